@@ -0,0 +1,98 @@
+/*
+admin.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Admin sends the operational commands, validating their arguments. The -ERR replies are
+// returned as *APIError, the other replies not starting with +OK as plain errors.
+type Admin struct {
+	fs *FSock
+}
+
+// NewAdmin returns the Admin sending its commands over fs.
+func NewAdmin(fs *FSock) *Admin {
+	return &Admin{fs: fs}
+}
+
+// ReloadXML reloads the XML configuration.
+func (adm *Admin) ReloadXML() error {
+	return adm.okCmd("reloadxml")
+}
+
+// ReloadACL reloads the access lists.
+func (adm *Admin) ReloadACL() error {
+	return adm.okCmd("reloadacl")
+}
+
+// HupAll hangs up all the channels with cause, NORMAL_CLEARING if empty. With varName
+// set, only the channels having it set to varValue are hung up.
+func (adm *Admin) HupAll(cause, varName, varValue string) error {
+	if cause == "" {
+		cause = "NORMAL_CLEARING"
+	}
+	if varName == "" {
+		return adm.okCmd("hupall", cause)
+	}
+	return adm.okCmd("hupall", cause, varName, varValue)
+}
+
+// Shutdown shuts FreeSWITCH down, the mode being one of elegant, asap, now, restart,
+// or cancel for the shutdown pending, right away if empty.
+func (adm *Admin) Shutdown(mode string) error {
+	if !slices.Contains([]string{"", "elegant", "asap", "now", "restart", "cancel"}, mode) {
+		return fmt.Errorf("invalid shutdown mode: <%s>", mode)
+	}
+	if mode == "" {
+		return adm.okCmd("fsctl", "shutdown")
+	}
+	return adm.okCmd("fsctl", "shutdown", mode)
+}
+
+// Pause stops accepting new calls in direction, inbound or outbound, both if empty.
+func (adm *Admin) Pause(direction string) error {
+	return adm.sessionsCmd("pause", direction)
+}
+
+// Resume accepts new calls again in direction, inbound or outbound, both if empty.
+func (adm *Admin) Resume(direction string) error {
+	return adm.sessionsCmd("resume", direction)
+}
+
+// sessionsCmd sends fsctl pause or resume for direction.
+func (adm *Admin) sessionsCmd(subCmd, direction string) error {
+	switch direction {
+	case "":
+		return adm.okCmd("fsctl", subCmd)
+	case "inbound", "outbound":
+		return adm.okCmd("fsctl", subCmd, direction)
+	default:
+		return fmt.Errorf("invalid direction: <%s>", direction)
+	}
+}
+
+// okCmd sends the api command cmd with args, expecting a +OK reply.
+func (adm *Admin) okCmd(cmd string, args ...string) error {
+	cmdStr, err := ApiCmd(cmd, args...)
+	if err != nil {
+		return err
+	}
+	rply, err := adm.fs.SendApiCmd(cmdStr)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(strings.TrimSpace(rply), "+OK") {
+		return fmt.Errorf("unexpected reply to %s received: <%s>", cmd, strings.TrimSpace(rply))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+admin_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestAdmin(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.Stub("api ", "+OK\n")
+	srv.Stub("api reloadacl", "-ERR acl reload failed\n")
+	srv.Stub("api fsctl resume", "Unknown command\n")
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	adm := NewAdmin(fs)
+	for _, err := range []error{
+		adm.ReloadXML(),
+		adm.HupAll("", "", ""),
+		adm.HupAll("MANAGER_REQUEST", "cgr_account", "1001"),
+		adm.Shutdown("elegant"),
+		adm.Pause("inbound"),
+	} {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	exp := []string{
+		"api reloadxml",
+		"api hupall NORMAL_CLEARING",
+		"api hupall MANAGER_REQUEST cgr_account 1001",
+		"api fsctl shutdown elegant",
+		"api fsctl pause inbound",
+	}
+	if cmds := srv.Commands(); len(cmds) < len(exp) || !reflect.DeepEqual(cmds[len(cmds)-len(exp):], exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, cmds)
+	}
+
+	var apiErr *APIError
+	if err := adm.ReloadACL(); !errors.As(err, &apiErr) {
+		t.Errorf("expected APIError, received: %v", err)
+	}
+	if err := adm.Resume(""); err == nil {
+		t.Error("expected error")
+	}
+	if err := adm.Shutdown("later"); err == nil {
+		t.Error("expected error")
+	}
+	if err := adm.Pause("sideways"); err == nil {
+		t.Error("expected error")
+	}
+	if err := adm.HupAll("NORMAL_CLEARING", "cgr_account", "1001\nfsctl shutdown"); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("expected ErrUnsafeArg, received: %v", err)
+	}
+}
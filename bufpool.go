@@ -0,0 +1,61 @@
+/*
+bufpool.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// The body buffers are pooled by size class, powers of two from 1KiB to 1MiB. The larger
+// bodies, e.g. the output of api show channels on a busy box, are allocated as needed.
+const (
+	minBodyClass = 10
+	maxBodyClass = 20
+)
+
+// bodyPools holds the body buffers of each size class, shared by all the connections.
+var bodyPools [maxBodyClass - minBodyClass + 1]sync.Pool
+
+// bodyClass returns the index within bodyPools of the size class fitting size, -1 if too large.
+func bodyClass(size int) int {
+	if size <= 1<<minBodyClass {
+		return 0
+	}
+	class := bits.Len(uint(size - 1))
+	if class > maxBodyClass {
+		return -1
+	}
+	return class - minBodyClass
+}
+
+// getBodyBuf returns a buffer of size bytes, out of the pool of its size class if any.
+// It is handed back with putBodyBuf once its content is no longer referenced.
+func getBodyBuf(size int) *[]byte {
+	class := bodyClass(size)
+	if class == -1 {
+		buf := make([]byte, size)
+		return &buf
+	}
+	if buf, ok := bodyPools[class].Get().(*[]byte); ok {
+		*buf = (*buf)[:size]
+		return buf
+	}
+	buf := make([]byte, size, 1<<(class+minBodyClass))
+	return &buf
+}
+
+// putBodyBuf hands the buffer back to the pool of its size class, dropping the ones too large.
+func putBodyBuf(buf *[]byte) {
+	class := bodyClass(cap(*buf))
+	if class == -1 || cap(*buf) != 1<<(class+minBodyClass) {
+		return
+	}
+	bodyPools[class].Put(buf)
+}
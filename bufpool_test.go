@@ -0,0 +1,42 @@
+/*
+bufpool_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import "testing"
+
+func TestBodyClass(t *testing.T) {
+	for size, exp := range map[int]int{
+		0:           0,
+		1:           0,
+		1024:        0,
+		1025:        1,
+		4096:        2,
+		1 << 20:     maxBodyClass - minBodyClass,
+		1<<20 + 1:   -1,
+		100 << 20:   -1,
+		1<<15 + 100: 6,
+	} {
+		if rcv := bodyClass(size); rcv != exp {
+			t.Errorf("size %d\nExpected: <%+v>, \nReceived: <%+v>", size, exp, rcv)
+		}
+	}
+}
+
+func TestBodyBufPool(t *testing.T) {
+	buf := getBodyBuf(3000)
+	if len(*buf) != 3000 || cap(*buf) != 4096 {
+		t.Errorf("unexpected buffer: len %d, cap %d", len(*buf), cap(*buf))
+	}
+	putBodyBuf(buf)
+	if buf = getBodyBuf(2500); len(*buf) != 2500 || cap(*buf) != 4096 {
+		t.Errorf("unexpected buffer: len %d, cap %d", len(*buf), cap(*buf))
+	}
+	if buf = getBodyBuf(2 << 20); len(*buf) != 2<<20 {
+		t.Errorf("unexpected buffer: len %d", len(*buf))
+	}
+	putBodyBuf(buf) // dropped, too large
+}
@@ -0,0 +1,64 @@
+/*
+callcontrol.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+// CallControl controls the live calls through the uuid_* api commands. The -ERR replies
+// are returned as *APIError, matching ErrNoSuchChannel through errors.Is if the channel
+// is gone.
+type CallControl struct {
+	fs *FSock
+}
+
+// NewCallControl returns the CallControl sending its commands over fs.
+func NewCallControl(fs *FSock) *CallControl {
+	return &CallControl{fs: fs}
+}
+
+// Answer answers the channel.
+func (cc *CallControl) Answer(uuid string) error {
+	return cc.uuidCmd("uuid_answer", uuid)
+}
+
+// Hangup hangs up the channel with cause, e.g. NORMAL_CLEARING, the default if empty.
+func (cc *CallControl) Hangup(uuid, cause string) error {
+	if cause == "" {
+		return cc.uuidCmd("uuid_kill", uuid)
+	}
+	return cc.uuidCmd("uuid_kill", uuid, cause)
+}
+
+// Bridge bridges the channels uuidA and uuidB together.
+func (cc *CallControl) Bridge(uuidA, uuidB string) error {
+	return cc.uuidCmd("uuid_bridge", uuidA, uuidB)
+}
+
+// Park parks the channel, stopping whatever it was executing.
+func (cc *CallControl) Park(uuid string) error {
+	return cc.uuidCmd("uuid_park", uuid)
+}
+
+// Hold places the channel on hold, its peer hearing the music on hold.
+func (cc *CallControl) Hold(uuid string) error {
+	return cc.uuidCmd("uuid_hold", uuid)
+}
+
+// Unhold takes the channel off hold.
+func (cc *CallControl) Unhold(uuid string) error {
+	return cc.uuidCmd("uuid_hold", "off", uuid)
+}
+
+// uuidCmd sends the api command cmd with args, discarding its +OK reply.
+func (cc *CallControl) uuidCmd(cmd string, args ...string) error {
+	cmdStr, err := ApiCmd(cmd, args...)
+	if err != nil {
+		return err
+	}
+	_, err = cc.fs.SendApiCmd(cmdStr)
+	return err
+}
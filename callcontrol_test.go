@@ -0,0 +1,64 @@
+/*
+callcontrol_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestCallControl(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.Stub("api uuid_", "+OK\n")
+	srv.Stub("api uuid_kill gone", "-ERR No such channel!\n")
+	srv.Stub("api uuid_bridge gone", "-ERR Invalid uuid gone\n")
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	cc := NewCallControl(fs)
+	for _, err := range []error{
+		cc.Answer("a1"),
+		cc.Hangup("a1", ""),
+		cc.Hangup("a1", "USER_BUSY"),
+		cc.Bridge("a1", "b1"),
+		cc.Park("a1"),
+		cc.Hold("a1"),
+		cc.Unhold("a1"),
+	} {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	exp := []string{
+		"api uuid_answer a1",
+		"api uuid_kill a1",
+		"api uuid_kill a1 USER_BUSY",
+		"api uuid_bridge a1 b1",
+		"api uuid_park a1",
+		"api uuid_hold a1",
+		"api uuid_hold off a1",
+	}
+	if cmds := srv.Commands(); len(cmds) < len(exp) || !reflect.DeepEqual(cmds[len(cmds)-len(exp):], exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, cmds)
+	}
+
+	var apiErr *APIError
+	if err := cc.Hangup("gone", ""); !errors.Is(err, ErrNoSuchChannel) || !errors.As(err, &apiErr) {
+		t.Errorf("expected ErrNoSuchChannel, received: %v", err)
+	}
+	if err := cc.Bridge("gone", "b1"); !errors.Is(err, ErrNoSuchChannel) {
+		t.Errorf("expected ErrNoSuchChannel, received: %v", err)
+	}
+	if err := cc.Park("a1\napi status"); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("expected ErrUnsafeArg, received: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+calls.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CallInfo is one call listed by show calls, out of its caller and callee legs.
+type CallInfo struct {
+	Created time.Time   // out of call_created_epoch
+	A       ChannelInfo // the caller leg
+	B       ChannelInfo // the callee leg, empty until bridged
+}
+
+// Bridged tells if the call has its callee leg.
+func (call CallInfo) Bridged() bool {
+	return call.B.UUID != ""
+}
+
+// ParseCalls parses the output of show calls, either plain or as json, into typed calls.
+// The plain rows MapChanData cannot split are left out.
+func ParseCalls(callInfoStr string) ([]CallInfo, error) {
+	callsInfoMap, err := mapListing(callInfoStr)
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]CallInfo, 0, len(callsInfoMap))
+	for _, callMp := range callsInfoMap {
+		call, err := newCallInfo(callMp)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// newCallInfo converts the call data as returned by MapChanData, the b_ prefixed fields
+// going to the callee leg.
+func newCallInfo(callMp map[string]string) (call CallInfo, err error) {
+	aMp := make(map[string]string)
+	bMp := make(map[string]string)
+	for fld, val := range callMp {
+		if bFld, isB := strings.CutPrefix(fld, "b_"); isB {
+			bMp[bFld] = val
+		} else {
+			aMp[fld] = val
+		}
+	}
+	if call.A, err = newChannelInfo(aMp); err != nil {
+		return CallInfo{}, err
+	}
+	if call.B, err = newChannelInfo(bMp); err != nil {
+		return CallInfo{}, err
+	}
+	epoch, err := atoiEmpty(callMp["call_created_epoch"])
+	if err != nil {
+		return CallInfo{}, fmt.Errorf("invalid call_created_epoch of call %s: %v", call.A.UUID, err)
+	}
+	if epoch != 0 {
+		call.Created = time.Unix(int64(epoch), 0)
+	}
+	return
+}
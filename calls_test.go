@@ -0,0 +1,92 @@
+/*
+calls_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCalls(t *testing.T) {
+	callInfoStr := `uuid,direction,created,created_epoch,name,state,cid_name,cid_num,ip_addr,dest,presence_id,presence_data,accountcode,callstate,callee_name,callee_num,callee_direction,call_uuid,hostname,sent_callee_name,sent_callee_num,b_uuid,b_direction,b_created,b_created_epoch,b_name,b_state,b_cid_name,b_cid_num,b_ip_addr,b_dest,b_presence_id,b_presence_data,b_accountcode,b_callstate,b_callee_name,b_callee_num,b_callee_direction,b_sent_callee_name,b_sent_callee_num,call_created_epoch
+0a30dd7c-c222-482f-a322-b1218a15f8cd,inbound,2024-01-10 10:00:01,1704880801,sofia/internal/1001@192.168.56.120,CS_EXECUTE,1001,1001,192.168.56.1,1002,1001@192.168.56.120,,,ACTIVE,Outbound Call,1002,SEND,,debian,Outbound Call,1002,4fe9b2e9-a3ab-4b2f-bd51-08bd6f6bb6a2,outbound,2024-01-10 10:00:01,1704880801,sofia/internal/1002@192.168.56.1:5062,CS_EXCHANGE_MEDIA,Extension 1001,1001,192.168.56.1,1002,1002@192.168.56.120,,,ACTIVE,Outbound Call,1002,SEND,,,1704880805
+7e1f6c0a-7d52-4a53-9d23-7b5a8a0c1f11,inbound,2024-01-10 10:00:09,1704880809,sofia/internal/1003@192.168.56.120,CS_EXECUTE,1003,1003,192.168.56.1,9196,1003@192.168.56.120,,,RINGING,,,,,debian,,,,,,,,,,,,,,,,,,,,,,
+
+2 total.
+`
+	exp := []CallInfo{
+		{
+			Created: time.Unix(1704880805, 0),
+			A: ChannelInfo{
+				UUID:            "0a30dd7c-c222-482f-a322-b1218a15f8cd",
+				Direction:       "inbound",
+				Created:         time.Unix(1704880801, 0),
+				Name:            "sofia/internal/1001@192.168.56.120",
+				State:           "CS_EXECUTE",
+				CIDName:         "1001",
+				CIDNum:          "1001",
+				IPAddr:          "192.168.56.1",
+				Dest:            "1002",
+				PresenceID:      "1001@192.168.56.120",
+				CallState:       "ACTIVE",
+				CalleeName:      "Outbound Call",
+				CalleeNum:       "1002",
+				CalleeDirection: "SEND",
+				Hostname:        "debian",
+				SentCalleeName:  "Outbound Call",
+				SentCalleeNum:   "1002",
+			},
+			B: ChannelInfo{
+				UUID:            "4fe9b2e9-a3ab-4b2f-bd51-08bd6f6bb6a2",
+				Direction:       "outbound",
+				Created:         time.Unix(1704880801, 0),
+				Name:            "sofia/internal/1002@192.168.56.1:5062",
+				State:           "CS_EXCHANGE_MEDIA",
+				CIDName:         "Extension 1001",
+				CIDNum:          "1001",
+				IPAddr:          "192.168.56.1",
+				Dest:            "1002",
+				PresenceID:      "1002@192.168.56.120",
+				CallState:       "ACTIVE",
+				CalleeName:      "Outbound Call",
+				CalleeNum:       "1002",
+				CalleeDirection: "SEND",
+			},
+		},
+		{
+			A: ChannelInfo{
+				UUID:       "7e1f6c0a-7d52-4a53-9d23-7b5a8a0c1f11",
+				Direction:  "inbound",
+				Created:    time.Unix(1704880809, 0),
+				Name:       "sofia/internal/1003@192.168.56.120",
+				State:      "CS_EXECUTE",
+				CIDName:    "1003",
+				CIDNum:     "1003",
+				IPAddr:     "192.168.56.1",
+				Dest:       "9196",
+				PresenceID: "1003@192.168.56.120",
+				CallState:  "RINGING",
+				Hostname:   "debian",
+			},
+		},
+	}
+	rcv, err := ParseCalls(callInfoStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+	if !rcv[0].Bridged() || rcv[1].Bridged() {
+		t.Errorf("unexpected bridged calls: %+v", rcv)
+	}
+
+	if _, err := ParseCalls("uuid,call_created_epoch\nabc,now\n\n1 total.\n"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+cdr.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"strings"
+	"time"
+)
+
+// CDR is the record of a channel, built out of its CHANNEL_HANGUP_COMPLETE.
+type CDR struct {
+	UUID              string
+	CallUUID          string // Channel-Call-UUID, shared by the legs of the call
+	Direction         string
+	CallerIDName      string
+	CallerIDNumber    string
+	DestinationNumber string
+	Context           string
+	HangupCause       string
+	StartTime         time.Time
+	AnswerTime        time.Time // zero if not answered
+	EndTime           time.Time
+	Duration          time.Duration     // from start to end
+	BillSec           time.Duration     // from answer to end, 0 if not answered
+	Variables         map[string]string // the channel variables selected, without the variable_ prefix
+}
+
+// NewCDR builds the CDR out of the CHANNEL_HANGUP_COMPLETE event, keeping the channel
+// variables named, all of them if none given.
+func NewCDR(ev Event, vars ...string) CDR {
+	cdr := CDR{
+		UUID:              ev.UUID(),
+		CallUUID:          ev.GetHeader("Channel-Call-UUID"),
+		Direction:         ev.GetHeader("Call-Direction"),
+		CallerIDName:      ev.GetHeader("Caller-Caller-ID-Name"),
+		CallerIDNumber:    ev.GetHeader("Caller-Caller-ID-Number"),
+		DestinationNumber: ev.GetHeader("Caller-Destination-Number"),
+		Context:           ev.GetHeader("Caller-Context"),
+		HangupCause:       ev.GetHeader("Hangup-Cause"),
+		StartTime:         channelTime(ev.GetHeader("Caller-Channel-Created-Time")),
+		AnswerTime:        channelTime(ev.GetHeader("Caller-Channel-Answered-Time")),
+		EndTime:           channelTime(ev.GetHeader("Caller-Channel-Hangup-Time")),
+		Variables:         make(map[string]string),
+	}
+	if !cdr.StartTime.IsZero() && !cdr.EndTime.IsZero() {
+		cdr.Duration = cdr.EndTime.Sub(cdr.StartTime)
+	}
+	if !cdr.AnswerTime.IsZero() && !cdr.EndTime.IsZero() {
+		cdr.BillSec = cdr.EndTime.Sub(cdr.AnswerTime)
+	}
+	if len(vars) == 0 {
+		for hdr, val := range ev.Headers {
+			if name, isVar := strings.CutPrefix(hdr, "variable_"); isVar {
+				cdr.Variables[name] = val
+			}
+		}
+		return cdr
+	}
+	for _, name := range vars {
+		if val, has := ev.Headers["variable_"+name]; has {
+			cdr.Variables[name] = val
+		}
+	}
+	return cdr
+}
+
+// channelTime converts the Caller-Channel-*-Time headers, in microseconds since epoch,
+// returning the zero time for 0, as set for the channels not reaching the state.
+func channelTime(usecStr string) time.Time {
+	if usecStr == "0" {
+		return time.Time{}
+	}
+	return parseEventTimestamp(usecStr)
+}
+
+// CDRHandler adapts a CDR handler to the plain handlers signature, to be registered for
+// the CHANNEL_HANGUP_COMPLETE events. The channel variables named are kept, all of them
+// if none given.
+func CDRHandler(handler func(CDR, int), vars ...string) func(string, int) {
+	return EventHandler(func(ev Event, connIdx int) {
+		handler(NewCDR(ev, vars...), connIdx)
+	})
+}
+
+// CollectCDRs subscribes to CHANNEL_HANGUP_COMPLETE, delivering the CDRs to handler over
+// reconnects, until removed by the returned ID. The channel variables named are kept, all
+// of them if none given.
+func (fs *FSock) CollectCDRs(handler func(CDR, int), vars ...string) (HandlerID, error) {
+	return fs.AddEventHandler("CHANNEL_HANGUP_COMPLETE", CDRHandler(handler, vars...))
+}
@@ -0,0 +1,96 @@
+/*
+cdr_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+var testHangupComplete = map[string]string{
+	"Event-Name":                   "CHANNEL_HANGUP_COMPLETE",
+	"Unique-ID":                    "0a30dd7c",
+	"Channel-Call-UUID":            "0a30dd7c",
+	"Call-Direction":               "inbound",
+	"Caller-Caller-ID-Name":        "Extension 1001",
+	"Caller-Caller-ID-Number":      "1001",
+	"Caller-Destination-Number":    "1002",
+	"Caller-Context":               "default",
+	"Hangup-Cause":                 "NORMAL_CLEARING",
+	"Caller-Channel-Created-Time":  "1704880800000000",
+	"Caller-Channel-Answered-Time": "1704880805000000",
+	"Caller-Channel-Hangup-Time":   "1704880865500000",
+	"variable_cgr_account":         "1001",
+	"variable_sip_user_agent":      "Linphone",
+}
+
+func TestNewCDR(t *testing.T) {
+	exp := CDR{
+		UUID:              "0a30dd7c",
+		CallUUID:          "0a30dd7c",
+		Direction:         "inbound",
+		CallerIDName:      "Extension 1001",
+		CallerIDNumber:    "1001",
+		DestinationNumber: "1002",
+		Context:           "default",
+		HangupCause:       "NORMAL_CLEARING",
+		StartTime:         time.UnixMicro(1704880800000000),
+		AnswerTime:        time.UnixMicro(1704880805000000),
+		EndTime:           time.UnixMicro(1704880865500000),
+		Duration:          65500 * time.Millisecond,
+		BillSec:           60500 * time.Millisecond,
+		Variables:         map[string]string{"cgr_account": "1001", "sip_user_agent": "Linphone"},
+	}
+	ev := Event{Headers: testHangupComplete}
+	if rcv := NewCDR(ev); !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+	exp.Variables = map[string]string{"cgr_account": "1001"}
+	if rcv := NewCDR(ev, "cgr_account", "missing"); !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+
+	unanswered := Event{Headers: map[string]string{
+		"Caller-Channel-Created-Time":  "1704880800000000",
+		"Caller-Channel-Answered-Time": "0",
+		"Caller-Channel-Hangup-Time":   "1704880830000000",
+	}}
+	if cdr := NewCDR(unanswered); !cdr.AnswerTime.IsZero() || cdr.BillSec != 0 || cdr.Duration != 30*time.Second {
+		t.Errorf("unexpected CDR: %+v", cdr)
+	}
+}
+
+func TestFSockCollectCDRs(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	cdrs := make(chan CDR, 1)
+	if _, err = fs.CollectCDRs(func(cdr CDR, _ int) { cdrs <- cdr }, "cgr_account"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = srv.WaitCommand("event plain CHANNEL_HANGUP_COMPLETE", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.SendEvent(testHangupComplete, ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cdr := <-cdrs:
+		if cdr.UUID != "0a30dd7c" || cdr.BillSec != 60500*time.Millisecond ||
+			!reflect.DeepEqual(cdr.Variables, map[string]string{"cgr_account": "1001"}) {
+			t.Errorf("unexpected CDR: %+v", cdr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no CDR received")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+channels.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChannelInfo is one channel listed by show channels.
+type ChannelInfo struct {
+	UUID            string
+	Direction       string
+	Created         time.Time // out of created_epoch
+	Name            string
+	State           string
+	CIDName         string
+	CIDNum          string
+	IPAddr          string
+	Dest            string
+	Application     string
+	ApplicationData string
+	Dialplan        string
+	Context         string
+	ReadCodec       string
+	ReadRate        int
+	ReadBitRate     int
+	WriteCodec      string
+	WriteRate       int
+	WriteBitRate    int
+	Secure          string
+	Hostname        string
+	PresenceID      string
+	PresenceData    string
+	CallState       string
+	CalleeName      string
+	CalleeNum       string
+	CalleeDirection string
+	CallUUID        string
+	SentCalleeName  string
+	SentCalleeNum   string
+}
+
+// ParseChannels parses the output of show channels, either plain or as json, into typed
+// channels. The plain rows MapChanData cannot split are left out.
+func ParseChannels(chanInfoStr string) ([]ChannelInfo, error) {
+	chansInfoMap, err := mapListing(chanInfoStr)
+	if err != nil {
+		return nil, err
+	}
+	chans := make([]ChannelInfo, 0, len(chansInfoMap))
+	for _, chnMp := range chansInfoMap {
+		ch, err := newChannelInfo(chnMp)
+		if err != nil {
+			return nil, err
+		}
+		chans = append(chans, ch)
+	}
+	return chans, nil
+}
+
+// newChannelInfo converts the channel data as returned by MapChanData.
+func newChannelInfo(chnMp map[string]string) (ch ChannelInfo, err error) {
+	ch = ChannelInfo{
+		UUID:            chnMp["uuid"],
+		Direction:       chnMp["direction"],
+		Name:            chnMp["name"],
+		State:           chnMp["state"],
+		CIDName:         chnMp["cid_name"],
+		CIDNum:          chnMp["cid_num"],
+		IPAddr:          chnMp["ip_addr"],
+		Dest:            chnMp["dest"],
+		Application:     chnMp["application"],
+		ApplicationData: chnMp["application_data"],
+		Dialplan:        chnMp["dialplan"],
+		Context:         chnMp["context"],
+		ReadCodec:       chnMp["read_codec"],
+		WriteCodec:      chnMp["write_codec"],
+		Secure:          chnMp["secure"],
+		Hostname:        chnMp["hostname"],
+		PresenceID:      chnMp["presence_id"],
+		PresenceData:    chnMp["presence_data"],
+		CallState:       chnMp["callstate"],
+		CalleeName:      chnMp["callee_name"],
+		CalleeNum:       chnMp["callee_num"],
+		CalleeDirection: chnMp["callee_direction"],
+		CallUUID:        chnMp["call_uuid"],
+		SentCalleeName:  chnMp["sent_callee_name"],
+		SentCalleeNum:   chnMp["sent_callee_num"],
+	}
+	var epoch int
+	for fld, val := range map[string]*int{
+		"created_epoch":  &epoch,
+		"read_rate":      &ch.ReadRate,
+		"read_bit_rate":  &ch.ReadBitRate,
+		"write_rate":     &ch.WriteRate,
+		"write_bit_rate": &ch.WriteBitRate,
+	} {
+		if *val, err = atoiEmpty(chnMp[fld]); err != nil {
+			return ChannelInfo{}, fmt.Errorf("invalid %s of channel %s: %v", fld, ch.UUID, err)
+		}
+	}
+	if epoch != 0 {
+		ch.Created = time.Unix(int64(epoch), 0)
+	}
+	return
+}
+
+// mapListing parses the output of the show commands, either plain or as json, into one
+// map per row.
+func mapListing(listing string) ([]map[string]string, error) {
+	if strings.HasPrefix(strings.TrimSpace(listing), "{") {
+		return MapChanDataJSON(listing)
+	}
+	return MapChanData(listing, ","), nil
+}
+
+// atoiEmpty is the same as strconv.Atoi, converting the empty string to 0.
+func atoiEmpty(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,72 @@
+/*
+channels_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseChannels(t *testing.T) {
+	chanInfoStr := `uuid,direction,created,created_epoch,name,state,cid_name,cid_num,ip_addr,dest,application,application_data,dialplan,context,read_codec,read_rate,read_bit_rate,write_codec,write_rate,write_bit_rate,secure,hostname,presence_id,presence_data,callstate,callee_name,callee_num,callee_direction,call_uuid,sent_callee_name,sent_callee_num
+c56125cc-024a-48a2-adbc-9612f6c02334,outbound,2014-10-26 18:08:32,1414343312,sofia/ipbxas/dan@172.16.254.66,CS_EXCHANGE_MEDIA,dan,+4986517174963,172.16.254.66,dan,playback,local_stream://moh,XML,ipbxas,PCMA,8000,64000,PCMA,8000,64000,,iPBXDev,dan@172.16.254.66,,ACTIVE,Outbound Call,dan,,fed464b3-a328-453f-9437-92b9b6a400fd,,
+
+1 total.
+`
+	exp := []ChannelInfo{{
+		UUID:            "c56125cc-024a-48a2-adbc-9612f6c02334",
+		Direction:       "outbound",
+		Created:         time.Unix(1414343312, 0),
+		Name:            "sofia/ipbxas/dan@172.16.254.66",
+		State:           "CS_EXCHANGE_MEDIA",
+		CIDName:         "dan",
+		CIDNum:          "+4986517174963",
+		IPAddr:          "172.16.254.66",
+		Dest:            "dan",
+		Application:     "playback",
+		ApplicationData: "local_stream://moh",
+		Dialplan:        "XML",
+		Context:         "ipbxas",
+		ReadCodec:       "PCMA",
+		ReadRate:        8000,
+		ReadBitRate:     64000,
+		WriteCodec:      "PCMA",
+		WriteRate:       8000,
+		WriteBitRate:    64000,
+		Hostname:        "iPBXDev",
+		PresenceID:      "dan@172.16.254.66",
+		CallState:       "ACTIVE",
+		CalleeName:      "Outbound Call",
+		CalleeNum:       "dan",
+		CallUUID:        "fed464b3-a328-453f-9437-92b9b6a400fd",
+	}}
+	if rcv, err := ParseChannels(chanInfoStr); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+
+	chanInfoJSON := `{"row_count":1,"rows":[{"uuid":"c56125cc-024a-48a2-adbc-9612f6c02334","direction":"outbound",` +
+		`"created_epoch":"1414343312","read_rate":"8000","application_data":"file,with,commas.wav"}]}`
+	exp = []ChannelInfo{{
+		UUID:            "c56125cc-024a-48a2-adbc-9612f6c02334",
+		Direction:       "outbound",
+		Created:         time.Unix(1414343312, 0),
+		ApplicationData: "file,with,commas.wav",
+		ReadRate:        8000,
+	}}
+	if rcv, err := ParseChannels(chanInfoJSON); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+
+	if _, err := ParseChannels(`{"rows":[{"uuid":"1","read_rate":"fast"}]}`); err == nil {
+		t.Error("expected error for the invalid read_rate")
+	}
+}
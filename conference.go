@@ -0,0 +1,198 @@
+/*
+conference.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ConferenceMaintenance is the name the conference events are subscribed and handled by.
+const ConferenceMaintenance = "CUSTOM conference::maintenance"
+
+// Conference controls a mod_conference conference through the conference api command.
+// The member commands take the member ID, or all, last or non_moderator.
+type Conference struct {
+	fs   *FSock
+	name string
+}
+
+// NewConference returns the Conference named name, sending its commands over fs.
+func NewConference(fs *FSock, name string) *Conference {
+	return &Conference{fs: fs, name: name}
+}
+
+// ConferenceMember is one member listed by conference list.
+type ConferenceMember struct {
+	ID             int
+	Channel        string // e.g. sofia/internal/1001@192.168.56.120
+	UUID           string
+	CallerIDName   string
+	CallerIDNumber string
+	Flags          []string // e.g. hear, speak, talking, floor or moderator
+	VolumeIn       int
+	VolumeOut      int
+	EnergyLevel    int
+}
+
+// HasFlag tells if the member has the flag, e.g. talking.
+func (m ConferenceMember) HasFlag(flag string) bool {
+	return slices.Contains(m.Flags, flag)
+}
+
+// ParseConferenceList parses the output of conference list, one member per line.
+func ParseConferenceList(list string) (members []ConferenceMember, err error) {
+	for _, line := range strings.Split(strings.TrimSpace(list), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		flds := strings.Split(line, ";")
+		if len(flds) < 9 {
+			return nil, fmt.Errorf("unexpected conference list line received: <%s>", line)
+		}
+		m := ConferenceMember{
+			Channel:        flds[1],
+			UUID:           flds[2],
+			CallerIDName:   flds[3],
+			CallerIDNumber: flds[4],
+		}
+		if flds[5] != "" {
+			m.Flags = strings.Split(flds[5], "|")
+		}
+		for i, val := range map[int]*int{0: &m.ID, 6: &m.VolumeIn, 7: &m.VolumeOut, 8: &m.EnergyLevel} {
+			if *val, err = strconv.Atoi(flds[i]); err != nil {
+				return nil, fmt.Errorf("unexpected conference list line received: <%s>", line)
+			}
+		}
+		members = append(members, m)
+	}
+	return
+}
+
+// Members lists the members of the conference.
+func (conf *Conference) Members() ([]ConferenceMember, error) {
+	list, err := conf.cmd("list")
+	if err != nil {
+		return nil, err
+	}
+	return ParseConferenceList(list)
+}
+
+// Kick kicks the member out of the conference.
+func (conf *Conference) Kick(member string) error {
+	return conf.memberCmd("kick", member)
+}
+
+// Mute mutes the member.
+func (conf *Conference) Mute(member string) error {
+	return conf.memberCmd("mute", member)
+}
+
+// Unmute unmutes the member.
+func (conf *Conference) Unmute(member string) error {
+	return conf.memberCmd("unmute", member)
+}
+
+// Deaf stops the member hearing the conference.
+func (conf *Conference) Deaf(member string) error {
+	return conf.memberCmd("deaf", member)
+}
+
+// Undeaf lets the member hear the conference again.
+func (conf *Conference) Undeaf(member string) error {
+	return conf.memberCmd("undeaf", member)
+}
+
+// Lock stops new members joining the conference.
+func (conf *Conference) Lock() error {
+	_, err := conf.cmd("lock")
+	return err
+}
+
+// Unlock lets new members join the conference again.
+func (conf *Conference) Unlock() error {
+	_, err := conf.cmd("unlock")
+	return err
+}
+
+// memberCmd sends the member command, mod_conference answering OK for every member it
+// was applied to and something else, e.g. Non-Existant ID 9, otherwise.
+func (conf *Conference) memberCmd(subCmd, member string) error {
+	rply, err := conf.cmd(subCmd, member)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(rply), "\n") {
+		if line = strings.TrimSpace(line); !strings.HasPrefix(line, "OK") && !strings.HasPrefix(line, "+OK") {
+			return fmt.Errorf("conference %s %s %s failed: <%s>", conf.name, subCmd, member, line)
+		}
+	}
+	return nil
+}
+
+// cmd sends conference <name> subCmd args, turning the not found reply into an error.
+func (conf *Conference) cmd(subCmd string, args ...string) (string, error) {
+	cmdStr, err := ApiCmd("conference", append([]string{conf.name, subCmd}, args...)...)
+	if err != nil {
+		return "", err
+	}
+	rply, err := conf.fs.SendApiCmd(cmdStr)
+	if err != nil {
+		return "", err
+	}
+	if txt := strings.TrimSpace(rply); strings.HasPrefix(txt, "Conference") && strings.HasSuffix(txt, "not found") {
+		return "", fmt.Errorf("conference %s not found", conf.name)
+	}
+	return rply, nil
+}
+
+// ConferenceEvent is a conference::maintenance event.
+type ConferenceEvent struct {
+	Action         string // e.g. add-member, del-member, start-talking, stop-talking or mute-member
+	Conference     string
+	ConferenceUUID string
+	Size           int    // members in the conference
+	MemberID       int    // 0 for the events not about a member
+	MemberType     string // e.g. moderator or member
+	UUID           string // channel of the member
+	CallerIDName   string
+	CallerIDNumber string
+	Talking        bool
+	Muted          bool
+	Event          Event
+}
+
+// NewConferenceEvent decodes the conference::maintenance event.
+func NewConferenceEvent(ev Event) ConferenceEvent {
+	confEv := ConferenceEvent{
+		Action:         ev.GetHeader("Action"),
+		Conference:     ev.GetHeader("Conference-Name"),
+		ConferenceUUID: ev.GetHeader("Conference-Unique-ID"),
+		MemberType:     ev.GetHeader("Member-Type"),
+		UUID:           ev.UUID(),
+		CallerIDName:   ev.GetHeader("Caller-Caller-ID-Name"),
+		CallerIDNumber: ev.GetHeader("Caller-Caller-ID-Number"),
+		Talking:        ev.GetHeader("Talking") == "true",
+		Muted:          ev.GetHeader("Speak") == "false",
+		Event:          ev,
+	}
+	confEv.Size, _ = strconv.Atoi(ev.GetHeader("Conference-Size"))
+	confEv.MemberID, _ = strconv.Atoi(ev.GetHeader("Member-ID"))
+	return confEv
+}
+
+// ConferenceEventHandler adapts a handler of conference events to the plain handlers
+// signature, to be registered for ConferenceMaintenance.
+func ConferenceEventHandler(handler func(ConferenceEvent, int)) func(string, int) {
+	return EventHandler(func(ev Event, connIdx int) {
+		handler(NewConferenceEvent(ev), connIdx)
+	})
+}
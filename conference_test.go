@@ -0,0 +1,91 @@
+/*
+conference_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+const testConferenceList = "7;sofia/internal/1001@192.168.56.120;0a30dd7c;Extension 1001;1001;hear|speak|talking|floor;0;0;100\n" +
+	"8;sofia/internal/1002@192.168.56.120;4fe9b2e9;Extension 1002;1002;hear;-1;2;300\n"
+
+func TestParseConferenceList(t *testing.T) {
+	exp := []ConferenceMember{
+		{ID: 7, Channel: "sofia/internal/1001@192.168.56.120", UUID: "0a30dd7c", CallerIDName: "Extension 1001",
+			CallerIDNumber: "1001", Flags: []string{"hear", "speak", "talking", "floor"}, EnergyLevel: 100},
+		{ID: 8, Channel: "sofia/internal/1002@192.168.56.120", UUID: "4fe9b2e9", CallerIDName: "Extension 1002",
+			CallerIDNumber: "1002", Flags: []string{"hear"}, VolumeIn: -1, VolumeOut: 2, EnergyLevel: 300},
+	}
+	members, err := ParseConferenceList(testConferenceList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(members, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, members)
+	}
+	if !members[0].HasFlag("talking") || members[1].HasFlag("speak") {
+		t.Errorf("unexpected flags: %+v", members)
+	}
+	if _, err = ParseConferenceList("7;sofia/internal/1001@192.168.56.120\n"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestConference(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.Stub("api conference 3000 list", testConferenceList)
+	srv.Stub("api conference 3000 mute", "OK mute 7\nOK mute 8\n")
+	srv.Stub("api conference 3000 kick 9", "Non-Existant ID 9\n")
+	srv.Stub("api conference 4000", "Conference 4000 not found\n")
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	conf := NewConference(fs, "3000")
+	if members, err := conf.Members(); err != nil {
+		t.Error(err)
+	} else if len(members) != 2 {
+		t.Errorf("unexpected members: %+v", members)
+	}
+	if err = conf.Mute("all"); err != nil {
+		t.Error(err)
+	}
+	if err = conf.Kick("9"); err == nil {
+		t.Error("expected error")
+	}
+	if _, err = NewConference(fs, "4000").Members(); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestNewConferenceEvent(t *testing.T) {
+	ev := NewEvent("Event-Name: CUSTOM\nEvent-Subclass: conference%3A%3Amaintenance\nAction: start-talking\n" +
+		"Conference-Name: 3000\nConference-Unique-ID: 6d1e4f0c\nConference-Size: 2\nMember-ID: 7\n" +
+		"Member-Type: member\nUnique-ID: 0a30dd7c\nCaller-Caller-ID-Name: Extension%201001\n" +
+		"Caller-Caller-ID-Number: 1001\nTalking: true\nSpeak: false\n")
+	exp := ConferenceEvent{
+		Action:         "start-talking",
+		Conference:     "3000",
+		ConferenceUUID: "6d1e4f0c",
+		Size:           2,
+		MemberID:       7,
+		MemberType:     "member",
+		UUID:           "0a30dd7c",
+		CallerIDName:   "Extension 1001",
+		CallerIDNumber: "1001",
+		Talking:        true,
+		Muted:          true,
+		Event:          ev,
+	}
+	if rcv := NewConferenceEvent(ev); !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+}
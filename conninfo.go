@@ -0,0 +1,82 @@
+/*
+conninfo.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"slices"
+)
+
+// ConnInfo identifies the connection an event came from, for the components managing
+// many named connections.
+type ConnInfo struct {
+	Idx      int               // index given with WithConnIdx
+	ID       string            // identifier given with WithConnID, optional
+	Metadata map[string]string // given with WithConnMetadata, not to be modified
+}
+
+// connInfoKey indexes the ConnInfo within the context of the handlers.
+type connInfoKey struct{}
+
+// ConnInfoFromContext returns the ConnInfo of the connection out of the context received
+// by an EventHandlerCtx.
+func ConnInfoFromContext(ctx context.Context) (info ConnInfo, has bool) {
+	info, has = ctx.Value(connInfoKey{}).(ConnInfo)
+	return
+}
+
+// ConnInfoHandler adapts a handler receiving the ConnInfo to the plain handlers signature,
+// for the connection described by info.
+func ConnInfoHandler(info ConnInfo, handler func(string, ConnInfo)) func(string, int) {
+	return func(event string, _ int) {
+		handler(event, info)
+	}
+}
+
+// fields returns the structured log fields describing the connection, followed by args.
+// The metadata keys are used as field names.
+func (info ConnInfo) fields(args ...any) []any {
+	fields := make([]any, 0, 4+2*len(info.Metadata)+len(args))
+	fields = append(fields, "conn_idx", info.Idx)
+	if info.ID != "" {
+		fields = append(fields, "conn_id", info.ID)
+	}
+	keys := getMapKeys(info.Metadata)
+	slices.Sort(keys)
+	for _, key := range keys {
+		fields = append(fields, key, info.Metadata[key])
+	}
+	return append(fields, args...)
+}
+
+// logger returns lgr attaching the connection fields and args to its messages. The plain
+// Loggers get the ID within the message text, their messages naming the index already.
+func (info ConnInfo) logger(lgr Logger, args ...any) Logger {
+	if fl, canField := lgr.(FieldLogger); canField {
+		return fl.With(info.fields(args...)...)
+	}
+	if info.ID != "" {
+		return newConnLogger(lgr, "conn_id", info.ID)
+	}
+	return lgr
+}
+
+// scope returns lgr scoped to the connection, through fields if supported, by prefixing
+// its messages with them otherwise. Either way, more fields can be attached through With.
+func (info ConnInfo) scope(lgr Logger) FieldLogger {
+	if fl, canField := lgr.(FieldLogger); canField {
+		if scoped, canField := fl.With(info.fields()...).(FieldLogger); canField {
+			return scoped
+		}
+	}
+	if info.ID != "" {
+		return newConnLogger(lgr, "conn_id", info.ID, "conn_idx", info.Idx)
+	}
+	return newConnLogger(lgr, "conn_idx", info.Idx)
+}
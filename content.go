@@ -0,0 +1,80 @@
+/*
+content.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ContentHandler handles the messages received with a given Content-Type, getting
+// their headers and body as read.
+type ContentHandler func(header, body string, connIdx int)
+
+// builtinContentHandlers handle the messages by their Content-Type. The ones of the
+// types not listed, nor registered with WithContentHandler, are dispatched as events
+// if they come with a body.
+var builtinContentHandlers = map[string]func(fsConn *FSConn, hdr []byte, body string){
+	// For API responses, hand the body to the command awaiting it.
+	"api/response": func(fsConn *FSConn, _ []byte, body string) {
+		fsConn.deliverReply(body)
+	},
+	// For command replies, extract the "Reply-Text" from
+	// the header and hand it to the command awaiting it.
+	"command/reply": func(fsConn *FSConn, hdr []byte, _ string) {
+		fsConn.deliverReply(headerVal(string(hdr), "Reply-Text"))
+	},
+	// Announces the hangup, with linger the events keep coming
+	// until FreeSWITCH closes the connection.
+	"text/disconnect-notice": func(fsConn *FSConn, _ []byte, body string) {
+		fsConn.log().Info(fmt.Sprintf("<FSock> Received disconnect notice: <%s>", strings.TrimSpace(body)))
+	},
+	// Console log lines, requested with log <level>.
+	"log/data": func(fsConn *FSConn, hdr []byte, body string) {
+		fsConn.dispatchLog(string(hdr) + "\n" + body)
+	},
+	// Convert XML events to plain ones, so they
+	// share the dispatching with the rest.
+	"text/event-xml": func(fsConn *FSConn, _ []byte, body string) {
+		event, err := xmlEventToPlain(body)
+		if err != nil {
+			fsConn.log().Warning(fmt.Sprintf("<FSock> Cannot decode XML event: <%v>", err))
+			return
+		}
+		fsConn.handleEvent(event)
+	},
+}
+
+// handleContent hands the message to the handler of its Content-Type, the registered
+// ones taking precedence over the built-in ones.
+func (fsConn *FSConn) handleContent(hdr []byte, body string) {
+	contentType := contentTypeOf(hdr)
+	if handler, has := fsConn.contentHandlers[string(contentType)]; has {
+		handler(string(hdr), body, fsConn.connIdx)
+		return
+	}
+	if handler, has := builtinContentHandlers[string(contentType)]; has {
+		handler(fsConn, hdr, body)
+		return
+	}
+	if body != "" { // could be an event, try dispatching it
+		fsConn.handleEvent(body)
+	}
+}
+
+// contentTypeOf returns the Content-Type out of the message headers, nil if missing.
+// Anchored as contentLength, e.g. X-Content-Type is not taken for it.
+func contentTypeOf(hdr []byte) []byte {
+	line, has := headerLine(hdr, []byte("Content-Type"))
+	if !has {
+		return nil
+	}
+	return bytes.TrimSpace(bytes.TrimPrefix(line, []byte(":")))
+}
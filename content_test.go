@@ -0,0 +1,67 @@
+/*
+content_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestContentTypeOf(t *testing.T) {
+	for hdr, exp := range map[string]string{
+		"Content-Type: api/response\nContent-Length: 4":                "api/response",
+		"Content-Length: 4\nContent-Type: text/event-xml":              "text/event-xml",
+		"Content-Type:log/data ":                                       "log/data",
+		"Content-Length: 4":                                            "",
+		"X-Content-Type: text/plain\nContent-Type: auth/request":       "auth/request",
+		"Content-Length: 4\nReply-Text: -ERR Content-Type: text/plain": "",
+	} {
+		if rcv := string(contentTypeOf([]byte(hdr))); rcv != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, rcv)
+		}
+	}
+}
+
+func TestFSockContentHandler(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		for _, frame := range []string{
+			"Content-Type: text/rude-rejection\nContent-Length: 13\n\nAccess Denied",
+			"Content-Type: text/disconnect-notice\nContent-Length: 3\n\nbye",
+		} {
+			if _, err := c.Write([]byte(frame)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		io.Copy(io.Discard, c)
+	})
+	type msg struct{ contentType, body string }
+	msgs := make(chan msg, 2)
+	handler := func(hdr, body string, _ int) {
+		msgs <- msg{headerVal(hdr, "Content-Type"), body}
+	}
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithContentHandler("text/rude-rejection", handler),
+		WithContentHandler("text/disconnect-notice", handler),
+		WithContentHandler("command/reply", handler)) // ignored, needed by the commands
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	for _, exp := range []msg{{"text/rude-rejection", "Access Denied"}, {"text/disconnect-notice", "bye"}} {
+		select {
+		case rcv := <-msgs:
+			if rcv != exp {
+				t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", exp.contentType)
+		}
+	}
+}
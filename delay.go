@@ -0,0 +1,108 @@
+/*
+delay.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// FibDuration returns successive Fibonacci numbers converted to time.Duration,
+// capped at maxDuration if positive. It is the default delay function.
+func FibDuration(durationUnit, maxDuration time.Duration) func() time.Duration {
+	a, b := 0, 1
+	return func() time.Duration {
+		a, b = b, a+b
+		fibNrAsDuration := time.Duration(a) * durationUnit
+		if maxDuration > 0 && maxDuration < fibNrAsDuration {
+			return maxDuration
+		}
+		return fibNrAsDuration
+	}
+}
+
+// ExponentialDelay returns delays doubling from durationUnit on,
+// capped at maxDuration if positive.
+func ExponentialDelay(durationUnit, maxDuration time.Duration) func() time.Duration {
+	next := durationUnit
+	return func() time.Duration {
+		delay := next
+		if maxDuration > 0 && maxDuration < delay {
+			return maxDuration
+		}
+		if next <= math.MaxInt64/2 { // stop growing instead of overflowing
+			next *= 2
+		}
+		return delay
+	}
+}
+
+// ConstantDelay returns durationUnit every time, capped at maxDuration if positive.
+func ConstantDelay(durationUnit, maxDuration time.Duration) func() time.Duration {
+	delay := durationUnit
+	if maxDuration > 0 && maxDuration < delay {
+		delay = maxDuration
+	}
+	return func() time.Duration {
+		return delay
+	}
+}
+
+// BackoffPolicy decides the delays between the reconnect attempts.
+type BackoffPolicy interface {
+	Next() time.Duration // delay before the next attempt
+	Reset()              // restarts the delays, called before every round of reconnects
+}
+
+// BackoffFromDelayFunc adapts a delay function constructor, like FibDuration,
+// to a BackoffPolicy. Reset constructs a new delay function.
+func BackoffFromDelayFunc(delayFunc func(time.Duration, time.Duration) func() time.Duration,
+	durationUnit, maxDuration time.Duration) BackoffPolicy {
+	bp := &delayFuncBackoff{
+		delayFunc:    delayFunc,
+		durationUnit: durationUnit,
+		maxDuration:  maxDuration,
+	}
+	bp.Reset()
+	return bp
+}
+
+type delayFuncBackoff struct {
+	delayFunc    func(time.Duration, time.Duration) func() time.Duration
+	durationUnit time.Duration
+	maxDuration  time.Duration
+	delay        func() time.Duration
+}
+
+func (bp *delayFuncBackoff) Next() time.Duration { return bp.delay() }
+
+func (bp *delayFuncBackoff) Reset() { bp.delay = bp.delayFunc(bp.durationUnit, bp.maxDuration) }
+
+// NewExponentialJitterBackoff returns exponential delays, starting at base and capped at
+// maxDuration if positive, with each delay picked randomly between its half and its full
+// value. The jitter keeps many instances from reconnecting in lockstep.
+func NewExponentialJitterBackoff(base, maxDuration time.Duration) BackoffPolicy {
+	return &jitterBackoff{
+		BackoffPolicy: BackoffFromDelayFunc(ExponentialDelay, base, maxDuration),
+	}
+}
+
+type jitterBackoff struct {
+	BackoffPolicy
+}
+
+func (bp *jitterBackoff) Next() time.Duration {
+	delay := bp.BackoffPolicy.Next()
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
@@ -0,0 +1,137 @@
+/*
+delay_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDelayFuncs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		delayFunc func(time.Duration, time.Duration) func() time.Duration
+		exp       []time.Duration
+	}{
+		"FibDuration": {
+			delayFunc: FibDuration,
+			exp:       []time.Duration{time.Second, time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 6 * time.Second},
+		},
+		"ExponentialDelay": {
+			delayFunc: ExponentialDelay,
+			exp:       []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 6 * time.Second, 6 * time.Second, 6 * time.Second},
+		},
+		"ConstantDelay": {
+			delayFunc: ConstantDelay,
+			exp:       []time.Duration{time.Second, time.Second, time.Second, time.Second, time.Second, time.Second},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			delay := tc.delayFunc(time.Second, 6*time.Second)
+			rcv := make([]time.Duration, 0, len(tc.exp))
+			for range tc.exp {
+				rcv = append(rcv, delay())
+			}
+			if !reflect.DeepEqual(rcv, tc.exp) {
+				t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", tc.exp, rcv)
+			}
+		})
+	}
+	if delay := ConstantDelay(time.Minute, time.Second); delay() != time.Second {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", time.Second, delay())
+	}
+}
+
+func TestDelayBackoffFromDelayFunc(t *testing.T) {
+	bp := BackoffFromDelayFunc(FibDuration, time.Second, 0)
+	for _, exp := range []time.Duration{time.Second, time.Second, 2 * time.Second} {
+		if rcv := bp.Next(); rcv != exp {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+		}
+	}
+	bp.Reset()
+	if rcv := bp.Next(); rcv != time.Second {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", time.Second, rcv)
+	}
+}
+
+func TestDelayExponentialJitterBackoff(t *testing.T) {
+	bp := NewExponentialJitterBackoff(time.Second, 8*time.Second)
+	for _, maxDelay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		if rcv := bp.Next(); rcv < maxDelay/2 || rcv > maxDelay {
+			t.Errorf("expected delay between %v and %v, received: %v", maxDelay/2, maxDelay, rcv)
+		}
+	}
+}
+
+type backoffMock struct {
+	nexts, resets int
+}
+
+func (bm *backoffMock) Next() time.Duration {
+	bm.nexts++
+	return time.Millisecond
+}
+
+func (bm *backoffMock) Reset() { bm.resets++ }
+
+func TestDelayReconnectBackoffPolicy(t *testing.T) {
+	bm := new(backoffMock)
+	fs := newFSock("127.0.0.1:1", "ClueCon", WithReconnects(3), WithBackoffPolicy(bm))
+	if err := fs.ReconnectIfNeeded(); err == nil {
+		t.Fatal("expected connection error")
+	}
+	if bm.resets != 1 || bm.nexts != 3 {
+		t.Errorf("unexpected backoff usage, resets: %d, nexts: %d", bm.resets, bm.nexts)
+	}
+}
+
+func TestDelayReconnectBudget(t *testing.T) {
+	bm := new(backoffMock)
+	fs := newFSock("127.0.0.1:1", "ClueCon", WithReconnects(-1), WithBackoffPolicy(bm),
+		WithReconnectBudget(50*time.Millisecond))
+	start := time.Now()
+	err := fs.ReconnectIfNeeded()
+	if !errors.Is(err, ErrReconnectBudget) {
+		t.Fatalf("\nExpected: <%+v>, \nReceived: <%+v>", ErrReconnectBudget, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("reconnecting took %v, over the budget", elapsed)
+	}
+	if bm.nexts < 2 {
+		t.Errorf("expected several attempts within the budget, received: %d", bm.nexts)
+	}
+}
+
+func TestDelayReconnectBudgetHangingConnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() { // accepting without ever asking for auth
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	fs := newFSock(ln.Addr().String(), "ClueCon", WithReconnects(-1), WithBackoffPolicy(new(backoffMock)),
+		WithReconnectBudget(50*time.Millisecond))
+	start := time.Now()
+	err = fs.ReconnectIfNeeded()
+	if !errors.Is(err, ErrReconnectBudget) {
+		t.Fatalf("\nExpected: <%+v>, \nReceived: <%+v>", ErrReconnectBudget, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("reconnecting took %v, over the budget", elapsed)
+	}
+}
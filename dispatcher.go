@@ -0,0 +1,289 @@
+/*
+dispatcher.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// workerPool runs the event handlers on a fixed number of goroutines, fed through a
+// bounded queue, instead of one goroutine per handler and event.
+type workerPool struct {
+	tasks chan func()
+}
+
+// newWorkerPool starts workers goroutines consuming a queue of queueSize tasks.
+func newWorkerPool(workers, queueSize int) *workerPool {
+	wp := &workerPool{tasks: make(chan func(), queueSize)}
+	for range workers {
+		go wp.work()
+	}
+	return wp
+}
+
+// work runs the queued tasks until the pool is stopped.
+func (wp *workerPool) work() {
+	for task := range wp.tasks {
+		task()
+	}
+}
+
+// submit queues task, blocking while the queue is full so the reading slows down
+// to the pace of the handlers.
+func (wp *workerPool) submit(task func()) {
+	wp.tasks <- task
+}
+
+// stop lets the workers exit once they ran the tasks already queued.
+// No task may be submitted afterwards.
+func (wp *workerPool) stop() {
+	close(wp.tasks)
+}
+
+// depth returns the number of tasks waiting for a worker.
+func (wp *workerPool) depth() int {
+	return len(wp.tasks)
+}
+
+// OverflowPolicy decides the fate of the events arriving while the event queue is full.
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // stop reading the connection, replies included, until the queue has room
+	OverflowDropOldest                       // drop the oldest queued event, making room for the new one
+	OverflowDropNewest                       // drop the event just received
+)
+
+// eventQueue buffers the events between the reading of the connection and their
+// dispatching, bounding the memory used when the handlers fall behind.
+type eventQueue struct {
+	events  chan string
+	policy  OverflowPolicy
+	dropped atomic.Uint64
+}
+
+// newEventQueue returns a queue of capacity events, overflowing as policy says.
+func newEventQueue(capacity int, policy OverflowPolicy) *eventQueue {
+	return &eventQueue{
+		events: make(chan string, capacity),
+		policy: policy,
+	}
+}
+
+// push queues the event, returning the one dropped to make room, if any.
+// Meant for a single producer, the loop reading the connection.
+func (eq *eventQueue) push(event string) (dropped string, hasDropped bool) {
+	if eq.policy == OverflowBlock {
+		eq.events <- event
+		return
+	}
+	select {
+	case eq.events <- event:
+		return
+	default:
+	}
+	if eq.policy == OverflowDropNewest {
+		eq.dropped.Add(1)
+		return event, true
+	}
+	select { // drop the oldest, unless consumed meanwhile
+	case dropped = <-eq.events:
+		eq.dropped.Add(1)
+		hasDropped = true
+	default:
+	}
+	eq.events <- event // there is room now, no other producer can take it
+	return
+}
+
+// close ends the queue, its consumer returning once the queued events are taken.
+// No event may be pushed afterwards.
+func (eq *eventQueue) close() {
+	close(eq.events)
+}
+
+// DefaultEventStreamSize is the number of events queued for each Events consumer when
+// no event queue is set with WithEventQueue.
+const DefaultEventStreamSize = 1024
+
+// eventStreams keeps the channels returned by Events, shared by the successive
+// connections of a FSock.
+type eventStreams struct {
+	mu     sync.RWMutex
+	subs   map[*eventStream]struct{}
+	size   int            // events queued for each consumer, DefaultEventStreamSize if 0
+	policy OverflowPolicy // fate of the events received while a consumer queue is full
+}
+
+// eventStream is one Events consumer. The events are queued, so the reading is not held
+// up by a slow consumer, and forwarded in the order received by one goroutine.
+type eventStream struct {
+	names  []string // event names delivered, all if empty
+	ch     chan Event
+	ctx    context.Context
+	events chan string // events not forwarded yet
+	policy OverflowPolicy
+}
+
+// add registers a stream of the events named, closing its channel once ctx is done.
+func (ess *eventStreams) add(ctx context.Context, names []string) <-chan Event {
+	size := ess.size
+	if size <= 0 {
+		size = DefaultEventStreamSize
+	}
+	es := &eventStream{
+		names:  names,
+		ch:     make(chan Event),
+		ctx:    ctx,
+		events: make(chan string, size),
+		policy: ess.policy,
+	}
+	ess.mu.Lock()
+	if ess.subs == nil {
+		ess.subs = make(map[*eventStream]struct{})
+	}
+	ess.subs[es] = struct{}{}
+	ess.mu.Unlock()
+	context.AfterFunc(ctx, func() {
+		ess.mu.Lock()
+		delete(ess.subs, es)
+		ess.mu.Unlock()
+	})
+	go es.forward()
+	return es.ch
+}
+
+// matching returns the streams interested in eventName.
+func (ess *eventStreams) matching(eventName string) (streams []*eventStream) {
+	ess.mu.RLock()
+	defer ess.mu.RUnlock()
+	for es := range ess.subs {
+		if len(es.names) == 0 || slices.Contains(es.names, eventName) {
+			streams = append(streams, es)
+		}
+	}
+	return
+}
+
+// push queues the event for the consumer, overflowing as the policy says once the queue
+// is full, returning the event dropped if any. With OverflowBlock it waits for room, or
+// for the stream to be done.
+func (es *eventStream) push(event string) (dropped string, hasDropped bool) {
+	select {
+	case es.events <- event:
+		return
+	case <-es.ctx.Done():
+		return
+	default:
+	}
+	switch es.policy {
+	case OverflowDropNewest:
+		return event, true
+	case OverflowDropOldest:
+		select { // unless forwarded meanwhile
+		case dropped = <-es.events:
+			hasDropped = true
+		default:
+		}
+	}
+	select {
+	case es.events <- event:
+	case <-es.ctx.Done():
+	}
+	return
+}
+
+// forward hands the queued events to the consumer, in order, closing the channel once
+// the stream is done.
+func (es *eventStream) forward() {
+	defer close(es.ch)
+	for {
+		select {
+		case event := <-es.events:
+			select {
+			case es.ch <- NewEvent(event):
+			case <-es.ctx.Done():
+				return
+			}
+		case <-es.ctx.Done():
+			return
+		}
+	}
+}
+
+// DeadLetterSink receives the events which could not be handled, together with the reason:
+// ErrEventDropped for the ones dropped by the event queue, or the panic of their handler.
+type DeadLetterSink func(event string, reason error, connIdx int)
+
+// DeadLetterWriter returns a sink writing the events to w framed as FreeSWITCH sends
+// them, so they can be replayed later, the reason going into a Dead-Letter-Reason header.
+// The writes are serialized, w needs not be safe for concurrent use.
+func DeadLetterWriter(w io.Writer) DeadLetterSink {
+	var mu sync.Mutex
+	return func(event string, reason error, connIdx int) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "Content-Length: %d\nContent-Type: text/event-plain\nDead-Letter-Reason: %s\nDead-Letter-Conn-Idx: %d\n\n%s",
+			len(event), strings.ReplaceAll(reason.Error(), "\n", " "), connIdx, event)
+	}
+}
+
+// SequenceGapHandler is notified when the Event-Sequence jumps from last to seq,
+// seq-last-1 events being missed.
+type SequenceGapHandler func(last, seq uint64, connIdx int)
+
+// sequenceTracker follows the Event-Sequence of the received events, shared by the
+// successive connections of a FSock so the events lost while reconnecting are noticed.
+type sequenceTracker struct {
+	mu      sync.Mutex
+	last    uint64 // 0 until the first event
+	handler SequenceGapHandler
+}
+
+// track records the sequence of event, returning whether it left a gap after the previous one.
+// A lower sequence, FreeSWITCH restarted, starts the tracking over.
+func (st *sequenceTracker) track(event string) (last, seq uint64, gap bool) {
+	seq, err := strconv.ParseUint(headerVal(event, "Event-Sequence"), 10, 64)
+	if err != nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	last, st.last = st.last, seq
+	return last, seq, last != 0 && seq > last+1
+}
+
+// EventLagHandler receives the lag of every event, the time passed between FreeSWITCH
+// firing it and its dispatching.
+type EventLagHandler func(lag time.Duration, eventName string, connIdx int)
+
+// lagMeter measures the event lag, shared by the successive connections of a FSock.
+type lagMeter struct {
+	last    atomic.Int64 // nanoseconds, lag of the last event
+	handler EventLagHandler
+}
+
+// measure records the lag of event at the received time, returning false if it has no timestamp.
+func (lm *lagMeter) measure(event string, received time.Time) (time.Duration, bool) {
+	fired := parseEventTimestamp(headerVal(event, "Event-Date-Timestamp"))
+	if fired.IsZero() {
+		return 0, false
+	}
+	lag := received.Sub(fired)
+	lm.last.Store(int64(lag))
+	return lag, true
+}
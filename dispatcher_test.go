@@ -0,0 +1,141 @@
+/*
+dispatcher_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolQueue(t *testing.T) {
+	wp := newWorkerPool(1, 2)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	wp.submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	done := make(chan int, 2)
+	for i := range 2 {
+		wp.submit(func() { done <- i })
+	}
+	if rcv := wp.depth(); rcv != 2 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 2, rcv)
+	}
+	close(release)
+	wp.stop()
+	for i := range 2 {
+		select {
+		case rcv := <-done:
+			if rcv != i {
+				t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", i, rcv)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("queued tasks not run after stop")
+		}
+	}
+}
+
+func TestEventQueueOverflow(t *testing.T) {
+	eq := newEventQueue(2, OverflowDropOldest)
+	for _, event := range []string{"ev1", "ev2"} {
+		if _, hasDropped := eq.push(event); hasDropped {
+			t.Errorf("unexpected drop of %s", event)
+		}
+	}
+	if dropped, _ := eq.push("ev3"); dropped != "ev1" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "ev1", dropped)
+	}
+	eq.policy = OverflowDropNewest
+	if dropped, _ := eq.push("ev4"); dropped != "ev4" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "ev4", dropped)
+	}
+	eq.close()
+	var rcv []string
+	for event := range eq.events {
+		rcv = append(rcv, event)
+	}
+	if exp := []string{"ev2", "ev3"}; !slices.Equal(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+	if dropped := eq.dropped.Load(); dropped != 2 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 2, dropped)
+	}
+}
+
+func TestEventStreamOverflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	es := &eventStream{ctx: ctx, events: make(chan string, 2), policy: OverflowDropOldest}
+	for _, event := range []string{"ev1", "ev2"} {
+		if _, hasDropped := es.push(event); hasDropped {
+			t.Errorf("unexpected drop of %s", event)
+		}
+	}
+	if dropped, _ := es.push("ev3"); dropped != "ev1" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "ev1", dropped)
+	}
+	es.policy = OverflowDropNewest
+	if dropped, _ := es.push("ev4"); dropped != "ev4" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "ev4", dropped)
+	}
+	es.policy = OverflowBlock
+	cancel()
+	pushed := make(chan struct{})
+	go func() { // the consumer gone, not waiting for room
+		es.push("ev5")
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push blocked on the full queue of a done stream")
+	}
+	close(es.events)
+	var rcv []string
+	for event := range es.events {
+		rcv = append(rcv, event)
+	}
+	if exp := []string{"ev2", "ev3"}; !slices.Equal(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+}
+
+func TestDeadLetterWriter(t *testing.T) {
+	var buf bytes.Buffer
+	sink := DeadLetterWriter(&buf)
+	sink("Event-Name: CHANNEL_ANSWER\n\n", ErrEventDropped, 3)
+	exp := "Content-Length: 28\nContent-Type: text/event-plain\n" +
+		"Dead-Letter-Reason: event dropped, event queue full\nDead-Letter-Conn-Idx: 3\n\n" +
+		"Event-Name: CHANNEL_ANSWER\n\n"
+	if rcv := buf.String(); rcv != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, rcv)
+	}
+}
+
+func TestSequenceTrackerTrack(t *testing.T) {
+	st := new(sequenceTracker)
+	for _, tc := range []struct {
+		event string
+		last  uint64
+		seq   uint64
+		gap   bool
+	}{
+		{event: "Event-Name: HEARTBEAT\nEvent-Sequence: 10\n", last: 0, seq: 10},
+		{event: "Event-Name: HEARTBEAT\nEvent-Sequence: 11\n", last: 10, seq: 11},
+		{event: "Event-Name: HEARTBEAT\nEvent-Sequence: 15\n", last: 11, seq: 15, gap: true},
+		{event: "Event-Name: HEARTBEAT\nEvent-Sequence: 2\n", last: 15, seq: 2}, // FreeSWITCH restarted
+		{event: "Event-Name: HEARTBEAT\n"},
+	} {
+		if last, seq, gap := st.track(tc.event); last != tc.last || seq != tc.seq || gap != tc.gap {
+			t.Errorf("\nExpected: <%d %d %t>, \nReceived: <%d %d %t>", tc.last, tc.seq, tc.gap, last, seq, gap)
+		}
+	}
+}
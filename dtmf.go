@@ -0,0 +1,61 @@
+/*
+dtmf.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// dtmfSampleRate is the rate DTMF-Duration is expressed at, in samples per second.
+const dtmfSampleRate = 8000
+
+// DTMF is a digit received on a channel.
+type DTMF struct {
+	Digit    string
+	Duration time.Duration
+	Source   string // e.g. RTP, INBAND_AUDIO or APP
+}
+
+// SendDTMF sends the digits to the channel, each of them lasting duration, the
+// FreeSWITCH default if 0.
+func (cc *CallControl) SendDTMF(uuid, digits string, duration time.Duration) error {
+	if duration > 0 {
+		digits += "@" + strconv.FormatInt(duration.Milliseconds(), 10)
+	}
+	return cc.uuidCmd("uuid_send_dtmf", uuid, digits)
+}
+
+// DTMF returns a channel receiving the digits of the channel with the given uuid, out
+// of the DTMF events, which must be subscribed to, e.g. with an event handler. The
+// digits are received in the order pressed, whatever the dispatch mode, see Events.
+// The channel is closed once ctx is done.
+func (fs *FSock) DTMF(ctx context.Context, uuid string) <-chan DTMF {
+	events := fs.Events(ctx, "DTMF")
+	digits := make(chan DTMF)
+	go func() {
+		defer close(digits)
+		for ev := range events {
+			if ev.UUID() != uuid {
+				continue
+			}
+			samples, _ := strconv.Atoi(ev.GetHeader("DTMF-Duration"))
+			select {
+			case digits <- DTMF{
+				Digit:    ev.GetHeader("DTMF-Digit"),
+				Duration: time.Duration(samples) * time.Second / dtmfSampleRate,
+				Source:   ev.GetHeader("DTMF-Source"),
+			}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return digits
+}
@@ -0,0 +1,102 @@
+/*
+dtmf_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestFSockDTMF(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithEventHandlers(map[string][]func(string, int){"DTMF": nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	if err = NewCallControl(fs).SendDTMF("3f1c7b2e", "12#", 250*time.Millisecond); err != nil {
+		t.Error(err)
+	}
+	if _, err = srv.WaitCommand("api uuid_send_dtmf 3f1c7b2e 12#@250", time.Second); err != nil {
+		t.Error(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	digits := fs.DTMF(ctx, "3f1c7b2e")
+	for _, uuid := range []string{"another", "3f1c7b2e"} {
+		if err = srv.SendEvent(map[string]string{
+			"Event-Name":    "DTMF",
+			"Unique-ID":     uuid,
+			"DTMF-Digit":    "5",
+			"DTMF-Duration": "2000",
+			"DTMF-Source":   "RTP",
+		}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp := DTMF{Digit: "5", Duration: 250 * time.Millisecond, Source: "RTP"}
+	select {
+	case dtmf := <-digits:
+		if dtmf != exp {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, dtmf)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no DTMF received")
+	}
+	cancel()
+	select {
+	case _, open := <-digits:
+		if open {
+			t.Error("unexpected DTMF")
+		}
+	case <-time.After(time.Second):
+		t.Error("DTMF channel not closed")
+	}
+}
+
+func TestFSockDTMFOrdered(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithEventHandlers(map[string][]func(string, int){"DTMF": nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	digits := fs.DTMF(ctx, "3f1c7b2e")
+	const exp = "1234567890*#"
+	for _, digit := range exp {
+		if err = srv.SendEvent(map[string]string{
+			"Event-Name":    "DTMF",
+			"Unique-ID":     "3f1c7b2e",
+			"DTMF-Digit":    string(digit),
+			"DTMF-Duration": "2000",
+			"DTMF-Source":   "RTP",
+		}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var rcv string
+	for len(rcv) < len(exp) {
+		select {
+		case dtmf := <-digits:
+			rcv += dtmf.Digit
+		case <-time.After(time.Second):
+			t.Fatalf("received only: <%s>", rcv)
+		}
+	}
+	if rcv != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+dump.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ParseUUIDDump parses the output of uuid_dump, either plain or as json, into an Event
+// holding the channel headers and its variables, the latter prefixed with variable_.
+// The opts apply to the plain dumps only, the json ones carrying their values decoded.
+func ParseUUIDDump(dump string, opts ...ParseOption) (Event, error) {
+	if strings.HasPrefix(strings.TrimSpace(dump), "{") {
+		var hdrs map[string]string
+		if err := json.Unmarshal([]byte(dump), &hdrs); err != nil {
+			return Event{}, fmt.Errorf("unexpected uuid_dump received: <%v>", err)
+		}
+		return Event{Headers: hdrs, Raw: dump}, nil
+	}
+	ev := NewEvent(dump, opts...)
+	if len(ev.Headers) == 0 {
+		return Event{}, fmt.Errorf("unexpected uuid_dump received: <%s>", strings.TrimSpace(dump))
+	}
+	return ev, nil
+}
+
+// UUIDDump returns the headers and the variables of the channel with the given uuid,
+// out of the plain uuid_dump.
+func (fs *FSock) UUIDDump(uuid string, opts ...ParseOption) (Event, error) {
+	return fs.uuidDump(uuid, "", opts)
+}
+
+// UUIDDumpJSON is the same as UUIDDump, requesting the dump as json, which spares the
+// URL decoding of the values.
+func (fs *FSock) UUIDDumpJSON(uuid string) (Event, error) {
+	return fs.uuidDump(uuid, "json", nil)
+}
+
+// uuidDump runs uuid_dump in the given format, parsing its output.
+func (fs *FSock) uuidDump(uuid, format string, opts []ParseOption) (Event, error) {
+	args := []string{uuid}
+	if format != "" {
+		args = append(args, format)
+	}
+	cmd, err := ApiCmd("uuid_dump", args...)
+	if err != nil {
+		return Event{}, err
+	}
+	dump, err := fs.SendApiCmd(cmd)
+	if err != nil {
+		return Event{}, err
+	}
+	return ParseUUIDDump(dump, opts...)
+}
@@ -0,0 +1,72 @@
+/*
+dump_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestParseUUIDDump(t *testing.T) {
+	plain := "Event-Name: CHANNEL_DATA\nUnique-ID: 0a30dd7c-c222-482f-a322-b1218a15f8cd\n" +
+		"Caller-Caller-ID-Name: Extension%201001\nvariable_sip_from_user: 1001\n"
+	exp := map[string]string{
+		"Event-Name":             "CHANNEL_DATA",
+		"Unique-ID":              "0a30dd7c-c222-482f-a322-b1218a15f8cd",
+		"Caller-Caller-ID-Name":  "Extension 1001",
+		"variable_sip_from_user": "1001",
+	}
+	ev, err := ParseUUIDDump(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ev.Headers, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, ev.Headers)
+	}
+	if ev.UUID() != "0a30dd7c-c222-482f-a322-b1218a15f8cd" || ev.GetVariable("sip_from_user") != "1001" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	json := `{"Event-Name":"CHANNEL_DATA","Unique-ID":"0a30dd7c-c222-482f-a322-b1218a15f8cd",` +
+		`"Caller-Caller-ID-Name":"Extension 1001","variable_sip_from_user":"1001"}`
+	if ev, err = ParseUUIDDump(json); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ev.Headers, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, ev.Headers)
+	}
+
+	if _, err = ParseUUIDDump("{not json"); err == nil {
+		t.Error("expected error")
+	}
+	if _, err = ParseUUIDDump("\n"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestFSockUUIDDump(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.Stub("api uuid_dump", "-ERR No such channel!\n")
+	srv.Stub("api uuid_dump 0a30dd7c-c222-482f-a322-b1218a15f8cd json",
+		`{"Event-Name":"CHANNEL_DATA","variable_sip_from_user":"1001"}`)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if ev, err := fs.UUIDDumpJSON("0a30dd7c-c222-482f-a322-b1218a15f8cd"); err != nil {
+		t.Error(err)
+	} else if ev.GetVariable("sip_from_user") != "1001" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	var apiErr *APIError
+	if _, err := fs.UUIDDump("unknown"); !errors.As(err, &apiErr) {
+		t.Errorf("expected APIError, received: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+escape.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsafeArg is returned for the values which would break out of the command they are
+// interpolated into, e.g. a caller ID carrying a newline followed by another command.
+var ErrUnsafeArg = errors.New("unsafe command argument")
+
+// CheckArg returns ErrUnsafeArg if arg contains a line break or a NUL, which FreeSWITCH
+// would take as the end of the command.
+func CheckArg(arg string) error {
+	if strings.ContainsAny(arg, "\r\n\x00") {
+		return fmt.Errorf("%w: %q", ErrUnsafeArg, arg)
+	}
+	return nil
+}
+
+// QuoteArg checks arg, then encloses it within single quotes so FreeSWITCH takes it as a
+// single argument of an api command or application, spaces included. The quotes and the
+// backslashes inside are escaped.
+func QuoteArg(arg string) (string, error) {
+	if err := CheckArg(arg); err != nil {
+		return "", err
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(arg) + "'", nil
+}
+
+// ApiCmd builds the api command cmd out of its args, e.g. ApiCmd("uuid_kill", uuid, cause),
+// to be sent with SendApiCmd or SendBgapiCmd. The args are checked, the empty ones and
+// the ones containing spaces, quotes or backslashes being quoted with QuoteArg.
+func ApiCmd(cmd string, args ...string) (string, error) {
+	if err := CheckArg(cmd); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(cmd)
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t'\"\\") {
+			var err error
+			if arg, err = QuoteArg(arg); err != nil {
+				return "", err
+			}
+		} else if err := CheckArg(arg); err != nil {
+			return "", err
+		}
+		sb.WriteString(" " + arg)
+	}
+	return sb.String(), nil
+}
+
+// CheckHeaders returns ErrUnsafeArg if the headers given to sendmsg or sendevent would
+// alter the command: empty names or containing colons or whitespace, values containing
+// line breaks.
+func CheckHeaders(hdrs map[string]string) error {
+	for name, val := range hdrs {
+		if name == "" || strings.ContainsAny(name, ": \t\r\n\x00") {
+			return fmt.Errorf("%w: header name %q", ErrUnsafeArg, name)
+		}
+		if err := CheckArg(val); err != nil {
+			return fmt.Errorf("%w, header %s", err, name)
+		}
+	}
+	return nil
+}
+
+// checkCmd checks the api command, ignoring the line breaks ending it.
+func checkCmd(cmdStr string) error {
+	return CheckArg(strings.TrimRight(cmdStr, "\r\n"))
+}
@@ -0,0 +1,108 @@
+/*
+escape_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApiCmd(t *testing.T) {
+	for _, tc := range []struct {
+		cmd  string
+		args []string
+		exp  string
+		err  bool
+	}{
+		{cmd: "uuid_kill", args: []string{"1234", "NORMAL_CLEARING"}, exp: "uuid_kill 1234 NORMAL_CLEARING"},
+		{cmd: "uuid_setvar", args: []string{"1234", "caller", "John O'Neil"}, exp: `uuid_setvar 1234 caller 'John O\'Neil'`},
+		{cmd: "uuid_setvar", args: []string{"1234", "path", `C:\tmp`}, exp: `uuid_setvar 1234 path 'C:\\tmp'`},
+		{cmd: "uuid_setvar", args: []string{"1234", "empty", ""}, exp: "uuid_setvar 1234 empty ''"},
+		{cmd: "uuid_setvar", args: []string{"1234", "caller", "John\n\napi shutdown"}, err: true},
+		{cmd: "status\nshutdown", err: true},
+	} {
+		rcv, err := ApiCmd(tc.cmd, tc.args...)
+		if tc.err {
+			if !errors.Is(err, ErrUnsafeArg) {
+				t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(err)
+		} else if rcv != tc.exp {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", tc.exp, rcv)
+		}
+	}
+}
+
+func TestCheckHeaders(t *testing.T) {
+	if err := CheckHeaders(map[string]string{"call-command": "execute", "execute-app-arg": "John Doe"}); err != nil {
+		t.Error(err)
+	}
+	for _, hdrs := range []map[string]string{
+		{"execute-app-arg": "John\n\nsendmsg"},
+		{"call-command\nexecute-app-name": "hangup"},
+		{"event-lock:": "true"},
+		{"": "true"},
+	} {
+		if err := CheckHeaders(hdrs); !errors.Is(err, ErrUnsafeArg) {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+		}
+	}
+}
+
+func TestFSockSendUnsafeCmd(t *testing.T) {
+	fs := &FSock{}
+	if _, err := fs.SendApiCmd("uuid_setvar 1234 caller John\n\napi shutdown"); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+	}
+	if err := fs.SendMsgCmd("1234", map[string]string{"execute-app-arg": "John\n\napi shutdown"}); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+	}
+}
+
+func TestFSockUnsafeFilter(t *testing.T) {
+	fs := newFSock("127.0.0.1:1", "ClueCon")
+	if err := fs.AddFilter("Event-Name", "CHANNEL_ANSWER\n\napi shutdown"); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+	}
+	if err := fs.AddFilter("Unique-ID\n\napi shutdown", "uuid1"); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+	}
+	if err := fs.DeleteFilter("Event-Name", "CHANNEL_ANSWER\n\napi shutdown"); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+	}
+	if len(fs.eventFilters) != 0 { // not replayed on reconnects either
+		t.Errorf("expected no filters kept, received: %+v", fs.eventFilters)
+	}
+}
+
+func TestFSockUnsafeMyEvents(t *testing.T) {
+	fs := newFSock("127.0.0.1:1", "ClueCon")
+	if err := fs.SubscribeMyEvents("uuid1\n\napi shutdown", func(string, int) {}); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+	}
+	if fs.myEventsUUID != "" {
+		t.Errorf("expected no subscription kept, received: %q", fs.myEventsUUID)
+	}
+}
+
+func TestFSockSubscribeLogLevel(t *testing.T) {
+	fs := newFSock("127.0.0.1:1", "ClueCon")
+	for _, level := range []string{"debug\n\napi shutdown", "verbose", "8", "-1", ""} {
+		if err := fs.SubscribeLog(level, func(string, int) {}); err == nil ||
+			err.Error() != "unsupported log level: <"+level+">" {
+			t.Errorf("expected level %q refused, received: %v", level, err)
+		}
+	}
+	for _, level := range []string{"DEBUG", "warning", "0", "7"} {
+		if !isLogLevel(level) {
+			t.Errorf("expected level %q accepted", level)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+event.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats the events can be subscribed in.
+const (
+	EventFormatPlain = "plain"
+	EventFormatXML   = "xml"
+)
+
+// ParseOption alters the parsing of the events by EventToMap, EventToMultiMap, ParseHeaders,
+// FSEventStrToMap, NewEvent and NewLazyEvent.
+type ParseOption func(*parseOptions)
+
+// parseOptions gathers the ParseOptions of one call, the zero value standing for the defaults.
+type parseOptions struct {
+	rawValues bool // keeps the header values URL encoded
+}
+
+// RawValues keeps the header values exactly as received, URL encoded, e.g. to re-emit or
+// hash them.
+func RawValues() ParseOption {
+	return func(po *parseOptions) { po.rawValues = true }
+}
+
+// newParseOptions applies the opts over the defaults.
+func newParseOptions(opts []ParseOption) (po parseOptions) {
+	for _, opt := range opts {
+		opt(&po)
+	}
+	return
+}
+
+// decode returns the header value as configured, URL decoded by default.
+func (po parseOptions) decode(hdrVal string) string {
+	if po.rawValues {
+		return hdrVal
+	}
+	return urlDecode(hdrVal)
+}
+
+// Event is a FreeSWITCH event parsed out of its plain text form.
+type Event struct {
+	Headers map[string]string // Event headers, URL decoded unless parsed with RawValues
+	Body    string            // Body of the event, if any
+	Raw     string            // Event as received from FreeSWITCH
+}
+
+// NewEvent parses a plain text event.
+func NewEvent(raw string, opts ...ParseOption) Event {
+	hdrs := EventToMap(raw, opts...)
+	body := hdrs[EventBodyTag]
+	delete(hdrs, EventBodyTag)
+	return Event{
+		Headers: hdrs,
+		Body:    body,
+		Raw:     raw,
+	}
+}
+
+// EventHandler adapts a handler of typed events to the plain handlers signature,
+// so it can be registered within the event handlers.
+func EventHandler(handler func(Event, int)) func(string, int) {
+	return func(event string, connIdx int) {
+		handler(NewEvent(event), connIdx)
+	}
+}
+
+// GetHeader returns the value of the header, empty if missing.
+func (ev Event) GetHeader(name string) string {
+	return ev.Headers[name]
+}
+
+// GetVariable returns the value of the channel variable, empty if missing.
+func (ev Event) GetVariable(name string) string {
+	return ev.Headers["variable_"+name]
+}
+
+// Name returns the Event-Name header.
+func (ev Event) Name() string {
+	return ev.Headers["Event-Name"]
+}
+
+// Subclass returns the Event-Subclass header, set on CUSTOM events.
+func (ev Event) Subclass() string {
+	return ev.Headers["Event-Subclass"]
+}
+
+// UUID returns the Unique-ID of the channel the event refers to.
+func (ev Event) UUID() string {
+	return ev.Headers["Unique-ID"]
+}
+
+// Timestamp returns the time the event was fired at, based on the Event-Date-Timestamp
+// header. The zero time is returned if the header is missing or invalid.
+func (ev Event) Timestamp() time.Time {
+	return parseEventTimestamp(ev.Headers["Event-Date-Timestamp"])
+}
+
+// parseEventTimestamp converts an Event-Date-Timestamp value, in microseconds since epoch,
+// returning the zero time if invalid.
+func parseEventTimestamp(usecStr string) time.Time {
+	usec, err := strconv.ParseInt(usecStr, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMicro(usec)
+}
+
+// xmlEventToPlain converts an XML event into its plain text form, URL-encoding the
+// header values the way FreeSWITCH does for plain events.
+func xmlEventToPlain(xmlEvent string) (string, error) {
+	var ev struct {
+		Headers struct {
+			Headers []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"headers"`
+		Body string `xml:"body"`
+	}
+	if err := xml.NewDecoder(strings.NewReader(xmlEvent)).Decode(&ev); err != nil {
+		if err == io.EOF {
+			err = errors.New("empty XML event")
+		}
+		return "", err
+	}
+	var sb strings.Builder
+	for _, hdr := range ev.Headers.Headers {
+		sb.WriteString(hdr.XMLName.Local + ": " + url.QueryEscape(hdr.Value) + "\n")
+	}
+	if ev.Body != "" {
+		sb.WriteString("Content-Length: " + strconv.Itoa(len(ev.Body)) + "\n\n" + ev.Body)
+	}
+	return sb.String(), nil
+}
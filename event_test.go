@@ -0,0 +1,163 @@
+/*
+event_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventAccessors(t *testing.T) {
+	raw := "Event-Name: CHANNEL_ANSWER\nUnique-ID: 4967ceb1-c6f9-4af9-9855-df323d6763ad\n" +
+		"Event-Date-Timestamp: 1703257952506074\nvariable_sip_from_user: 1001\n" +
+		"Caller-Channel-Name: sofia/internal/1001%40192.168.56.120%3A5081\n\nsome body"
+	ev := NewEvent(raw)
+	if ev.Raw != raw {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", raw, ev.Raw)
+	}
+	if ev.Body != "some body" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "some body", ev.Body)
+	}
+	if _, has := ev.Headers[EventBodyTag]; has {
+		t.Errorf("body should not be part of the headers: %+v", ev.Headers)
+	}
+	if ev.Name() != "CHANNEL_ANSWER" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "CHANNEL_ANSWER", ev.Name())
+	}
+	if ev.UUID() != "4967ceb1-c6f9-4af9-9855-df323d6763ad" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "4967ceb1-c6f9-4af9-9855-df323d6763ad", ev.UUID())
+	}
+	if ev.GetVariable("sip_from_user") != "1001" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "1001", ev.GetVariable("sip_from_user"))
+	}
+	if exp := "sofia/internal/1001@192.168.56.120:5081"; ev.GetHeader("Caller-Channel-Name") != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, ev.GetHeader("Caller-Channel-Name"))
+	}
+	if exp := time.UnixMicro(1703257952506074); !ev.Timestamp().Equal(exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, ev.Timestamp())
+	}
+	if ts := NewEvent("Event-Name: HEARTBEAT\n").Timestamp(); !ts.IsZero() {
+		t.Errorf("expected zero timestamp, received: %v", ts)
+	}
+}
+
+func TestEventTypedHandlers(t *testing.T) {
+	received := make(chan Event, 1)
+	fs := newFSock("", "", WithEventHandlers(map[string][]func(string, int){
+		"HEARTBEAT": {func(string, int) {}},
+	}), WithTypedEventHandlers(map[string][]func(Event, int){
+		"HEARTBEAT": {func(ev Event, _ int) { received <- ev }},
+	}))
+	if len(fs.eventHandlers["HEARTBEAT"]) != 2 {
+		t.Fatalf("expected both handlers registered, received: %d", len(fs.eventHandlers["HEARTBEAT"]))
+	}
+	fs.eventHandlers["HEARTBEAT"][1]("Event-Name: HEARTBEAT\nEvent-Sequence: 5\n", 0)
+	if ev := <-received; ev.GetHeader("Event-Sequence") != "5" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "5", ev.GetHeader("Event-Sequence"))
+	}
+}
+
+func TestEventXMLToPlain(t *testing.T) {
+	xmlEv := `<event>
+  <headers>
+    <Event-Name>BACKGROUND_JOB</Event-Name>
+    <Job-UUID>7f4db78a-17d7-11dd-b7a0-db4edd065621</Job-UUID>
+    <Job-Command>originate</Job-Command>
+    <Job-Command-Arg>sofia/default/1005%20&amp;park</Job-Command-Arg>
+  </headers>
+  <body>+OK 7f4de4bc-17d7-11dd-b7a0-db4edd065621
+</body>
+</event>`
+	plain, err := xmlEventToPlain(xmlEv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evMap := EventToMap(plain)
+	exp := map[string]string{
+		"Event-Name":      "BACKGROUND_JOB",
+		"Job-UUID":        "7f4db78a-17d7-11dd-b7a0-db4edd065621",
+		"Job-Command":     "originate",
+		"Job-Command-Arg": "sofia/default/1005%20&park",
+		"Content-Length":  "41",
+		EventBodyTag:      "+OK 7f4de4bc-17d7-11dd-b7a0-db4edd065621\n",
+	}
+	if !reflect.DeepEqual(exp, evMap) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, evMap)
+	}
+	if _, err := xmlEventToPlain(""); err == nil {
+		t.Error("expected error for empty XML event")
+	}
+}
+
+func TestEventXMLDispatch(t *testing.T) {
+	body := "<event><headers><Event-Name>HEARTBEAT</Event-Name><Up-Time>0 years, 0 days</Up-Time></headers></event>"
+	received := make(chan Event, 1)
+	fs := &FSConn{
+		lgr: nopLogger{},
+		rdr: bufio.NewReader(strings.NewReader(fmt.Sprintf(
+			"Content-Length: %d\nContent-Type: text/event-xml\n\n%s", len(body), body))),
+		conn: new(connMock3),
+		err:  make(chan error, 1),
+		eventHandlers: map[string][]func(string, int){
+			"HEARTBEAT": {EventHandler(func(ev Event, _ int) { received <- ev })},
+		},
+	}
+	fs.readEvents()
+	select {
+	case ev := <-received:
+		if ev.GetHeader("Up-Time") != "0 years, 0 days" {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "0 years, 0 days", ev.GetHeader("Up-Time"))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("XML event was not dispatched")
+	}
+}
+
+func TestEventXMLSubscribe(t *testing.T) {
+	buf := new(bytes.Buffer)
+	fs := &FSConn{
+		conn:        &connMock2{buf: buf},
+		rdr:         bufio.NewReader(strings.NewReader("Reply-Text: +OK\n\n")),
+		lgr:         nopLogger{},
+		connOptions: connOptions{eventFormat: EventFormatXML},
+	}
+	if err := fs.eventsPlain([]string{"HEARTBEAT"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "event xml HEARTBEAT BACKGROUND_JOB\n\n"; buf.String() != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, buf.String())
+	}
+}
+
+func TestEventRawValues(t *testing.T) {
+	raw := "Event-Name: CHANNEL_ANSWER\nCaller-Channel-Name: sofia/internal/1001%40192.168.56.120%3A5081\n\n"
+	decoded, encoded := "sofia/internal/1001@192.168.56.120:5081", "sofia/internal/1001%40192.168.56.120%3A5081"
+	if rcv := EventToMap(raw)["Caller-Channel-Name"]; rcv != decoded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", decoded, rcv)
+	}
+	if rcv := EventToMap(raw, RawValues())["Caller-Channel-Name"]; rcv != encoded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", encoded, rcv)
+	}
+	if rcv := FSEventStrToMap(raw, nil, RawValues())["Caller-Channel-Name"]; rcv != encoded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", encoded, rcv)
+	}
+	if rcv := NewEvent(raw, RawValues()).GetHeader("Caller-Channel-Name"); rcv != encoded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", encoded, rcv)
+	}
+	lazy := NewLazyEvent(raw, RawValues())
+	if rcv := lazy.GetHeader("Caller-Channel-Name"); rcv != encoded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", encoded, rcv)
+	}
+	if rcv := lazy.Event().GetHeader("Caller-Channel-Name"); rcv != encoded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", encoded, rcv)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+execute.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"fmt"
+)
+
+// AppResult is the outcome of an application executed with ExecuteApp.
+type AppResult struct {
+	Response string // Application-Response, e.g. FILE PLAYED, _none_ for the applications setting none
+	Event    Event  // the CHANNEL_EXECUTE_COMPLETE event
+}
+
+// ExecuteApp executes the dialplan application app with args on the channel with the
+// given uuid, through sendmsg, and waits for its CHANNEL_EXECUTE_COMPLETE, matched by
+// Application-UUID. The CHANNEL_EXECUTE_COMPLETE events must be subscribed to, e.g. with
+// an event handler, and not filtered out.
+func (fs *FSock) ExecuteApp(ctx context.Context, uuid, app, args string) (AppResult, error) {
+	fsConn, err := fs.activeConn(ctx)
+	if err != nil {
+		return AppResult{}, err
+	}
+	return fsConn.ExecuteApp(ctx, uuid, app, args)
+}
+
+// ExecuteApp is the same as FSock.ExecuteApp, returning ErrNotConnected if the
+// connection is lost before the application completes.
+func (fsConn *FSConn) ExecuteApp(ctx context.Context, uuid, app, args string) (AppResult, error) {
+	return fsConn.executeApp(ctx, uuid, app, args, false)
+}
+
+// executeApp implements ExecuteApp, sending event-lock with lock set so the application
+// waits for the ones sent before it.
+func (fsConn *FSConn) executeApp(ctx context.Context, uuid, app, args string, lock bool) (AppResult, error) {
+	appUUID := genUUID()
+	msg, err := executeMsg(uuid, app, args, appUUID, lock)
+	if err != nil {
+		return AppResult{}, err
+	}
+	done := make(chan string, 1)
+	fsConn.execsMux.Lock()
+	if fsConn.execs == nil {
+		fsConn.execs = make(map[string]chan string)
+	}
+	fsConn.execs[appUUID] = done
+	fsConn.execsMux.Unlock()
+	defer fsConn.takeExecution(appUUID)
+
+	if _, err = fsConn.SendCtx(ctx, msg); err != nil {
+		return AppResult{}, err
+	}
+	var connDone <-chan struct{}
+	if fsConn.ctx != nil {
+		connDone = fsConn.ctx.Done()
+	}
+	select {
+	case event := <-done:
+		ev := NewEvent(event)
+		return AppResult{Response: ev.GetHeader("Application-Response"), Event: ev}, nil
+	case <-ctx.Done():
+		return AppResult{}, ctx.Err()
+	case <-connDone:
+		return AppResult{}, ErrNotConnected
+	}
+}
+
+// executeMsg composes the sendmsg executing app with args, identified by appUUID.
+func executeMsg(uuid, app, args, appUUID string, lock bool) (string, error) {
+	for _, arg := range []string{uuid, app, args} {
+		if err := CheckArg(arg); err != nil {
+			return "", err
+		}
+	}
+	msg := fmt.Sprintf("sendmsg %s\ncall-command: execute\nexecute-app-name: %s\nexecute-app-arg: %s\nEvent-UUID: %s\n",
+		uuid, app, args, appUUID)
+	if lock {
+		msg += "event-lock: true\n"
+	}
+	return msg + "\n", nil
+}
+
+// takeExecution removes the application out of the ones awaiting their completion.
+func (fsConn *FSConn) takeExecution(appUUID string) (done chan string, has bool) {
+	fsConn.execsMux.Lock()
+	defer fsConn.execsMux.Unlock()
+	if done, has = fsConn.execs[appUUID]; has {
+		delete(fsConn.execs, appUUID)
+	}
+	return
+}
+
+// completeExecution hands the CHANNEL_EXECUTE_COMPLETE to the ExecuteApp awaiting it,
+// telling if there was one.
+func (fsConn *FSConn) completeExecution(event string) bool {
+	done, has := fsConn.takeExecution(headerVal(event, "Application-UUID"))
+	if has {
+		done <- event
+	}
+	return has
+}
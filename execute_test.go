@@ -0,0 +1,80 @@
+/*
+execute_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestFSockExecuteApp(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithEventHandlers(map[string][]func(string, int){"CHANNEL_EXECUTE_COMPLETE": nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	type result struct {
+		res AppResult
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		res, err := fs.ExecuteApp(context.Background(), "3f1c7b2e", "playback", "ivr/welcome.wav")
+		results <- result{res, err}
+	}()
+	cmd, err := srv.WaitCommand("sendmsg 3f1c7b2e", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app := HeaderValue(cmd, "execute-app-name"); app != "playback" {
+		t.Errorf("unexpected application: %q", app)
+	}
+	if err = srv.SendEvent(map[string]string{
+		"Event-Name":           "CHANNEL_EXECUTE_COMPLETE",
+		"Unique-ID":            "3f1c7b2e",
+		"Application":          "playback",
+		"Application-UUID":     "another",
+		"Application-Response": "FILE NOT FOUND",
+	}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.SendEvent(map[string]string{
+		"Event-Name":           "CHANNEL_EXECUTE_COMPLETE",
+		"Unique-ID":            "3f1c7b2e",
+		"Application":          "playback",
+		"Application-UUID":     HeaderValue(cmd, "Event-UUID"),
+		"Application-Response": "FILE PLAYED",
+	}, ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Error(r.err)
+		} else if r.res.Response != "FILE PLAYED" || r.res.Event.GetHeader("Application") != "playback" {
+			t.Errorf("unexpected result: %+v", r.res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ExecuteApp did not return")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = fs.ExecuteApp(ctx, "3f1c7b2e", "park", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, received: %v", err)
+	}
+	if _, err = fs.ExecuteApp(context.Background(), "3f1c7b2e", "playback", "a\nb"); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("expected ErrUnsafeArg, received: %v", err)
+	}
+}
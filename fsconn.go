@@ -12,98 +12,394 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
-// NewFSConn constructs and connects a FSConn
+// NewFSConn constructs and connects a FSConn. Over TLS, hand a *tls.Conn to NewFSConnFromConn.
 func NewFSConn(addr, passwd string,
 	connIdx int,
 	replyTimeout time.Duration,
 	connErr chan error,
-	lgr logger,
+	lgr Logger,
 	evFilters map[string][]string,
 	eventHandlers map[string][]func(string, int),
 	bgapi bool,
 ) (*FSConn, error) {
+	return newFSConnCtx(context.Background(), addr, passwd, connIdx, replyTimeout, connErr, lgr,
+		evFilters, eventHandlers, nil, bgapi, nil, connOptions{})
+}
 
-	fsConn := &FSConn{
-		connIdx:       connIdx,
-		replyTimeout:  replyTimeout,
-		lgr:           lgr,
-		err:           connErr,
-		replies:       make(chan string),
-		eventHandlers: eventHandlers,
-		bgapiChan:     make(map[string]chan string),
-		bgapiMux:      new(sync.RWMutex),
-	}
+// newFSConnCtx constructs and connects a FSConn, aborting the dial and the handshake
+// once ctx is done.
+func newFSConnCtx(ctx context.Context, addr, passwd string,
+	connIdx int,
+	replyTimeout time.Duration,
+	connErr chan error,
+	lgr Logger,
+	evFilters map[string][]string,
+	eventHandlers map[string][]func(string, int),
+	ctxEventHandlers map[string][]EventHandlerCtx,
+	bgapi bool,
+	tlsConfig *tls.Config,
+	opts connOptions,
+) (*FSConn, error) {
 
 	// Build the TCP connection and the buffer reading it
-	var err error
-	if fsConn.conn, err = net.Dial("tcp", addr); err != nil {
-		fsConn.lgr.Err(fmt.Sprintf("<FSock> Attempt to connect to FreeSWITCH, received: %s", err.Error()))
+	dialCtx := ctx
+	if opts.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, opts.dialTimeout)
+		defer cancel()
+	}
+	conn, err := dial(dialCtx, addr, tlsConfig, opts.dialer())
+	if err != nil {
+		lgr.Err(fmt.Sprintf("<FSock> Attempt to connect to FreeSWITCH, received: %s", err.Error()))
 		return nil, err
 	}
-	fsConn.rdr = bufio.NewReaderSize(fsConn.conn, 8192) // reinit buffer
-	fsConn.lgr.Info("<FSock> Successfully connected to FreeSWITCH!")
+	if err = setKeepAlive(conn, opts.keepAlive); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set the TCP keepalive: %w", err)
+	}
+	lgr.Info("<FSock> Successfully connected to FreeSWITCH!")
+	return newFSConnFromConnCtx(ctx, conn, passwd, connIdx, replyTimeout, connErr, lgr,
+		evFilters, eventHandlers, ctxEventHandlers, bgapi, opts)
+}
 
-	// Connected, auth and subscribe to desired events and filters
-	var authChlng string
-	if authChlng, err = fsConn.readHeaders(); err != nil {
+// NewFSConnFromConn runs the auth, subscribe and read events pipeline over an already
+// established connection, e.g. one dialed through a proxy or a test pipe. The connection
+// is closed if the handshake fails.
+func NewFSConnFromConn(conn net.Conn, passwd string,
+	connIdx int,
+	replyTimeout time.Duration,
+	connErr chan error,
+	lgr Logger,
+	evFilters map[string][]string,
+	eventHandlers map[string][]func(string, int),
+	bgapi bool,
+) (*FSConn, error) {
+	return newFSConnFromConnCtx(context.Background(), conn, passwd, connIdx, replyTimeout, connErr, lgr,
+		evFilters, eventHandlers, nil, bgapi, connOptions{})
+}
+
+// newFSConnFromConnCtx is the context-aware version of NewFSConnFromConn, aborting the
+// handshake once ctx is done.
+func newFSConnFromConnCtx(ctx context.Context, conn net.Conn, passwd string,
+	connIdx int,
+	replyTimeout time.Duration,
+	connErr chan error,
+	lgr Logger,
+	evFilters map[string][]string,
+	eventHandlers map[string][]func(string, int),
+	ctxEventHandlers map[string][]EventHandlerCtx,
+	bgapi bool,
+	opts connOptions,
+) (*FSConn, error) {
+	fsConn := newFSConn(conn, connIdx, replyTimeout, connErr, lgr, eventHandlers, ctxEventHandlers)
+	fsConn.connOptions = opts
+	fsConn.ctx = context.WithValue(fsConn.ctx, connInfoKey{}, fsConn.ConnInfo())
+
+	// Connected, auth and subscribe to desired events and filters.
+	// Closing the connection unblocks the handshake if ctx is done meanwhile.
+	stopClosing := context.AfterFunc(ctx, func() { conn.Close() })
+	err := fsConn.handshake(passwd, evFilters, bgapi)
+	if !stopClosing() {
+		err = ctx.Err()
+		conn.Close()
+	}
+	if err != nil {
+		conn.Close()
+		fsConn.cancel()
 		return nil, err
 	}
 
-	if !strings.Contains(authChlng, "auth/request") {
-		fsConn.conn.Close()
-		return nil, errors.New("no auth challenge received")
+	if fsConn.pull {
+		return fsConn, nil // read by NextEvent
+	}
+	if fsConn.dispatchWorkers > 0 && !fsConn.syncDispatch {
+		fsConn.workers = newWorkerPool(fsConn.dispatchWorkers, fsConn.dispatchQueue)
+	}
+	if fsConn.eventQueueSize > 0 {
+		fsConn.queue = newEventQueue(fsConn.eventQueueSize, fsConn.overflowPolicy)
+		go fsConn.dispatchQueued()
+	}
+	fsConn.reading.Store(true)
+	go fsConn.readEvents() // Fork read events in it's own goroutine
+	if fsConn.heartbeatTimeout > 0 {
+		go fsConn.watchHeartbeat()
 	}
 
-	if err = fsConn.auth(passwd); err != nil { // Auth did not succeed
-		return nil, err
+	return fsConn, nil
+}
+
+// DialFunc establishes the connections to FreeSWITCH, e.g. (*net.Dialer).DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// lookupSRV resolves the SRV records, replaced by the tests.
+var lookupSRV = net.DefaultResolver.LookupSRV
+
+// dial connects to FreeSWITCH, over TLS if tlsConfig is provided, using dialFunc
+// if not nil. The address can also point to a unix socket or name SRV records, see
+// networkAddr. Being resolved on every dial, the DNS changes apply on the next reconnect.
+func dial(ctx context.Context, addr string, tlsConfig *tls.Config, dialFunc DialFunc) (net.Conn, error) {
+	network, address := networkAddr(addr)
+	if network == "srv" {
+		return dialSRV(ctx, address, tlsConfig, dialFunc)
 	}
+	return dialAddr(ctx, network, address, tlsConfig, dialFunc)
+}
 
-	if err = fsConn.filterEvents(evFilters, bgapi); err != nil {
+// dialSRV connects to the first target of the SRV records of name accepting the
+// connection, trying them in the order of their priority and weight.
+func dialSRV(ctx context.Context, name string, tlsConfig *tls.Config, dialFunc DialFunc) (net.Conn, error) {
+	_, srvs, err := lookupSRV(ctx, "", "", name)
+	if err != nil {
 		return nil, err
 	}
+	if len(srvs) == 0 {
+		return nil, fmt.Errorf("no SRV records found for <%s>", name)
+	}
+	for _, srv := range srvs {
+		var conn net.Conn
+		if conn, err = dialAddr(ctx, "tcp", net.JoinHostPort(strings.TrimSuffix(srv.Target, "."),
+			strconv.Itoa(int(srv.Port))), tlsConfig, dialFunc); err == nil || ctx.Err() != nil {
+			return conn, err
+		}
+	}
+	return nil, err // of the last target
+}
 
-	if err = fsConn.eventsPlain(getMapKeys(eventHandlers),
-		bgapi); err != nil {
+// setKeepAlive enables the TCP keepalive probes every period on conn, disabling them if
+// period is negative. Left as dialed if 0, or not a TCP connection, e.g. a unix socket.
+func setKeepAlive(conn net.Conn, period time.Duration) error {
+	if period == 0 {
+		return nil
+	}
+	for {
+		switch c := conn.(type) {
+		case *tls.Conn:
+			conn = c.NetConn()
+		case *bufferedConn:
+			conn = c.Conn
+		case *net.TCPConn:
+			if period < 0 {
+				return c.SetKeepAlive(false)
+			}
+			if err := c.SetKeepAlive(true); err != nil {
+				return err
+			}
+			return c.SetKeepAlivePeriod(period)
+		default:
+			return nil
+		}
+	}
+}
+
+// dialAddr connects to address over network, as described by dial.
+func dialAddr(ctx context.Context, network, address string, tlsConfig *tls.Config, dialFunc DialFunc) (net.Conn, error) {
+	if dialFunc == nil {
+		if tlsConfig != nil {
+			return (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, network, address)
+		}
+		return new(net.Dialer).DialContext(ctx, network, address)
+	}
+	conn, err := dialFunc(ctx, network, address)
+	if err != nil || tlsConfig == nil {
+		return conn, err
+	}
+	if tlsConfig.ServerName == "" && network == "tcp" { // as tls.Dialer does
+		tlsConfig = tlsConfig.Clone()
+		if tlsConfig.ServerName, _, err = net.SplitHostPort(address); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
 		return nil, err
 	}
+	return tlsConn, nil
+}
 
-	go fsConn.readEvents() // Fork read events in it's own goroutine
+// EventHandlerCtx is a context-aware event handler. The context is cancelled once the
+// connection that delivered the event is lost or shut down, while lgr carries the fields
+// of that connection, e.g. conn_idx, and takes the ones of the handler through With.
+// The plain Loggers render the fields as a prefix of the messages.
+type EventHandlerCtx func(ctx context.Context, lgr FieldLogger, event string, connIdx int)
 
-	return fsConn, nil
+// newFSConn wraps an established connection into a FSConn, without performing any handshake.
+func newFSConn(conn net.Conn, connIdx int, replyTimeout time.Duration, connErr chan error, lgr Logger,
+	eventHandlers map[string][]func(string, int), ctxEventHandlers map[string][]EventHandlerCtx) *FSConn {
+	fsConn := &FSConn{
+		connIdx:          connIdx,
+		conn:             conn,
+		rdr:              bufio.NewReaderSize(conn, 8192),
+		lgr:              lgr,
+		err:              connErr,
+		eventHandlers:    cloneMap(eventHandlers),
+		ctxEventHandlers: cloneMap(ctxEventHandlers),
+		bgapiChan:        make(map[string]*bgapiJob),
+		bgapiMux:         new(sync.RWMutex),
+	}
+	fsConn.replyTimeout.Store(int64(replyTimeout))
+	fsConn.ctx, fsConn.cancel = context.WithCancel(context.Background())
+	return fsConn
 }
 
 type FSConn struct {
-	connIdx       int                            // Identifier for the component using this instance of FSConn, optional
-	replyTimeout  time.Duration                  // Timeout for awaiting replies
-	conn          net.Conn                       // TCP connection to FreeSWITCH
-	rdr           *bufio.Reader                  // Reader for the TCP connection
-	lgr           logger                         // Logger for logging messages
-	err           chan error                     // Channel for reporting errors
-	replies       chan string                    // Channel for receiving replies
-	eventHandlers map[string][]func(string, int) // eventStr, connId, handles events
-	bgapiChan     map[string]chan string         // Channels used by bgapi
-	bgapiMux      *sync.RWMutex                  // Protects the bgapiChan map
+	connIdx          int                            // Identifier for the component using this instance of FSConn, optional
+	replyTimeout     atomic.Int64                   // Timeout for awaiting replies, in nanoseconds
+	conn             net.Conn                       // TCP connection to FreeSWITCH
+	rdr              *bufio.Reader                  // Reader for the TCP connection
+	hdrBuf           []byte                         // Scratch buffer the headers are read into, reused across messages
+	lgrMu            sync.RWMutex                   // Protects lgr, swapped by SetLogger
+	lgr              Logger                         // Logger for logging messages
+	err              chan error                     // Channel for reporting errors
+	sendMux          sync.Mutex                     // Keeps the waiters in the order the commands are written
+	repliesMux       sync.Mutex                     // Protects waiters and repliesDone
+	waiters          []*replyWaiter                 // Commands awaiting their replies, in the order sent
+	repliesDone      bool                           // Set once reading stopped, no more replies to come
+	eventHandlers    map[string][]func(string, int) // eventStr, connId, handles events
+	ctxEventHandlers map[string][]EventHandlerCtx   // Context-aware handlers, dispatched along eventHandlers
+	bgapiChan        map[string]*bgapiJob           // Jobs awaiting their bgapi result
+	bgapiMux         *sync.RWMutex                  // Protects the bgapiChan map
+	execsMux         sync.Mutex                     // Protects the execs map
+	execs            map[string]chan string         // Applications awaiting their CHANNEL_EXECUTE_COMPLETE, by Application-UUID
+	ctx              context.Context                // Handed to ctxEventHandlers, cancelled on connection loss
+	cancel           context.CancelFunc             // Cancels ctx
+	handlersMux      sync.RWMutex                   // Protects the handler maps, altered at runtime
+	myEventsUUID     string                         // Call leg subscribed to with myevents
+	myEventsHandler  func(string, int)              // Receives the events of myEventsUUID
+	logHandler       func(string, int)              // Receives the log/data payloads
+	paused           bool                           // Set by pauseEvents, no events subscribed meanwhile
+	outbound         bool                           // Accepted from FreeSWITCH, see FSockServer
+	disconnecting    atomic.Bool                    // Set while waiting for the lingered events
+	lastRead         atomic.Int64                   // Unix nanoseconds of the last message read, for the watchdog
+	stale            atomic.Bool                    // Set once the watchdog closed the connection
+	draining         atomic.Bool                    // Set by Shutdown, no more commands accepted
+	reading          atomic.Bool                    // Set while the events are read
+	inflight         atomic.Int64                   // Commands awaiting their replies
+	unhandled        atomic.Int64                   // Events read and not dispatched yet
+	running          atomic.Int64                   // Handlers started and not finished yet
+	replaced         atomic.Bool                    // Set once a failback replaced the connection
+	workers          *workerPool                    // Runs the handlers when dispatchWorkers is set
+	queue            *eventQueue                    // Buffers the events when eventQueueSize is set
+	connOptions                                     // Settings configurable through FSock Options
+}
+
+// connOptions gathers the FSConn settings configurable through the FSock Options.
+// The zero value stands for the defaults.
+type connOptions struct {
+	eventFormat      string                    // Format of the subscribed events, EventFormatPlain if empty
+	linger           int                       // Seconds FreeSWITCH keeps delivering the events after hangup, disabled if 0
+	bgapiJobTTL      time.Duration             // Expires the bgapi jobs not answered in time, disabled if 0
+	heartbeatTimeout time.Duration             // Reconnects if no HEARTBEAT arrives in time, disabled if 0
+	dialTimeout      time.Duration             // Bounds connecting, TLS handshake included, disabled if 0
+	writeTimeout     time.Duration             // Deadline of every write on the connection, disabled if 0
+	dialFunc         DialFunc                  // Replaces the default dialer when set
+	proxy            *url.URL                  // Dials through the SOCKS5 or HTTP CONNECT proxy when set
+	keepAlive        time.Duration             // TCP keepalive period, disabled if negative, as dialed if 0
+	authUser         string                    // user@domain logging in with userauth instead of auth, optional
+	dispatchWorkers  int                       // Goroutines running the handlers, one per handler and event if 0
+	dispatchQueue    int                       // Events waiting for the dispatch workers before the reading blocks
+	syncDispatch     bool                      // Runs the handlers inline in the reading loop
+	eventQueueSize   int                       // Events buffered between reading and dispatching, no buffer if 0
+	overflowPolicy   OverflowPolicy            // Fate of the events received while the buffer is full
+	deadLetter       DeadLetterSink            // Receives the events dropped or failing their handlers
+	sequences        *sequenceTracker          // Detects the Event-Sequence gaps, shared across reconnects
+	lag              *lagMeter                 // Measures the event lag, shared across reconnects
+	metrics          *Metrics                  // Collects the connection activity when set
+	tracer           *wireTracer               // Traces the raw frames when set
+	redactor         Redactor                  // Masks the sensitive texts logged, traced or within errors
+	recorder         *eventRecorder            // Records the received events when set
+	connID           string                    // Names the connection in the logs and ConnInfo, optional
+	connMetadata     map[string]string         // Describes the connection in the logs and ConnInfo, optional
+	streams          *eventStreams             // Consumers of Events, shared across reconnects, protected by handlersMux
+	defaultHandler   func(string, int)         // Receives the events matching no handler, logged if nil
+	pull             bool                      // Read by NextEvent, without the readEvents goroutine
+	contentHandlers  map[string]ContentHandler // Handle the Content-Types beyond the built-in ones, by Content-Type
+}
+
+// handshake waits for the auth challenge, authenticates and subscribes to the desired
+// events and filters.
+func (fsConn *FSConn) handshake(passwd string, evFilters map[string][]string, bgapi bool) (err error) {
+	var authChlng string
+	if authChlng, err = fsConn.readHeaders(); err != nil {
+		return
+	}
+
+	if !strings.Contains(authChlng, "auth/request") {
+		fsConn.conn.Close()
+		return errors.New("no auth challenge received")
+	}
+
+	if err = fsConn.auth(passwd); err != nil { // Auth did not succeed
+		return
+	}
+
+	if err = fsConn.lingerEvents(); err != nil {
+		return
+	}
+
+	if err = fsConn.filterEvents(evFilters, bgapi); err != nil {
+		return
+	}
+
+	return fsConn.eventsPlain(fsConn.eventNames(), bgapi)
+}
+
+// eventNames returns the names of the events having either plain or context-aware handlers.
+func (fsConn *FSConn) eventNames() []string {
+	fsConn.handlersMux.RLock()
+	defer fsConn.handlersMux.RUnlock()
+	evNames := getMapKeys(fsConn.eventHandlers)
+	for evName := range fsConn.ctxEventHandlers {
+		if _, has := fsConn.eventHandlers[evName]; !has {
+			evNames = append(evNames, evName)
+		}
+	}
+	if fsConn.heartbeatTimeout > 0 && !fsConn.hasHandlers("HEARTBEAT") {
+		evNames = append(evNames, "HEARTBEAT") // needed by the watchdog
+	}
+	return evNames
 }
 
 // readHeaders reads and parses the headers from a FreeSWITCH response.
 func (fsConn *FSConn) readHeaders() (header string, err error) {
-	bytesRead := make([]byte, 0) // buffer to accumulate header bytes
-	var readLine []byte          // temporary slice to hold each line read
+	hdr, err := fsConn.readHeaderBytes()
+	if err != nil {
+		return "", err
+	}
+	return string(hdr), nil
+}
 
+// readHeaderBytes is the same as readHeaders, returning the headers out of the scratch
+// buffer of the connection, valid until the next read. The lines are read in place with
+// ReadSlice and the buffer only grows, sparing the per line allocations.
+func (fsConn *FSConn) readHeaderBytes() ([]byte, error) {
+	fsConn.hdrBuf = fsConn.hdrBuf[:0]
+	lineStart := 0 // where the line being read starts within hdrBuf
 	for {
-		if readLine, err = fsConn.rdr.ReadBytes('\n'); err != nil {
-			fsConn.lgr.Err(fmt.Sprintf(
+		readLine, err := fsConn.rdr.ReadSlice('\n')
+		if err == bufio.ErrBufferFull { // longer than the reader buffer, keep reading the line
+			fsConn.hdrBuf = append(fsConn.hdrBuf, readLine...)
+			continue
+		}
+		if err != nil {
+			fsConn.log().Err(fmt.Sprintf(
 				"<FSock> Error reading headers: <%v>", err))
 			fsConn.conn.Close() // close the connection regardless
 
@@ -116,24 +412,37 @@ func (fsConn *FSConn) readHeaders() (header string, err error) {
 			var opErr *net.OpError
 			if !errors.As(err, &opErr) || opErr.Timeout() ||
 				errors.Is(opErr.Err, syscall.ECONNRESET) {
-				return "", io.EOF
+				return nil, io.EOF
 			}
-			return "", err
+			return nil, err
 		}
+		fsConn.hdrBuf = append(fsConn.hdrBuf, readLine...)
 
 		// Check if the line is empty.
-		if len(bytes.TrimSpace(readLine)) == 0 {
+		if len(bytes.TrimSpace(fsConn.hdrBuf[lineStart:])) == 0 {
 			// Empty line indicates the end of the headers, exit loop.
+			fsConn.hdrBuf = fsConn.hdrBuf[:lineStart]
 			break
 		}
-		bytesRead = append(bytesRead, readLine...)
+		lineStart = len(fsConn.hdrBuf)
 	}
-	return string(bytesRead), nil
+	if fsConn.tracer != nil {
+		fsConn.tracer.trace(traceReceived, fsConn.connIdx, len(fsConn.hdrBuf), fsConn.redact(string(fsConn.hdrBuf)))
+	}
+	return fsConn.hdrBuf, nil
 }
 
 // auth authenticates the connection with FreeSWITCH using the provided password.
 func (fsConn *FSConn) auth(passwd string) (err error) {
-	if err = fsConn.send("auth " + passwd + "\n\n"); err != nil {
+	authCmd := "auth " + passwd
+	if fsConn.authUser != "" {
+		if strings.ContainsAny(fsConn.authUser, " \t\r\n:") {
+			fsConn.conn.Close()
+			return fmt.Errorf("%w: user %q", ErrUnsafeArg, fsConn.authUser)
+		}
+		authCmd = "userauth " + fsConn.authUser + ":" + passwd
+	}
+	if err = fsConn.send(authCmd + "\n\n"); err != nil {
 		fsConn.conn.Close()
 		return
 	}
@@ -143,7 +452,19 @@ func (fsConn *FSConn) auth(passwd string) (err error) {
 	}
 	if !strings.Contains(rply, "Reply-Text: +OK accepted") {
 		fsConn.conn.Close()
-		return fmt.Errorf("unexpected auth reply received: <%s>", rply)
+		return fmt.Errorf("unexpected auth reply received: <%s>", redactSecret(fsConn.redact(rply), passwd))
+	}
+	return
+}
+
+// ownFilters returns the events the connection relies on, to be let through as well once
+// the events are filtered: BACKGROUND_JOB for bgapi and HEARTBEAT for the watchdog.
+func (opts *connOptions) ownFilters(bgapi bool) (evNames []string) {
+	if bgapi {
+		evNames = append(evNames, "BACKGROUND_JOB")
+	}
+	if opts.heartbeatTimeout > 0 {
+		evNames = append(evNames, "HEARTBEAT")
 	}
 	return
 }
@@ -153,13 +474,17 @@ func (fsConn *FSConn) filterEvents(filters map[string][]string, bgapi bool) (err
 	if len(filters) == 0 {
 		return nil
 	}
-	if bgapi {
-		filters["Event-Name"] = append(filters["Event-Name"], "BACKGROUND_JOB") // for bgapi
+	if own := slices.DeleteFunc(fsConn.ownFilters(bgapi), func(evName string) bool {
+		return slices.Contains(filters["Event-Name"], evName)
+	}); len(own) != 0 {
+		// Cloned, the filters are kept for the reconnects and need them added only once.
+		filters = maps.Clone(filters)
+		filters["Event-Name"] = append(slices.Clip(filters["Event-Name"]), own...)
 	}
 	for hdr, vals := range filters {
 		for _, val := range vals {
 			if err = fsConn.send("filter " + hdr + " " + val + "\n\n"); err != nil {
-				fsConn.lgr.Err(fmt.Sprintf("<FSock> Error filtering events: <%s>", err.Error()))
+				fsConn.log().Err(fmt.Sprintf("<FSock> Error filtering events: <%s>", err.Error()))
 				fsConn.conn.Close()
 				return
 			}
@@ -176,22 +501,98 @@ func (fsConn *FSConn) filterEvents(filters map[string][]string, bgapi bool) (err
 	return nil
 }
 
+// lingerEvents enables linger on the connection, if configured.
+func (fsConn *FSConn) lingerEvents() (err error) {
+	if fsConn.linger <= 0 {
+		return
+	}
+	if err = fsConn.send("linger " + strconv.Itoa(fsConn.linger) + "\n\n"); err != nil {
+		fsConn.conn.Close()
+		return
+	}
+	var rply string
+	if rply, err = fsConn.readHeaders(); err != nil {
+		return
+	}
+	if !strings.Contains(rply, "Reply-Text: +OK") {
+		fsConn.conn.Close()
+		return fmt.Errorf("unexpected linger reply received: <%s>", rply)
+	}
+	return
+}
+
+// setLinger sends linger, or nolinger if seconds is not positive, on the live connection.
+func (fsConn *FSConn) setLinger(seconds int) (err error) {
+	cmd := "nolinger"
+	if seconds > 0 {
+		cmd = "linger " + strconv.Itoa(seconds)
+	}
+	if _, err = fsConn.Send(cmd + "\n\n"); err != nil {
+		return
+	}
+	fsConn.linger = seconds
+	return
+}
+
+// addFilter issues filter for the header value on the live connection.
+func (fsConn *FSConn) addFilter(header, value string) (err error) {
+	_, err = fsConn.Send("filter " + header + " " + value + "\n\n")
+	return
+}
+
+// deleteFilter removes the filter for the header value from the live connection.
+func (fsConn *FSConn) deleteFilter(header, value string) (err error) {
+	_, err = fsConn.Send("filter delete " + header + " " + value + "\n\n")
+	return
+}
+
 // send will send the content over the connection.
 func (fsConn *FSConn) send(sendContent string) (err error) {
+	if fsConn.writeTimeout > 0 {
+		if err = fsConn.conn.SetWriteDeadline(time.Now().Add(fsConn.writeTimeout)); err != nil {
+			return
+		}
+	}
+	if fsConn.tracer != nil {
+		fsConn.tracer.trace(traceSent, fsConn.connIdx, len(sendContent), fsConn.redact(sendContent))
+	}
 	if _, err = fsConn.conn.Write([]byte(sendContent)); err != nil {
-		fsConn.lgr.Err(fmt.Sprintf("<FSock> Cannot write command to socket <%s>", err.Error()))
+		fsConn.log().Err(fmt.Sprintf("<FSock> Cannot write command to socket <%s>", err.Error()))
 	}
 	return
 }
 
-// eventsPlain will subscribe for events in plain mode.
+// eventsPlain will subscribe for events in plain mode, or in the format
+// configured for the connection.
 func (fsConn *FSConn) eventsPlain(events []string, bgapi bool) (err error) {
-	eventsCmd := "event plain"
+	eventsCmd := fsConn.eventsCmd(events, bgapi)
+	if err = fsConn.send(eventsCmd + "\n\n"); err != nil {
+		fsConn.conn.Close()
+		return
+	}
+	var rply string
+	if rply, err = fsConn.readHeaders(); err != nil {
+		return
+	}
+	if !strings.Contains(rply, "Reply-Text: +OK") {
+		fsConn.conn.Close()
+		return fmt.Errorf("unexpected events-subscribe reply received: <%s>", rply)
+	}
+	return
+}
+
+// eventsCmd builds the command subscribing to the events.
+func (fsConn *FSConn) eventsCmd(events []string, bgapi bool) string {
+	format := fsConn.eventFormat
+	if format == "" {
+		format = EventFormatPlain
+	}
+	eventsCmd := "event " + format
+	allEventsCmd := eventsCmd + " all"
 	customEvents := ""
 	for _, ev := range events {
 		if ev == "ALL" {
-			eventsCmd = "event plain all"
-			break
+			return allEventsCmd
 		}
 		if strings.HasPrefix(ev, "CUSTOM") {
 			customEvents += ev[6:] // will capture here also space between CUSTOM and event
@@ -199,116 +600,535 @@ func (fsConn *FSConn) eventsPlain(events []string, bgapi bool) (err error) {
 		}
 		eventsCmd += " " + ev
 	}
-	if eventsCmd != "event plain all" {
-		if bgapi {
-			eventsCmd += " BACKGROUND_JOB" // For bgapi
-		}
-		if len(customEvents) != 0 { // Add CUSTOM events subscribing in the end otherwise unexpected events are received
-			eventsCmd += " " + "CUSTOM" + customEvents
-		}
+	if bgapi {
+		eventsCmd += " BACKGROUND_JOB" // For bgapi
+	}
+	if len(customEvents) != 0 { // Add CUSTOM events subscribing in the end otherwise unexpected events are received
+		eventsCmd += " " + "CUSTOM" + customEvents
 	}
+	return eventsCmd
+}
 
-	if err = fsConn.send(eventsCmd + "\n\n"); err != nil {
-		fsConn.conn.Close()
+// hasHandlers checks if there are handlers registered for the event. Not thread safe.
+func (fsConn *FSConn) hasHandlers(eventName string) bool {
+	return len(fsConn.eventHandlers[eventName]) != 0 ||
+		len(fsConn.ctxEventHandlers[eventName]) != 0
+}
+
+// addEventHandler registers the handler on the live connection, subscribing
+// to the event if not already receiving it.
+func (fsConn *FSConn) addEventHandler(eventName string, handler func(string, int)) (err error) {
+	fsConn.handlersMux.Lock()
+	subscribed := fsConn.hasHandlers(eventName) || fsConn.hasHandlers("ALL") || fsConn.paused
+	if fsConn.eventHandlers == nil {
+		fsConn.eventHandlers = make(map[string][]func(string, int))
+	}
+	fsConn.eventHandlers[eventName] = append(fsConn.eventHandlers[eventName], handler)
+	fsConn.handlersMux.Unlock()
+	if subscribed {
 		return
 	}
-	var rply string
-	if rply, err = fsConn.readHeaders(); err != nil {
+	_, err = fsConn.Send(fsConn.eventsCmd([]string{eventName}, false) + "\n\n")
+	return
+}
+
+// setEventHandlers replaces the handlers of the event on the live connection with the
+// ones left after a removal, unsubscribing from the event once none are left for it.
+func (fsConn *FSConn) setEventHandlers(eventName string, handlers []func(string, int), bgapi bool) (err error) {
+	fsConn.handlersMux.Lock()
+	if len(handlers) != 0 {
+		fsConn.eventHandlers[eventName] = handlers
+	} else {
+		delete(fsConn.eventHandlers, eventName)
+	}
+	unsubscribe := !fsConn.hasHandlers(eventName) && !fsConn.paused
+	if eventName != "ALL" && fsConn.hasHandlers("ALL") {
+		unsubscribe = false // still receiving it as part of all the events
+	}
+	fsConn.handlersMux.Unlock()
+	if !unsubscribe {
 		return
 	}
-	if !strings.Contains(rply, "Reply-Text: +OK") {
-		fsConn.conn.Close()
-		return fmt.Errorf("unexpected events-subscribe reply received: <%s>", rply)
+	if eventName != "ALL" {
+		_, err = fsConn.Send("nixevent " + eventName + "\n\n")
+		return
+	}
+	if _, err = fsConn.Send("nixevent all\n\n"); err != nil {
+		return
+	}
+	// Dropped along all the events, the ones still handled are subscribed again.
+	if evNames := fsConn.eventNames(); len(evNames) != 0 || bgapi {
+		_, err = fsConn.Send(fsConn.eventsCmd(evNames, bgapi) + "\n\n")
+	}
+	return
+}
+
+// subscribeMyEvents issues myevents, restricting the connection to the events of the
+// call leg identified by uuid and delivering them to handler. FreeSWITCH keeps one
+// myevents subscription per connection, a new one replaces the previous.
+func (fsConn *FSConn) subscribeMyEvents(uuid string, handler func(string, int)) (err error) {
+	if err = CheckArg(uuid); err != nil {
+		return
+	}
+	cmd := "myevents " + uuid
+	if fsConn.eventFormat != "" && fsConn.eventFormat != EventFormatPlain {
+		cmd = "myevents " + fsConn.eventFormat + " " + uuid
+	}
+	fsConn.handlersMux.Lock() // in place before the first event arrives
+	prevUUID, prevHandler := fsConn.myEventsUUID, fsConn.myEventsHandler
+	fsConn.myEventsUUID, fsConn.myEventsHandler = uuid, handler
+	fsConn.handlersMux.Unlock()
+	if _, err = fsConn.Send(cmd + "\n\n"); err != nil {
+		fsConn.handlersMux.Lock()
+		fsConn.myEventsUUID, fsConn.myEventsHandler = prevUUID, prevHandler
+		fsConn.handlersMux.Unlock()
+	}
+	return
+}
+
+// subscribeLog issues log for the level, delivering the log/data payloads to handler.
+func (fsConn *FSConn) subscribeLog(level string, handler func(string, int)) (err error) {
+	fsConn.handlersMux.Lock() // in place before the first log line arrives
+	prevHandler := fsConn.logHandler
+	fsConn.logHandler = handler
+	fsConn.handlersMux.Unlock()
+	if _, err = fsConn.Send("log " + level + "\n\n"); err != nil {
+		fsConn.handlersMux.Lock()
+		fsConn.logHandler = prevHandler
+		fsConn.handlersMux.Unlock()
+	}
+	return
+}
+
+// unsubscribeLog disables the log subscription with nolog.
+func (fsConn *FSConn) unsubscribeLog() (err error) {
+	if _, err = fsConn.Send("nolog\n\n"); err != nil {
+		return
+	}
+	fsConn.handlersMux.Lock()
+	fsConn.logHandler = nil
+	fsConn.handlersMux.Unlock()
+	return
+}
+
+// pauseEvents sends noevents, keeping subscribed only the events the connection itself
+// relies on: BACKGROUND_JOB for bgapi and HEARTBEAT for the watchdog.
+func (fsConn *FSConn) pauseEvents(bgapi bool) (err error) {
+	fsConn.handlersMux.Lock() // the handlers added meanwhile wait for resumeEvents
+	fsConn.paused = true
+	fsConn.handlersMux.Unlock()
+	if _, err = fsConn.Send("noevents\n\n"); err != nil {
+		fsConn.handlersMux.Lock()
+		fsConn.paused = false
+		fsConn.handlersMux.Unlock()
+		return
+	}
+	var evNames []string
+	if fsConn.heartbeatTimeout > 0 {
+		evNames = append(evNames, "HEARTBEAT")
+	}
+	if len(evNames) != 0 || bgapi {
+		_, err = fsConn.Send(fsConn.eventsCmd(evNames, bgapi) + "\n\n")
+	}
+	return
+}
+
+// resumeEvents subscribes again to the events of the handlers and to myevents, if
+// subscribed to, undoing pauseEvents.
+func (fsConn *FSConn) resumeEvents(bgapi bool) (err error) {
+	if _, err = fsConn.Send(fsConn.eventsCmd(fsConn.eventNames(), bgapi) + "\n\n"); err != nil {
+		return
+	}
+	fsConn.handlersMux.Lock()
+	fsConn.paused = false
+	myEventsUUID, myEventsHandler := fsConn.myEventsUUID, fsConn.myEventsHandler
+	fsConn.handlersMux.Unlock()
+	if myEventsHandler != nil {
+		err = fsConn.subscribeMyEvents(myEventsUUID, myEventsHandler)
 	}
 	return
 }
 
 // readEvent will read one Event from FreeSWITCH, made out of headers and body (if present).
 func (fsConn *FSConn) readEvent() (header string, body string, err error) {
-	if header, err = fsConn.readHeaders(); err != nil {
+	hdr, err := fsConn.readHeaderBytes()
+	if err != nil {
+		return "", "", err
+	}
+	if body, err = fsConn.readContent(hdr); err != nil {
 		return "", "", err
 	}
-	if !strings.Contains(header, "Content-Length") { //No body
-		return header, "", nil
+	return string(hdr), body, nil
+}
+
+// readContent reads the body announced by the Content-Length of the header, if any.
+func (fsConn *FSConn) readContent(header []byte) (string, error) {
+	cl, has, err := contentLength(header)
+	if !has { //No body
+		return "", nil
 	}
-	cl, err := strconv.Atoi(headerVal(header, "Content-Length"))
 	if err != nil {
-		return "", "", fmt.Errorf("invalid Content-Length header: %v", err)
+		return "", fmt.Errorf("invalid Content-Length header: %v", err)
 	}
-	if body, err = fsConn.readBody(cl); err != nil {
-		return "", "", err
+	return fsConn.readBody(cl)
+}
+
+// contentLength returns the Content-Length found among the headers, see headerLine.
+func contentLength(hdrs []byte) (cl int, has bool, err error) {
+	line, has := headerLine(hdrs, []byte("Content-Length"))
+	if !has {
+		return 0, false, nil
+	}
+	_, val, _ := bytes.Cut(line, []byte(": "))
+	cl, err = strconv.Atoi(string(bytes.TrimSpace(val)))
+	return cl, true, err
+}
+
+// headerLine returns the rest of the line after the header name among the message headers,
+// starting with the colon. The name is anchored as lookupHeader does, so neither e.g.
+// X-Content-Length nor a header value is taken for it. Garbage left before the frame is
+// tolerated, on its first line only.
+func headerLine(hdrs, name []byte) ([]byte, bool) {
+	for from := 0; ; {
+		idx := bytes.Index(hdrs[from:], name)
+		if idx == -1 {
+			return nil, false
+		}
+		idx += from
+		line, _, _ := bytes.Cut(hdrs[idx+len(name):], []byte("\n"))
+		if (len(line) == 0 || line[0] == ':') && (idx == 0 || hdrs[idx-1] == '\n' ||
+			(hdrs[idx-1] == ' ' && bytes.IndexByte(bytes.TrimLeft(hdrs[:idx], "\n"), '\n') == -1)) {
+			return line, true
+		}
+		from = idx + 1
 	}
-	return header, body, nil
 }
 
 // readBody reads the specified number of bytes from the buffer.
 // The number of bytes to read is given by 'noBytes', which is determined from the content-length header.
 func (fsConn *FSConn) readBody(noBytes int) (string, error) {
-	bytesRead := make([]byte, noBytes)
+	buf := getBodyBuf(noBytes) // only the string outlives the read
+	defer putBodyBuf(buf)
+	bytesRead := *buf
 	_, err := io.ReadFull(fsConn.rdr, bytesRead)
 	if err != nil {
-		fsConn.lgr.Err(fmt.Sprintf("<FSock> Error reading message body: <%v>", err))
+		fsConn.log().Err(fmt.Sprintf("<FSock> Error reading message body: <%v>", err))
 		fsConn.conn.Close()
 		return "", io.EOF // Return io.EOF to trigger ReconnectIfNeeded.
 	}
+	if fsConn.tracer != nil {
+		fsConn.tracer.trace(traceReceived, fsConn.connIdx, len(bytesRead), fsConn.redact(string(bytesRead)))
+	}
 	return string(bytesRead), nil
 }
 
 // readEvents continuously reads and processes events from the network buffer. It stops
 // and exits the loop if an error is encountered, after sending it to fsConn.err.
 func (fsConn *FSConn) readEvents() {
+	if fsConn.queue != nil {
+		defer fsConn.queue.close()
+	} else if fsConn.workers != nil {
+		defer fsConn.workers.stop()
+	}
 	for {
-		hdr, body, err := fsConn.readEvent()
+		hdr, err := fsConn.readHeaderBytes() // converted only where needed, the events skip it
+		var body string
+		var streamed bool
+		if err == nil {
+			if bytes.Contains(hdr, []byte("api/response")) && fsConn.streamAwaited() {
+				streamed = true
+				err = fsConn.streamReply(hdr)
+			} else {
+				body, err = fsConn.readContent(hdr)
+			}
+		}
+		fsConn.lastRead.Store(time.Now().UnixNano())
 
-		// If an error occurs during the read operation, report
-		// it on the error channel and exit the loop.
+		// If an error occurs during the read operation, cancel the
+		// handlers context, report the error and exit the loop.
 		if err != nil {
+			if err == io.EOF && fsConn.disconnecting.Load() {
+				err = net.ErrClosed // closed after the lingered events, not a connection loss
+			}
+			if fsConn.stale.Load() {
+				err = ErrStaleConnection
+			}
+			fsConn.cancelHandlers()
+			fsConn.failReplies()
+			fsConn.reading.Store(false)
 			fsConn.err <- err
 			return
 		}
 		switch {
-		case strings.Contains(hdr, "api/response"):
-			// For API responses, send the body
-			// directly to the replies channel.
-			fsConn.replies <- body
+		case streamed:
+			// Handed over to its command.
 
-		case strings.Contains(hdr, "command/reply"):
-			// For command replies, extract the "Reply-Text" from
-			// the header and send it to the replies channel.
-			fsConn.replies <- headerVal(hdr, "Reply-Text")
+		default:
+			fsConn.handleContent(hdr, body)
+		}
+	}
+}
 
-		case body != "":
-			// Could be an event, try dispatching it.
-			fsConn.dispatchEvent(body)
+// fullEventName returns the name the event is dispatched by, the subclass included for CUSTOM events.
+func fullEventName(event string) string {
+	eventName := headerVal(event, "Event-Name")
+	if eventName == "CUSTOM" {
+		eventSubclass := headerVal(event, "Event-Subclass")
+		if len(eventSubclass) != 0 {
+			eventName += " " + urlDecode(eventSubclass)
 		}
 	}
+	return eventName
+}
+
+// handleEvent dispatches the event, through the event queue if configured.
+func (fsConn *FSConn) handleEvent(event string) {
+	if fsConn.recorder != nil {
+		fsConn.recorder.record(event, time.Now())
+	}
+	if fsConn.metrics != nil {
+		fsConn.metrics.eventReceived(fullEventName(event))
+	}
+	if fsConn.sequences != nil {
+		fsConn.checkSequence(event)
+	}
+	fsConn.unhandled.Add(1)
+	if fsConn.queue == nil ||
+		headerVal(event, "Event-Name") == "BACKGROUND_JOB" { // bgapi results are never dropped
+		fsConn.dispatchEvent(event)
+		fsConn.unhandled.Add(-1)
+		return
+	}
+	if dropped, hasDropped := fsConn.queue.push(event); hasDropped {
+		fsConn.unhandled.Add(-1)
+		droppedName := headerVal(dropped, "Event-Name")
+		fsConn.log("event_name", droppedName).Warning(fmt.Sprintf(
+			"<FSock> Event queue full, dropped event with name: %s", droppedName))
+		if fsConn.deadLetter != nil {
+			fsConn.deadLetter(dropped, ErrEventDropped, fsConn.connIdx)
+		}
+	}
+}
+
+// checkSequence reports the events missed before event, according to their Event-Sequence.
+func (fsConn *FSConn) checkSequence(event string) {
+	last, seq, gap := fsConn.sequences.track(event)
+	if !gap {
+		return
+	}
+	fsConn.log().Warning(fmt.Sprintf("<FSock> Missed %d events, Event-Sequence jumped from %d to %d",
+		seq-last-1, last, seq))
+	if fsConn.sequences.handler != nil {
+		fsConn.sequences.handler(last, seq, fsConn.connIdx)
+	}
+}
+
+// dispatchQueued dispatches the events of the queue until it is closed.
+func (fsConn *FSConn) dispatchQueued() {
+	if fsConn.workers != nil {
+		defer fsConn.workers.stop()
+	}
+	for event := range fsConn.queue.events {
+		fsConn.dispatchEvent(event)
+		fsConn.unhandled.Add(-1)
+	}
+}
+
+// DroppedEvents returns the number of events dropped by the connection because of
+// the full event queue, see WithEventQueue.
+func (fsConn *FSConn) DroppedEvents() uint64 {
+	if fsConn.queue == nil {
+		return 0
+	}
+	return fsConn.queue.dropped.Load()
 }
 
 // Dispatch events to handlers in async mode
 func (fsConn *FSConn) dispatchEvent(event string) {
-	eventName := headerVal(event, "Event-Name")
+	eventName := fullEventName(event)
+	if fsConn.lag != nil {
+		if lag, ok := fsConn.lag.measure(event, time.Now()); ok && fsConn.lag.handler != nil {
+			fsConn.lag.handler(lag, eventName, fsConn.connIdx)
+		}
+	}
 	if eventName == "BACKGROUND_JOB" { // for bgapi BACKGROUND_JOB
 		go fsConn.doBackgroundJob(event)
 		return
 	}
+	executed := eventName == "CHANNEL_EXECUTE_COMPLETE" && fsConn.completeExecution(event)
 
-	if eventName == "CUSTOM" {
-		eventSubclass := headerVal(event, "Event-Subclass")
-		if len(eventSubclass) != 0 {
-			eventName += " " + urlDecode(eventSubclass)
+	fsConn.handlersMux.RLock()
+	myEventsHandler := fsConn.myEventsHandler
+	myEvent := myEventsHandler != nil &&
+		headerVal(event, "Unique-ID") == fsConn.myEventsUUID
+	var handlers []func(string, int)
+	var ctxHandlers []EventHandlerCtx
+	var hasHandlers, hasCtxHandlers bool
+	for _, handleName := range []string{eventName, "ALL"} {
+		handlers, hasHandlers = fsConn.eventHandlers[handleName]
+		ctxHandlers, hasCtxHandlers = fsConn.ctxEventHandlers[handleName]
+		if hasHandlers || hasCtxHandlers {
+			break
 		}
 	}
+	var streams []*eventStream
+	if fsConn.streams != nil {
+		streams = fsConn.streams.matching(eventName)
+	}
+	fsConn.handlersMux.RUnlock()
 
-	for _, handleName := range []string{eventName, "ALL"} {
-		if _, hasHandlers := fsConn.eventHandlers[handleName]; hasHandlers {
-			// We have handlers, dispatch to all of them
-			for _, handlerFunc := range fsConn.eventHandlers[handleName] {
-				go handlerFunc(event, fsConn.connIdx)
+	// Dispatch out of the lock, the worker queue might block
+	if myEvent {
+		fsConn.run(fsConn.guard(event, func() { myEventsHandler(event, fsConn.connIdx) }))
+	}
+	for _, handlerFunc := range handlers {
+		fsConn.run(fsConn.guard(event, func() { handlerFunc(event, fsConn.connIdx) }))
+	}
+	for _, handlerFunc := range ctxHandlers {
+		fsConn.run(fsConn.guard(event, func() { fsConn.handleEventCtx(handlerFunc, event) }))
+	}
+	for _, es := range streams {
+		if dropped, hasDropped := es.push(event); hasDropped { // in order, whatever the dispatch mode
+			droppedName := headerVal(dropped, "Event-Name")
+			fsConn.log("event_name", droppedName).Warning(fmt.Sprintf(
+				"<FSock> Events consumer queue full, dropped event with name: %s", droppedName))
+			if fsConn.deadLetter != nil {
+				fsConn.deadLetter(dropped, ErrEventDropped, fsConn.connIdx)
 			}
-			return
 		}
 	}
-	fsConn.lgr.Warning(fmt.Sprintf("<FSock> No dispatcher for event: <%+v> with event name: %s", event, eventName))
+	if executed || myEvent || hasHandlers || hasCtxHandlers || len(streams) != 0 ||
+		(eventName == "HEARTBEAT" && fsConn.heartbeatTimeout > 0) {
+		return
+	}
+	if fsConn.defaultHandler != nil {
+		fsConn.run(fsConn.guard(event, func() { fsConn.defaultHandler(event, fsConn.connIdx) }))
+		return
+	}
+	fsConn.log("event_name", eventName).Warning(fmt.Sprintf(
+		"<FSock> No dispatcher for event: <%+v> with event name: %s", event, eventName))
+}
+
+// dispatchLog hands the log/data payload to the log handler, if any.
+func (fsConn *FSConn) dispatchLog(logData string) {
+	fsConn.handlersMux.RLock()
+	logHandler := fsConn.logHandler
+	fsConn.handlersMux.RUnlock()
+	if logHandler == nil {
+		fsConn.log().Warning(fmt.Sprintf("<FSock> No handler for log data: <%s>", logData))
+		return
+	}
+	fsConn.run(func() { logHandler(logData, fsConn.connIdx) })
+}
+
+// setStreams hands over the Events consumers of the FSock.
+func (fsConn *FSConn) setStreams(streams *eventStreams) {
+	fsConn.handlersMux.Lock()
+	fsConn.streams = streams
+	fsConn.handlersMux.Unlock()
+}
+
+// guard recovers the panics of the handler task when a dead letter sink is configured,
+// routing the event to the sink instead of crashing.
+func (fsConn *FSConn) guard(event string, task func()) func() {
+	if fsConn.deadLetter == nil {
+		return task
+	}
+	return func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("event handler panicked: %v", r)
+				eventName := headerVal(event, "Event-Name")
+				fsConn.log("event_name", eventName).Err(fmt.Sprintf("<FSock> %v, event name: %s", err, eventName))
+				fsConn.deadLetter(event, err, fsConn.connIdx)
+			}
+		}()
+		task()
+	}
+}
+
+// log returns the logger of the connection, with the connection fields, see ConnInfo,
+// and the args fields attached if the logger supports them.
+func (fsConn *FSConn) log(args ...any) Logger {
+	return fsConn.ConnInfo().logger(fsConn.getLogger(), args...)
+}
+
+// ConnInfo returns the identification of the connection.
+func (fsConn *FSConn) ConnInfo() ConnInfo {
+	return ConnInfo{Idx: fsConn.connIdx, ID: fsConn.connID, Metadata: fsConn.connMetadata}
+}
+
+// getLogger returns the logger in use.
+func (fsConn *FSConn) getLogger() Logger {
+	fsConn.lgrMu.RLock()
+	defer fsConn.lgrMu.RUnlock()
+	return fsConn.lgr
+}
+
+// SetLogger replaces the logger of the connection, a nil one disabling the logging.
+func (fsConn *FSConn) SetLogger(lgr Logger) {
+	if lgr == nil {
+		lgr = nopLogger{}
+	}
+	fsConn.lgrMu.Lock()
+	defer fsConn.lgrMu.Unlock()
+	fsConn.lgr = lgr
+}
+
+// redact masks the auth password within s, passing it through the configured Redactor too.
+func (fsConn *FSConn) redact(s string) string {
+	s = redactFrame(s)
+	if fsConn.redactor != nil {
+		s = fsConn.redactor(s)
+	}
+	return s
+}
+
+// run executes the handler task inline or on the dispatch workers if configured so,
+// on its own goroutine otherwise.
+func (fsConn *FSConn) run(task func()) {
+	fsConn.running.Add(1)
+	task = func(task func()) func() {
+		return func() {
+			defer fsConn.running.Add(-1)
+			task()
+		}
+	}(task)
+	if fsConn.syncDispatch {
+		task()
+		return
+	}
+	if fsConn.workers != nil {
+		fsConn.workers.submit(task)
+		return
+	}
+	go task()
+}
+
+// DispatchQueueDepth returns the number of handler calls waiting for a dispatch worker,
+// always 0 without WithDispatchWorkers.
+func (fsConn *FSConn) DispatchQueueDepth() int {
+	if fsConn.workers == nil {
+		return 0
+	}
+	return fsConn.workers.depth()
+}
+
+// handleEventCtx invokes a context-aware handler with the connection context and a logger
+// scoped to this connection.
+func (fsConn *FSConn) handleEventCtx(handlerFunc EventHandlerCtx, event string) {
+	ctx := fsConn.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	handlerFunc(ctx, fsConn.ConnInfo().scope(fsConn.getLogger()), event, fsConn.connIdx)
+}
+
+// cancelHandlers signals the context-aware handlers that the connection is gone.
+func (fsConn *FSConn) cancelHandlers() {
+	if fsConn.cancel != nil {
+		fsConn.cancel()
+	}
 }
 
 // bgapi event lisen fuction
@@ -316,84 +1136,397 @@ func (fsConn *FSConn) doBackgroundJob(event string) { // add mutex protection
 	evMap := EventToMap(event)
 	jobUUID, has := evMap["Job-UUID"]
 	if !has {
-		fsConn.lgr.Err("<FSock> BACKGROUND_JOB with no Job-UUID")
+		fsConn.log().Err("<FSock> BACKGROUND_JOB with no Job-UUID")
 		return
 	}
 
-	fsConn.bgapiMux.Lock()
-	defer fsConn.bgapiMux.Unlock()
-	var out chan string
-	out, has = fsConn.bgapiChan[jobUUID]
+	job, has := fsConn.takeJob(jobUUID)
 	if !has {
-		fsConn.lgr.Err(fmt.Sprintf("<FSock> BACKGROUND_JOB with UUID %s lost!", jobUUID))
+		fsConn.log("job_uuid", jobUUID).Err(fmt.Sprintf("<FSock> BACKGROUND_JOB with UUID %s lost!", jobUUID))
 		return // not a requested bgapi
 	}
+	job.deliver(evMap[EventBodyTag])
+}
 
-	delete(fsConn.bgapiChan, jobUUID)
-	out <- evMap[EventBodyTag]
+// takeJob removes the job out of the jobs awaiting their result.
+func (fsConn *FSConn) takeJob(jobUUID string) (job *bgapiJob, has bool) {
+	fsConn.bgapiMux.Lock()
+	defer fsConn.bgapiMux.Unlock()
+	if job, has = fsConn.bgapiChan[jobUUID]; has {
+		delete(fsConn.bgapiChan, jobUUID)
+		if fsConn.metrics != nil {
+			fsConn.metrics.bgapiOutstanding.Add(-1)
+		}
+	}
+	return
 }
 
-// Send will send the content over the connection, exposing synchronous interface outside
+// bgapiJob is a bgapi command awaiting its BACKGROUND_JOB event.
+type bgapiJob struct {
+	out    chan string     // buffered, delivering never blocks
+	ctx    context.Context // the job is abandoned once done
+	stop   func() bool     // stops the cleanup scheduled on ctx
+	expiry *time.Timer     // expires the job after the configured TTL, nil without one
+}
+
+// release stops the cleanups scheduled for the job, once it is out of the jobs map.
+func (job *bgapiJob) release() {
+	if job.stop != nil {
+		job.stop()
+	}
+	if job.expiry != nil {
+		job.expiry.Stop()
+	}
+}
+
+// deliver sends the job result, closing the output instead if the job got abandoned meanwhile.
+func (job *bgapiJob) deliver(result string) {
+	job.release()
+	if job.ctx.Err() != nil {
+		close(job.out)
+		return
+	}
+	job.out <- result
+}
+
+// abandonJob forgets the job, closing its output, if the result was not received yet.
+func (fsConn *FSConn) abandonJob(jobUUID string) {
+	if job, has := fsConn.takeJob(jobUUID); has {
+		job.release()
+		close(job.out)
+	}
+}
+
+// expireJob forgets the job, delivering ErrBgapiJobExpired as its result, if the
+// BACKGROUND_JOB was not received within the TTL.
+func (fsConn *FSConn) expireJob(jobUUID string) {
+	if job, has := fsConn.takeJob(jobUUID); has {
+		fsConn.log("job_uuid", jobUUID).Warning(fmt.Sprintf("<FSock> BACKGROUND_JOB with UUID %s expired", jobUUID))
+		job.deliver("-ERR " + ErrBgapiJobExpired.Error())
+	}
+}
+
+// Send will send the content over the connection, exposing synchronous interface outside.
+// A -ERR reply is returned as *APIError.
 func (fsConn *FSConn) Send(payload string) (string, error) {
-	if err := fsConn.send(payload); err != nil {
+	return fsConn.SendCtx(context.Background(), payload)
+}
+
+// SendCtx is the same as Send, giving up on waiting for the reply once ctx is done.
+// The reply timeout of the connection still applies.
+func (fsConn *FSConn) SendCtx(ctx context.Context, payload string) (string, error) {
+	reply, err := fsConn.sendRaw(ctx, payload)
+	if err != nil {
 		return "", err
 	}
+	if strings.Contains(reply, "-ERR") {
+		return "", newAPIError(fsConn.redact(payload), fsConn.redact(reply))
+	}
+	return reply, nil
+}
+
+// SetReplyTimeout changes the time the commands wait for their replies, disabled if not
+// positive. Applies to the commands sent afterwards.
+func (fsConn *FSConn) SetReplyTimeout(replyTimeout time.Duration) {
+	fsConn.replyTimeout.Store(int64(replyTimeout))
+}
+
+// SendReply is the same as Send, returning the reply parsed into a Reply. A -ERR reply
+// is not an error here, only failing to get the reply is.
+func (fsConn *FSConn) SendReply(payload string) (Reply, error) {
+	return fsConn.SendReplyCtx(context.Background(), payload)
+}
+
+// SendReplyCtx is the same as SendReply, bound by ctx.
+func (fsConn *FSConn) SendReplyCtx(ctx context.Context, payload string) (Reply, error) {
+	reply, err := fsConn.sendRaw(ctx, payload)
+	if err != nil {
+		return Reply{}, err
+	}
+	return NewReply(reply), nil
+}
+
+// sendRaw sends the payload and waits for its reply, bound by ctx and fsConn.replyTimeout.
+func (fsConn *FSConn) sendRaw(ctx context.Context, payload string) (string, error) {
+	reply, _, err := fsConn.roundTrip(ctx, payload, false)
+	return reply, err
+}
+
+// roundTrip sends the payload and waits for its reply, bound by ctx and fsConn.replyTimeout.
+// With stream, an api/response body is returned as a replyStream instead of the reply.
+func (fsConn *FSConn) roundTrip(ctx context.Context, payload string, stream bool) (string, *replyStream, error) {
+	fsConn.inflight.Add(1)
+	defer fsConn.inflight.Add(-1)
+	if fsConn.draining.Load() {
+		return "", nil, ErrShutdown
+	}
+	waiter := newReplyWaiter(stream)
+	if err := fsConn.sendCmd(payload, waiter); err != nil {
+		return "", nil, err
+	}
+	sent := time.Now()
+	if fsConn.metrics != nil {
+		fsConn.metrics.commandSent()
+	}
 
-	// Prepare a context based on fsConn.replyTimeout
-	var ctx context.Context
+	// Bound ctx by fsConn.replyTimeout
 	var cancel context.CancelFunc
-	if fsConn.replyTimeout > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), fsConn.replyTimeout)
+	if replyTimeout := time.Duration(fsConn.replyTimeout.Load()); replyTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, replyTimeout)
 	} else {
-		ctx, cancel = context.WithCancel(context.Background())
+		ctx, cancel = context.WithCancel(ctx)
 	}
 	defer cancel()
 
-	replies := make(chan string)
-	replyErrors := make(chan error)
+	select {
+	case reply, ok := <-waiter.reply:
+		if !ok {
+			return "", nil, ErrNotConnected
+		}
+		if fsConn.metrics != nil {
+			fsConn.metrics.replyReceived(time.Since(sent))
+		}
+		return reply, nil, nil
+	case rs := <-waiter.stream:
+		if fsConn.metrics != nil {
+			fsConn.metrics.replyReceived(time.Since(sent))
+		}
+		return "", rs, nil
+	case <-ctx.Done():
+		if waiter.stream != nil {
+			close(waiter.gone) // a body handed over meanwhile is skipped by the read loop
+		}
+		return "", nil, ctx.Err()
+	}
+}
+
+// replyWaiter is a command awaiting its reply.
+type replyWaiter struct {
+	reply  chan string       // receives the reply, closed once the connection is lost
+	stream chan *replyStream // receives the api/response body to stream, nil if not streaming
+	gone   chan struct{}     // closed once a streaming command gave up waiting
+}
 
-	go func() {
-		select {
-		case reply := <-fsConn.replies:
-			if strings.Contains(reply, "-ERR") {
-				replyErrors <- errors.New(strings.TrimSpace(reply))
-				return
-			}
-			replies <- reply
-		case <-ctx.Done():
-			replyErrors <- ctx.Err()
+// newReplyWaiter returns a waiter, ready for streaming if requested. The channels are
+// buffered, since the command may have given up waiting meanwhile.
+func newReplyWaiter(stream bool) *replyWaiter {
+	waiter := &replyWaiter{reply: make(chan string, 1)}
+	if stream {
+		waiter.stream = make(chan *replyStream, 1)
+		waiter.gone = make(chan struct{})
+	}
+	return waiter
+}
+
+// sendCmd writes the payload, queuing the waiter its reply is handed to. FreeSWITCH replies
+// in the order it receives the commands, so the waiters are queued in the order written,
+// allowing concurrent commands on the same connection.
+func (fsConn *FSConn) sendCmd(payload string, waiter *replyWaiter) error {
+	fsConn.sendMux.Lock()
+	defer fsConn.sendMux.Unlock()
+	fsConn.repliesMux.Lock()
+	if fsConn.repliesDone {
+		fsConn.repliesMux.Unlock()
+		return ErrNotConnected
+	}
+	fsConn.waiters = append(fsConn.waiters, waiter)
+	fsConn.repliesMux.Unlock()
+	if err := fsConn.send(payload); err != nil {
+		fsConn.repliesMux.Lock()
+		if idx := slices.Index(fsConn.waiters, waiter); idx != -1 {
+			fsConn.waiters = slices.Delete(fsConn.waiters, idx, idx+1)
 		}
-	}()
+		fsConn.repliesMux.Unlock()
+		return err
+	}
+	return nil
+}
 
-	select {
-	case reply := <-replies:
-		return reply, nil
-	case err := <-replyErrors:
-		return "", err
+// deliverReply hands the reply to the oldest command awaiting one. The commands which
+// gave up waiting keep their place, so the later replies still reach their own commands.
+func (fsConn *FSConn) deliverReply(reply string) {
+	fsConn.repliesMux.Lock()
+	if len(fsConn.waiters) == 0 {
+		fsConn.repliesMux.Unlock()
+		fsConn.log().Warning(fmt.Sprintf("<FSock> Dropping reply not awaited by any command: <%s>",
+			fsConn.redact(strings.TrimSpace(reply))))
+		return
+	}
+	waiter := fsConn.popWaiter()
+	fsConn.repliesMux.Unlock()
+	waiter.reply <- reply
+}
+
+// popWaiter removes the oldest waiter from the queue, expected not empty. Called with
+// repliesMux locked.
+func (fsConn *FSConn) popWaiter() *replyWaiter {
+	waiter := fsConn.waiters[0]
+	fsConn.waiters[0] = nil
+	fsConn.waiters = fsConn.waiters[1:]
+	return waiter
+}
+
+// failReplies releases the commands awaiting replies once reading stopped, refusing the
+// ones sent afterwards.
+func (fsConn *FSConn) failReplies() {
+	fsConn.repliesMux.Lock()
+	defer fsConn.repliesMux.Unlock()
+	fsConn.repliesDone = true
+	for _, waiter := range fsConn.waiters {
+		close(waiter.reply)
 	}
+	fsConn.waiters = nil
 }
 
 // Send BGAPI command
 func (fsConn *FSConn) SendBgapiCmd(cmdStr string) (out chan string, err error) {
+	return fsConn.SendBgapiCmdCtx(context.Background(), cmdStr)
+}
+
+// SendBgapiCmdCtx is the same as SendBgapiCmd, abandoning the job once ctx is done:
+// its Job-UUID is forgotten and the output channel closed without a result.
+func (fsConn *FSConn) SendBgapiCmdCtx(ctx context.Context, cmdStr string) (out chan string, err error) {
+	if err = checkCmd(cmdStr); err != nil {
+		return
+	}
 	jobUUID := genUUID()
-	out = make(chan string)
+	job := &bgapiJob{
+		out: make(chan string, 1),
+		ctx: ctx,
+	}
 
 	fsConn.bgapiMux.Lock()
-	fsConn.bgapiChan[jobUUID] = out
+	fsConn.bgapiChan[jobUUID] = job
+	if fsConn.metrics != nil {
+		fsConn.metrics.bgapiOutstanding.Add(1)
+	}
+	job.stop = context.AfterFunc(ctx, func() { fsConn.abandonJob(jobUUID) })
+	if fsConn.bgapiJobTTL > 0 {
+		job.expiry = time.AfterFunc(fsConn.bgapiJobTTL, func() { fsConn.expireJob(jobUUID) })
+	}
 	fsConn.bgapiMux.Unlock()
 
-	if _, err = fsConn.Send("bgapi " + cmdStr + "\nJob-UUID:" + jobUUID + "\n\n"); err != nil {
+	if _, err = fsConn.SendCtx(ctx, "bgapi "+cmdStr+"\nJob-UUID:"+jobUUID+"\n\n"); err != nil {
+		job.release()
+		fsConn.takeJob(jobUUID)
 		return nil, err
 	}
-	return
+	return job.out, nil
 }
 
-// Disconnect will disconnect the fsConn from FreeSWITCH
+// Disconnect will disconnect the fsConn from FreeSWITCH. With linger enabled, on the
+// connections FreeSWITCH closes itself, it first waits for the lingered events to be delivered.
 func (fsConn *FSConn) Disconnect() error {
+	if fsConn.lingers() && fsConn.waitLinger() {
+		fsConn.conn.Close() // already closed while reading, nothing to report
+		return nil
+	}
+	fsConn.cancelHandlers()
 	return fsConn.conn.Close()
 }
 
+// Shutdown disconnects gracefully: refuses the new commands, waits for the replies of the
+// pending ones and for the outstanding bgapi jobs, then stops reading and waits for the
+// events already read to be handled. Once ctx is done it stops waiting, disconnecting
+// right away and returning the ctx error.
+func (fsConn *FSConn) Shutdown(ctx context.Context) (err error) {
+	fsConn.draining.Store(true)
+	defer fsConn.cancelHandlers()
+	if err = fsConn.waitFor(ctx, func() bool {
+		return fsConn.inflight.Load() == 0 && fsConn.pendingJobs() == 0
+	}); err != nil {
+		fsConn.conn.Close()
+		return
+	}
+	if err = fsConn.conn.Close(); err != nil {
+		return
+	}
+	return fsConn.waitFor(ctx, func() bool {
+		return !fsConn.reading.Load() && fsConn.unhandled.Load() == 0 && fsConn.running.Load() == 0
+	})
+}
+
+// waitFor polls cond until true, giving up once ctx is done.
+func (fsConn *FSConn) waitFor(ctx context.Context, cond func() bool) error {
+	tkr := time.NewTicker(10 * time.Millisecond)
+	defer tkr.Stop()
+	for !cond() {
+		select {
+		case <-tkr.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// pendingJobs returns the number of bgapi jobs awaiting their result.
+func (fsConn *FSConn) pendingJobs() int {
+	fsConn.bgapiMux.RLock()
+	defer fsConn.bgapiMux.RUnlock()
+	return len(fsConn.bgapiChan)
+}
+
 // LocalAddr returns the local address of the connection
 func (fsConn *FSConn) LocalAddr() net.Addr {
 	return fsConn.conn.LocalAddr()
 }
+
+// RemoteAddr returns the address of FreeSWITCH
+func (fsConn *FSConn) RemoteAddr() net.Addr {
+	return fsConn.conn.RemoteAddr()
+}
+
+// lingers tells if FreeSWITCH closes the connection itself once done delivering the
+// lingered events, announcing it with a disconnect-notice: on the outbound connections
+// and on the ones bound to a call with myevents. The others are never closed by it.
+func (fsConn *FSConn) lingers() bool {
+	if fsConn.linger <= 0 {
+		return false
+	}
+	if fsConn.outbound {
+		return true
+	}
+	fsConn.handlersMux.RLock()
+	defer fsConn.handlersMux.RUnlock()
+	return fsConn.myEventsUUID != ""
+}
+
+// waitLinger waits, at most for the linger period, for FreeSWITCH to close the
+// connection once done delivering the lingered events. Returns true if it did.
+func (fsConn *FSConn) waitLinger() bool {
+	fsConn.disconnecting.Store(true)
+	tm := time.NewTimer(time.Duration(fsConn.linger) * time.Second)
+	defer tm.Stop()
+	select {
+	case <-fsConn.ctx.Done():
+		return true
+	case <-tm.C:
+		return false
+	}
+}
+
+// watchHeartbeat closes the connection once nothing, HEARTBEAT events included, is read
+// for heartbeatTimeout, so half-open connections are detected. readEvents then reports
+// ErrStaleConnection.
+func (fsConn *FSConn) watchHeartbeat() {
+	fsConn.lastRead.CompareAndSwap(0, time.Now().UnixNano())
+	tm := time.NewTimer(fsConn.heartbeatTimeout)
+	defer tm.Stop()
+	for {
+		select {
+		case <-fsConn.ctx.Done():
+			return
+		case <-tm.C:
+		}
+		idle := time.Since(time.Unix(0, fsConn.lastRead.Load()))
+		if idle < fsConn.heartbeatTimeout {
+			tm.Reset(fsConn.heartbeatTimeout - idle)
+			continue
+		}
+		fsConn.log().Err(fmt.Sprintf("<FSock> No HEARTBEAT received for %v, closing the stale connection", idle))
+		fsConn.stale.Store(true)
+		fsConn.conn.Close()
+		return
+	}
+}
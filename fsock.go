@@ -9,52 +9,117 @@ Provides FreeSWITCH socket communication.
 package fsock
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
 	ErrConnectionPoolTimeout = errors.New("ConnectionPool timeout")
+	ErrConnectionPoolClosed  = errors.New("ConnectionPool closed")
+	ErrBgapiJobExpired       = errors.New("bgapi job expired")
+	ErrNotConnected          = errors.New("not connected to FreeSWITCH")
+	ErrStaleConnection       = errors.New("no HEARTBEAT received in time")
+	ErrEventDropped          = errors.New("event dropped, event queue full")
+	ErrShutdown              = errors.New("connection shut down")
+	ErrStreamClosed          = errors.New("reply stream closed")
+	ErrNoSuchChannel         = errors.New("no such channel")
+	ErrReconnectBudget       = errors.New("reconnect budget exhausted")
 )
 
 // NewFSock connects to FS and starts buffering input.
+// Kept for backwards compatibility, see NewFSockWithOptions.
 func NewFSock(addr, passwd string, reconnects int,
 	maxReconnectInterval, replyTimeout time.Duration,
 	delayFunc func(time.Duration, time.Duration) func() time.Duration,
 	eventHandlers map[string][]func(string, int),
 	eventFilters map[string][]string,
-	logger logger, connIdx int, bgapi bool, stopError chan error,
+	logger Logger, connIdx int, bgapi bool, stopError chan error,
 ) (fsock *FSock, err error) {
-	if logger == nil ||
-		(reflect.ValueOf(logger).Kind() == reflect.Ptr && reflect.ValueOf(logger).IsNil()) {
-		logger = nopLogger{}
-	}
-	fsock = &FSock{
-		mu:                   new(sync.RWMutex),
-		connIdx:              connIdx,
-		addr:                 addr,
-		passwd:               passwd,
-		eventFilters:         eventFilters,
-		eventHandlers:        eventHandlers,
-		reconnects:           reconnects,
-		maxReconnectInterval: maxReconnectInterval,
-		replyTimeout:         replyTimeout,
-		delayFunc:            delayFunc,
-		logger:               logger,
-		bgapi:                bgapi,
-		stopError:            stopError,
-	}
+	return NewFSockWithOptions(addr, passwd,
+		WithReconnects(reconnects),
+		WithMaxReconnectInterval(maxReconnectInterval),
+		WithReplyTimeout(replyTimeout),
+		WithDelayFunc(delayFunc),
+		WithEventHandlers(eventHandlers),
+		WithEventFilters(eventFilters),
+		WithLogger(logger),
+		WithConnIdx(connIdx),
+		WithBgapi(bgapi),
+		WithStopError(stopError),
+	)
+}
+
+// NewFSockWithOptions connects to FS and starts buffering input, configured by opts.
+func NewFSockWithOptions(addr, passwd string, opts ...Option) (fsock *FSock, err error) {
+	fsock = newFSock(addr, passwd, opts...)
 	if err = fsock.Connect(); err != nil {
 		return nil, err
 	}
 	return
 }
 
+// newFSock builds a FSock with the defaults overwritten by opts, without connecting it.
+func newFSock(addr, passwd string, opts ...Option) *FSock {
+	fsock := &FSock{
+		mu:        new(sync.RWMutex),
+		addr:      addr,
+		passwd:    passwd,
+		delayFunc: FibDuration,
+	}
+	for _, opt := range opts {
+		opt(fsock)
+	}
+	fsock.logger = fsock.usableLogger(fsock.logger)
+	if fsock.delayFunc == nil {
+		fsock.delayFunc = FibDuration
+	}
+	// Owned by the FSock, altered at runtime, the map provided by the caller can be shared, e.g. in a pool
+	fsock.eventHandlers = cloneMap(fsock.eventHandlers)
+	if fsock.eventHandlers == nil {
+		fsock.eventHandlers = make(map[string][]func(string, int))
+	}
+	for evName, handlers := range fsock.typedEventHandlers { // adapted to the plain ones
+		for _, handler := range handlers {
+			fsock.eventHandlers[evName] = append(fsock.eventHandlers[evName], EventHandler(handler))
+		}
+	}
+	fsock.typedEventHandlers = nil
+	for evName, handlers := range fsock.infoEventHandlers { // adapted as the typed ones
+		for _, handler := range handlers {
+			fsock.eventHandlers[evName] = append(fsock.eventHandlers[evName], ConnInfoHandler(fsock.ConnInfo(), handler))
+		}
+	}
+	fsock.infoEventHandlers = nil
+	for evName, handler := range fsock.removableHandlers { // last, as the ones added at runtime
+		fsock.lastHandlerID++
+		fsock.eventHandlers[evName] = append(fsock.eventHandlers[evName], handler)
+		if fsock.handlerIDs == nil {
+			fsock.handlerIDs = make(map[string][]HandlerID)
+		}
+		fsock.handlerIDs[evName] = append(fsock.handlerIDs[evName], fsock.lastHandlerID)
+		fsock.removableIDs[evName] = fsock.lastHandlerID
+	}
+	fsock.removableHandlers, fsock.removableIDs = nil, nil
+	if fsock.ctxEventHandlers == nil {
+		fsock.ctxEventHandlers = make(map[string][]EventHandlerCtx)
+	}
+	if fsock.eventFilters = cloneMap(fsock.eventFilters); fsock.eventFilters == nil {
+		fsock.eventFilters = make(map[string][]string)
+	}
+	return fsock
+}
+
 // FSock reperesents the connection to FreeSWITCH Socket
 type FSock struct {
 	mu      *sync.RWMutex
@@ -63,24 +128,69 @@ type FSock struct {
 	passwd  string
 	fsConn  *FSConn
 
+	passwdProvider func() (string, error) // takes over passwd when set, called on every connect
+
+	standbyAddrs     []string      // tried in order after addr fails
+	addrIdx          int           // index in connAddrs of the address in use
+	failbackInterval time.Duration // probing the primary while on standby, disabled if 0
+
 	reconnects           int
 	maxReconnectInterval time.Duration
+	reconnectBudget      time.Duration // bounds the time spent reconnecting, unlimited if 0
 	replyTimeout         time.Duration
 	delayFunc            func(time.Duration, time.Duration) func() time.Duration // used to create/reset the delay function
+	backoff              BackoffPolicy                                           // takes over delayFunc when set
 
-	eventFilters  map[string][]string
-	eventHandlers map[string][]func(string, int) // eventStr, connId
+	eventFilters       map[string][]string
+	eventHandlers      map[string][]func(string, int)      // eventStr, connId
+	handlerIDs         map[string][]HandlerID              // of the handlers added at runtime, ending eventHandlers
+	lastHandlerID      HandlerID                           // the last one handed out by AddEventHandler
+	removableHandlers  map[string]func(string, int)        // added on construction as if by AddEventHandler
+	removableIDs       map[string]HandlerID                // receives the IDs of removableHandlers
+	typedEventHandlers map[string][]func(Event, int)       // merged into eventHandlers on construction
+	infoEventHandlers  map[string][]func(string, ConnInfo) // merged into eventHandlers on construction
+	ctxEventHandlers   map[string][]EventHandlerCtx        // handlers receiving a context cancelled on disconnect
+	myEventsUUID       string                              // call leg subscribed to with myevents
+	myEventsHandler    func(string, int)                   // receives the events of myEventsUUID
+	logLevel           string                              // level of the console log subscribed to
+	logHandler         func(string, int)                   // receives the console log lines
+	eventsPaused       bool                                // set by PauseEvents, until ResumeEvents
 
-	logger    logger
+	logMu     sync.RWMutex // protects logger, swapped by SetLogger
+	logger    Logger
 	bgapi     bool
-	stopError chan error // will communicate on final disconnect
+	stopError chan error  // will communicate on final disconnect
+	onError   func(error) // invoked on final disconnect, next to stopError
+
+	stopMu        sync.Mutex              // protects stopListeners
+	stopListeners map[chan error]struct{} // receive the final disconnects, see StopErrors
+	tlsConfig     *tls.Config             // connect over TLS when not nil
+	connOptions                           // handed over to every FSConn
+
+	onConnect     ConnHook // invoked once the first connection is established
+	onDisconnect  ConnHook // invoked whenever the connection drops or is closed
+	onReconnect   ConnHook // invoked once the connection is re-established
+	connectedOnce bool     // tells OnConnect and OnReconnect apart
+
+	shutdown atomic.Bool  // set by Shutdown, no reconnecting until Connect
+	state    atomic.Int32 // ConnState, changed through setState
 }
 
 // Connect adds locking to connect method.
 func (fs *FSock) Connect() (err error) {
+	return fs.ConnectCtx(context.Background())
+}
+
+// ConnectCtx is the same as Connect, aborting the dial and handshake once ctx is done.
+func (fs *FSock) ConnectCtx(ctx context.Context) (err error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
-	return fs.connect()
+	fs.shutdown.Store(false)
+	fs.setState(StateConnecting)
+	if err = fs.connectCtx(ctx); err != nil {
+		fs.setState(StateDisconnected, StateConnecting)
+	}
+	return
 }
 
 // connect establishes a connection to FreeSWITCH using the provided configuration details.
@@ -88,58 +198,289 @@ func (fs *FSock) Connect() (err error) {
 // from multiple goroutines. Upon encountering read errors, it automatically attempts to
 // restart the connection unless the error is intentionally triggered for stopping.
 func (fs *FSock) connect() (err error) {
+	return fs.connectCtx(context.Background())
+}
+
+// connectCtx is the context-aware version of connect. Not thread-safe either.
+func (fs *FSock) connectCtx(ctx context.Context) (err error) {
 
 	// Create an error channel to listen for connection errors.
 	connErr := make(chan error)
 
 	// Initialize a new FSConn connection instance. Pass configuration and the error channel.
-	fs.fsConn, err = NewFSConn(fs.addr, fs.passwd, fs.connIdx, fs.replyTimeout, connErr,
-		fs.logger, fs.eventFilters, fs.eventHandlers, fs.bgapi)
+	// With standby addresses, each of them gets one attempt, starting with the one in use.
+	passwd := fs.passwd
+	if fs.passwdProvider != nil {
+		if passwd, err = fs.passwdProvider(); err != nil {
+			return fmt.Errorf("failed to get the password: %w", err)
+		}
+	}
+	addrs := fs.connAddrs()
+	for range addrs {
+		fs.fsConn, err = newFSConnCtx(ctx, addrs[fs.addrIdx], passwd, fs.connIdx, fs.replyTimeout, connErr,
+			fs.getLogger(), fs.eventFilters, fs.eventHandlers, fs.ctxEventHandlers, fs.bgapi, fs.tlsConfig, fs.connOptions)
+		if err == nil || len(addrs) == 1 || ctx.Err() != nil {
+			break
+		}
+		fs.addrIdx = (fs.addrIdx + 1) % len(addrs)
+		fs.log("addr", addrs[fs.addrIdx]).Warning(fmt.Sprintf(
+			"<FSock> Failing over to FreeSWITCH at %s (connection index: %d)",
+			addrs[fs.addrIdx], fs.connIdx))
+	}
 	if err != nil {
 		return err
 	}
+	if err = fs.restoreSubscriptions(); err != nil {
+		// Partially subscribed, the connection would silently miss events.
+		fs.log().Err(fmt.Sprintf("<FSock> Dropping the connection to FreeSWITCH (connection index: %d): %v",
+			fs.connIdx, err))
+		fs.fsConn.conn.Close()
+		<-connErr // the reading ends with the connection
+		fs.fsConn = nil
+		return err
+	}
+	fs.setState(StateConnected)
+	remoteAddr := fs.fsConn.conn.RemoteAddr()
 
 	// Start a goroutine to handle automatic reconnects in case the connection drops.
-	go fs.handleConnectionError(connErr)
+	go fs.handleConnectionError(fs.fsConn)
+	if fs.addrIdx != 0 && fs.failbackInterval > 0 {
+		go fs.failback(fs.fsConn)
+	}
+
+	hook := fs.onConnect
+	if fs.connectedOnce {
+		hook = fs.onReconnect
+		if fs.metrics != nil {
+			fs.metrics.reconnects.Add(1)
+		}
+	}
+	fs.connectedOnce = true
+	if hook != nil {
+		go hook(fs.connIdx, remoteAddr)
+	}
 
 	return
 }
 
+// connAddrs returns the addresses to connect to, the primary first.
+func (fs *FSock) connAddrs() []string {
+	return append([]string{fs.addr}, fs.standbyAddrs...)
+}
+
+// failback probes the primary address while connected to a standby one, through fsConn,
+// replacing the connection once the primary is reachable again.
+func (fs *FSock) failback(fsConn *FSConn) {
+	tk := time.NewTicker(fs.failbackInterval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-fsConn.ctx.Done():
+			return
+		case <-tk.C:
+		}
+		if !fs.probePrimary(fsConn.ctx) {
+			continue
+		}
+		fs.mu.Lock()
+		if fs.fsConn != fsConn { // replaced meanwhile
+			fs.mu.Unlock()
+			return
+		}
+		prevIdx := fs.addrIdx
+		fs.addrIdx = 0
+		if err := fs.connect(); err != nil {
+			fs.log("addr", fs.addr).Warning(fmt.Sprintf(
+				"<FSock> Failed to fail back to FreeSWITCH at %s (connection index: %d): %v",
+				fs.addr, fs.connIdx, err))
+			fs.fsConn, fs.addrIdx = fsConn, prevIdx
+			fs.mu.Unlock()
+			continue
+		}
+		fs.log("addr", fs.connAddrs()[fs.addrIdx]).Info(fmt.Sprintf(
+			"<FSock> Reconnected to FreeSWITCH at %s (connection index: %d)",
+			fs.connAddrs()[fs.addrIdx], fs.connIdx))
+		fsConn.replaced.Store(true)
+		fsConn.Disconnect()
+		fs.mu.Unlock()
+		return
+	}
+}
+
+// probePrimary checks if the primary address accepts connections again.
+func (fs *FSock) probePrimary(ctx context.Context) bool {
+	if fs.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fs.dialTimeout)
+		defer cancel()
+	}
+	conn, err := dial(ctx, fs.addr, fs.tlsConfig, fs.dialer())
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// restoreSubscriptions re-issues on the new connection the subscriptions made at runtime.
+// A rejected or failed one fails the connection, not to silently miss its events, except
+// for myevents of a channel gone meanwhile, which is dropped.
+func (fs *FSock) restoreSubscriptions() error {
+	if fs.myEventsHandler != nil {
+		err := fs.fsConn.subscribeMyEvents(fs.myEventsUUID, fs.myEventsHandler)
+		switch {
+		case errors.Is(err, ErrNoSuchChannel): // hung up meanwhile, nothing left to follow
+			fs.log().Warning(fmt.Sprintf(
+				"<FSock> Dropping myevents for %s (connection index: %d): %v",
+				fs.myEventsUUID, fs.connIdx, err))
+			fs.myEventsUUID, fs.myEventsHandler = "", nil
+		case err != nil:
+			return fmt.Errorf("failed to restore myevents for %s: %w", fs.myEventsUUID, err)
+		}
+	}
+	if fs.logHandler != nil {
+		if err := fs.fsConn.subscribeLog(fs.logLevel, fs.logHandler); err != nil {
+			return fmt.Errorf("failed to restore the log subscription: %w", err)
+		}
+	}
+	if fs.eventsPaused {
+		if err := fs.fsConn.pauseEvents(fs.bgapi); err != nil {
+			return fmt.Errorf("failed to keep the events paused: %w", err)
+		}
+	}
+	return nil
+}
+
 // handleConnectionError listens for connection errors and decides whether to attempt a
 // reconnection. It logs errors and manages the stopError channel signaling based on the
 // encountered error.
-func (fs *FSock) handleConnectionError(connErr chan error) {
-	err := <-connErr // Wait for an error signal from readEvents.
-	fs.logger.Err(fmt.Sprintf("<FSock> readEvents error (connection index: %d): %v", fs.connIdx, err))
-	if err != io.EOF {
+func (fs *FSock) handleConnectionError(fsConn *FSConn) {
+	err := <-fsConn.err // Wait for an error signal from readEvents.
+	fs.log().Err(fmt.Sprintf("<FSock> readEvents error (connection index: %d): %v", fs.connIdx, err))
+	if fs.onDisconnect != nil {
+		go fs.onDisconnect(fs.connIdx, fsConn.conn.RemoteAddr())
+	}
+	if fsConn.replaced.Load() {
+		return // failed back, the new connection is already in place
+	}
+	if err != io.EOF && err != ErrStaleConnection {
 		// Signal nil error for intentional shutdowns.
+		fs.setState(StateDisconnected, StateConnected) // closed already if intentional
 		fs.signalError(nil)
 		return // don't attempt reconnect
 	}
+	fs.setState(StateReconnecting, StateConnected) // not waiting for the lock to tell
 
-	// Attempt to reconnect if the error indicates a dropped connection (io.EOF).
+	// Attempt to reconnect if the error indicates a dropped (io.EOF) or stale connection.
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
 	if err := fs.disconnect(); err != nil {
-		fs.logger.Warning(fmt.Sprintf(
+		fs.log().Warning(fmt.Sprintf(
 			"<FSock> Failed to disconnect from FreeSWITCH (connection index: %d): %v",
 			fs.connIdx, err))
 	}
 	if err = fs.reconnectIfNeeded(); err != nil {
-		fs.logger.Err(fmt.Sprintf(
+		fs.log().Err(fmt.Sprintf(
 			"<FSock> Failed to reconnect to FreeSWITCH (connection index: %d): %v",
 			fs.connIdx, err))
 		fs.signalError(err)
 	}
 }
 
-// signalError handles logging or sending the error to the stopError channel.
+// log returns the logger of the FSock, with the connection fields, see ConnInfo, and
+// the args fields attached if the logger supports them.
+func (fs *FSock) log(args ...any) Logger {
+	return fs.ConnInfo().logger(fs.getLogger(), args...)
+}
+
+// ConnInfo returns the identification of the FSock connections.
+func (fs *FSock) ConnInfo() ConnInfo {
+	return ConnInfo{Idx: fs.connIdx, ID: fs.connID, Metadata: fs.connMetadata}
+}
+
+// getLogger returns the logger in use.
+func (fs *FSock) getLogger() Logger {
+	fs.logMu.RLock()
+	defer fs.logMu.RUnlock()
+	return fs.logger
+}
+
+// usableLogger returns lgr ready for use: nopLogger if nil, redacting if configured so.
+func (fs *FSock) usableLogger(lgr Logger) Logger {
+	if lgr == nil ||
+		(reflect.ValueOf(lgr).Kind() == reflect.Ptr && reflect.ValueOf(lgr).IsNil()) {
+		return nopLogger{}
+	}
+	if fs.redactor != nil {
+		return newRedactingLogger(lgr, fs.redactor)
+	}
+	return lgr
+}
+
+// SetLogger replaces the logger, on the active connection as well, e.g. after reopening
+// the syslog writer. A nil logger disables the logging.
+func (fs *FSock) SetLogger(lgr Logger) {
+	lgr = fs.usableLogger(lgr)
+	fs.logMu.Lock()
+	fs.logger = lgr
+	fs.logMu.Unlock()
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fs.fsConn != nil {
+		fs.fsConn.SetLogger(lgr)
+	}
+}
+
+// StopErrors returns a channel receiving the error of every final disconnect, nil if the
+// connection was closed on purpose, as the stopError channel does, to any number of
+// listeners. Buffered, it keeps only the latest error not received yet, so a slow listener
+// neither blocks the FSock nor misses the last one. The channel is closed once ctx is done.
+func (fs *FSock) StopErrors(ctx context.Context) <-chan error {
+	stopErrs := make(chan error, 1)
+	fs.stopMu.Lock()
+	if fs.stopListeners == nil {
+		fs.stopListeners = make(map[chan error]struct{})
+	}
+	fs.stopListeners[stopErrs] = struct{}{}
+	fs.stopMu.Unlock()
+	context.AfterFunc(ctx, func() {
+		fs.stopMu.Lock()
+		defer fs.stopMu.Unlock()
+		delete(fs.stopListeners, stopErrs)
+		close(stopErrs)
+	})
+	return stopErrs
+}
+
+// notifyStop hands err to the StopErrors listeners, telling if there were any.
+func (fs *FSock) notifyStop(err error) bool {
+	fs.stopMu.Lock()
+	defer fs.stopMu.Unlock()
+	for stopErrs := range fs.stopListeners {
+		select {
+		case <-stopErrs: // replaced by the latest
+		default:
+		}
+		stopErrs <- err
+	}
+	return len(fs.stopListeners) != 0
+}
+
+// signalError handles logging or sending the error to the stopError channel, and to
+// the onError callback.
 func (fs *FSock) signalError(err error) {
+	if fs.onError != nil {
+		go fs.onError(err)
+	}
+	listened := fs.notifyStop(err)
 	if fs.stopError == nil {
+		if fs.onError != nil || listened {
+			return // informed through the callback or the listeners
+		}
 		// No stopError channel designated. Log the error if not nil.
 		if err != nil {
-			fs.logger.Err(fmt.Sprintf(
+			fs.log().Err(fmt.Sprintf(
 				"<FSock> Error encountered while reading events (connection index: %d): %v",
 				fs.connIdx, err))
 		}
@@ -149,11 +490,52 @@ func (fs *FSock) signalError(err error) {
 	fs.stopError <- err
 }
 
-// Connected adds up locking on top of normal connected method.
-func (fs *FSock) Connected() (ok bool) {
+// SetReplyTimeout changes the time the commands wait for their replies, on the active
+// connection as well as the ones established later, disabled if not positive.
+func (fs *FSock) SetReplyTimeout(replyTimeout time.Duration) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.replyTimeout = replyTimeout
+	if fs.fsConn != nil {
+		fs.fsConn.SetReplyTimeout(replyTimeout)
+	}
+}
+
+// DispatchQueueDepth returns the number of handler calls waiting for a dispatch worker
+// of the current connection, see WithDispatchWorkers.
+func (fs *FSock) DispatchQueueDepth() int {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
-	return fs.connected()
+	if fs.fsConn == nil {
+		return 0
+	}
+	return fs.fsConn.DispatchQueueDepth()
+}
+
+// DroppedEvents returns the number of events the current connection dropped because
+// of the full event queue, see WithEventQueue.
+func (fs *FSock) DroppedEvents() uint64 {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fs.fsConn == nil {
+		return 0
+	}
+	return fs.fsConn.DroppedEvents()
+}
+
+// EventLag returns the lag of the last event received, 0 if not measured, see WithEventLag.
+func (fs *FSock) EventLag() time.Duration {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fs.lag == nil {
+		return 0
+	}
+	return time.Duration(fs.lag.last.Load())
+}
+
+// Connected tells if the connection is up, its State being StateConnected.
+func (fs *FSock) Connected() (ok bool) {
+	return fs.State() == StateConnected
 }
 
 // connected checks if socket connected. Not thread safe.
@@ -161,23 +543,65 @@ func (fs *FSock) connected() (ok bool) {
 	return fs.fsConn != nil
 }
 
-// Disconnect adds up locking for disconnect
+// Disconnect closes the connection, first waiting for the lingered events if FreeSWITCH
+// is to close it, see FSConn.Disconnect.
 func (fs *FSock) Disconnect() (err error) {
+	fs.setState(StateClosed) // before closing, not to be taken for a lost connection
 	fs.mu.Lock()
-	defer fs.mu.Unlock()
-	return fs.disconnect()
+	fs.setState(StateClosed) // over the reconnect which might have completed meanwhile
+	fsConn := fs.fsConn
+	fs.fsConn = nil
+	fs.mu.Unlock()
+	if fsConn == nil {
+		return
+	}
+	fs.log().Info("<FSock> Disconnecting from FreeSWITCH!")
+	return fsConn.Disconnect() // outside the lock, as it may wait for the lingered events
 }
 
 // Disconnect disconnects from socket
 func (fs *FSock) disconnect() (err error) {
 	if fs.fsConn != nil {
-		fs.logger.Info("<FSock> Disconnecting from FreeSWITCH!")
+		fs.log().Info("<FSock> Disconnecting from FreeSWITCH!")
 		err = fs.fsConn.Disconnect()
 		fs.fsConn = nil
 	}
 	return
 }
 
+// Shutdown disconnects gracefully: refuses the new commands and the reconnects, waits for
+// the pending commands and bgapi jobs, then for the events already read to be handled, see
+// FSConn.Shutdown. Once ctx is done it stops waiting, disconnecting right away and returning
+// the ctx error. Connect brings the FSock back.
+func (fs *FSock) Shutdown(ctx context.Context) error {
+	fs.shutdown.Store(true)
+	fs.setState(StateClosed)
+	locked := make(chan struct{})
+	go func() {
+		fs.mu.Lock() // waits for the reconnect in progress
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			defer fs.mu.Unlock()
+			fs.disconnect()
+		}()
+		return ctx.Err()
+	}
+	fs.setState(StateClosed) // over the reconnect which might have completed meanwhile
+	fsConn := fs.fsConn
+	fs.fsConn = nil // the handlers sending commands meanwhile get ErrShutdown
+	fs.mu.Unlock()
+	if fsConn == nil {
+		return nil
+	}
+	fs.log().Info("<FSock> Shutting down the connection to FreeSWITCH!")
+	return fsConn.Shutdown(ctx)
+}
+
 // ReconnectIfNeeded adds up locking to reconnectIfNeeded
 func (fs *FSock) ReconnectIfNeeded() (err error) {
 	fs.mu.Lock()
@@ -187,33 +611,396 @@ func (fs *FSock) ReconnectIfNeeded() (err error) {
 
 // reconnectIfNeeded if not connected, attempt reconnect if allowed
 func (fs *FSock) reconnectIfNeeded() (err error) {
+	return fs.reconnectIfNeededCtx(context.Background())
+}
+
+// reconnectIfNeededCtx is the same as reconnectIfNeeded, giving up on reconnecting once ctx is done.
+func (fs *FSock) reconnectIfNeededCtx(ctx context.Context) (err error) {
 	if fs.connected() { // No need to reconnect
 		return
 	}
-	delay := fs.delayFunc(time.Second, fs.maxReconnectInterval)
+	if fs.shutdown.Load() {
+		return ErrShutdown
+	}
+	if fs.failbackInterval > 0 {
+		fs.addrIdx = 0 // prefer the primary
+	}
+	backoff := fs.backoff
+	if backoff == nil {
+		backoff = BackoffFromDelayFunc(fs.delayFunc, time.Second, fs.maxReconnectInterval)
+	}
+	fs.setState(StateReconnecting)
+	defer func() {
+		if err != nil {
+			fs.setState(StateDisconnected, StateReconnecting)
+		}
+	}()
+	backoff.Reset()
+	deadline := time.Now().Add(fs.reconnectBudget)
+	connCtx := ctx
+	if fs.reconnectBudget > 0 { // a connect hanging, e.g. on auth, stops with the budget too
+		var cancel context.CancelFunc
+		connCtx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
 	for i := 0; fs.reconnects == -1 || i < fs.reconnects; i++ { // Maximum reconnects reached, -1 for infinite reconnects
-		if err = fs.connect(); err == nil && fs.connected() {
+		if err = fs.connectCtx(connCtx); err == nil && fs.connected() {
 			break // No error or unrelated to connection
 		}
-		time.Sleep(delay())
+		delay := backoff.Next()
+		if fs.reconnectBudget > 0 && time.Now().Add(delay).After(deadline) {
+			if err == nil {
+				err = ErrNotConnected
+			}
+			return fmt.Errorf("%w after %v: %w", ErrReconnectBudget, fs.reconnectBudget, err)
+		}
+		tm := time.NewTimer(delay)
+		select {
+		case <-tm.C:
+		case <-ctx.Done():
+			tm.Stop()
+			return ctx.Err()
+		}
 	}
 	if err == nil && !fs.connected() {
-		return errors.New("not connected to FreeSWITCH")
+		return ErrNotConnected
 	}
 	return // nil or last error in the loop
 }
 
-// Generic proxy for commands
-func (fs *FSock) SendCmd(cmdStr string) (rply string, err error) {
-	fs.mu.Lock() // make sure the fsConn does not get nil-ed after the reconnect
+// HandlerID identifies an event handler added with AddEventHandler, to remove it with
+// RemoveEventHandler.
+type HandlerID uint64
+
+// AddEventHandler registers a handler for the event, subscribing to it on the live
+// connection if needed. The handler is kept over reconnects, even if subscribing failed,
+// until removed by the returned ID.
+func (fs *FSock) AddEventHandler(eventName string, handler func(string, int)) (id HandlerID, err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.lastHandlerID++
+	id = fs.lastHandlerID
+	if fs.eventHandlers == nil {
+		fs.eventHandlers = make(map[string][]func(string, int))
+	}
+	if fs.handlerIDs == nil {
+		fs.handlerIDs = make(map[string][]HandlerID)
+	}
+	fs.eventHandlers[eventName] = append(fs.eventHandlers[eventName], handler)
+	fs.handlerIDs[eventName] = append(fs.handlerIDs[eventName], id)
+	if !fs.connected() {
+		return
+	}
+	err = fs.fsConn.addEventHandler(eventName, handler)
+	return
+}
+
+// RemoveEventHandler unregisters the handler added with AddEventHandler under id, and
+// sends nixevent once no handlers are left for the event. Unknown IDs are ignored.
+func (fs *FSock) RemoveEventHandler(id HandlerID) (err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for eventName, ids := range fs.handlerIDs {
+		idx := slices.Index(ids, id)
+		if idx == -1 {
+			continue
+		}
+		handlers := fs.eventHandlers[eventName]
+		hIdx := len(handlers) - len(ids) + idx // the runtime handlers end the list, in the same order
+		if handlers = slices.Delete(slices.Clone(handlers), hIdx, hIdx+1); len(handlers) != 0 {
+			fs.eventHandlers[eventName] = handlers
+		} else {
+			delete(fs.eventHandlers, eventName)
+		}
+		if ids = slices.Delete(slices.Clone(ids), idx, idx+1); len(ids) != 0 {
+			fs.handlerIDs[eventName] = ids
+		} else {
+			delete(fs.handlerIDs, eventName)
+		}
+		if !fs.connected() {
+			return
+		}
+		return fs.fsConn.setEventHandlers(eventName, handlers, fs.bgapi)
+	}
+	return
+}
+
+// AddFilter filters the events on header value, on the live connection as well as
+// on the ones established after reconnects. Both are checked with CheckArg.
+func (fs *FSock) AddFilter(header, value string) (err error) {
+	if err = checkFilter(header, value); err != nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if slices.Contains(fs.eventFilters[header], value) {
+		return
+	}
+	firstFilter := len(fs.eventFilters) == 0
+	if fs.eventFilters == nil {
+		fs.eventFilters = make(map[string][]string)
+	}
+	fs.eventFilters[header] = append(fs.eventFilters[header], value)
+	if !fs.connected() {
+		return
+	}
+	if firstFilter { // once filtered, the events the connection relies on need their own filters
+		for _, evName := range fs.ownFilters(fs.bgapi) {
+			if header == "Event-Name" && value == evName {
+				continue
+			}
+			if err = fs.fsConn.addFilter("Event-Name", evName); err != nil {
+				return
+			}
+		}
+	}
+	return fs.fsConn.addFilter(header, value)
+}
+
+// DeleteFilter removes the filter on header value previously set.
+func (fs *FSock) DeleteFilter(header, value string) (err error) {
+	if err = checkFilter(header, value); err != nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if !slices.Contains(fs.eventFilters[header], value) {
+		return
+	}
+	if vals := slices.DeleteFunc(slices.Clone(fs.eventFilters[header]),
+		func(v string) bool { return v == value }); len(vals) != 0 {
+		fs.eventFilters[header] = vals
+	} else {
+		delete(fs.eventFilters, header)
+	}
+	if !fs.connected() {
+		return
+	}
+	own := fs.ownFilters(fs.bgapi)
+	isOwn := header == "Event-Name" && slices.Contains(own, value)
+	if isOwn && len(fs.eventFilters) != 0 {
+		return // still needed by the connection as long as filtered
+	}
+	if err = fs.fsConn.deleteFilter(header, value); err != nil || len(fs.eventFilters) != 0 {
+		return
+	}
+	for _, evName := range own { // unfiltered, all the events pass
+		if header == "Event-Name" && value == evName {
+			continue
+		}
+		if err = fs.fsConn.deleteFilter("Event-Name", evName); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// checkFilter returns ErrUnsafeArg if the filter on header value would break out of
+// the filter command.
+func checkFilter(header, value string) error {
+	if err := CheckArg(header); err != nil {
+		return err
+	}
+	return CheckArg(value)
+}
+
+// SubscribeMyEvents restricts the connection to the events of the call leg identified
+// by uuid, delivering them to handler next to the event name handlers. Meant for
+// connections dedicated to one call, the subscription is restored after reconnects.
+// The uuid is checked with CheckArg.
+func (fs *FSock) SubscribeMyEvents(uuid string, handler func(string, int)) (err error) {
+	if err = CheckArg(uuid); err != nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err = fs.reconnectIfNeeded(); err != nil {
+		return
+	}
+	if err = fs.fsConn.subscribeMyEvents(uuid, handler); err != nil {
+		return
+	}
+	fs.myEventsUUID, fs.myEventsHandler = uuid, handler
+	return
+}
+
+// Events returns a channel receiving the events named, all of them if none given, as an
+// alternative to the handlers. Only the events subscribed to, through the event handlers
+// or AddEventHandler, are received. The channel is closed once ctx is done and keeps
+// working across reconnects. The events are delivered in the order FreeSWITCH sent them,
+// whatever the dispatch mode, being queued meanwhile for the consumers not keeping up.
+// Each consumer queues up to the capacity set with WithEventQueue, DefaultEventStreamSize
+// if not set, overflowing as its policy says, reported with ErrEventDropped.
+func (fs *FSock) Events(ctx context.Context, eventNames ...string) <-chan Event {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.streams == nil {
+		fs.streams = &eventStreams{size: fs.eventQueueSize, policy: fs.overflowPolicy}
+		if fs.fsConn != nil {
+			fs.fsConn.setStreams(fs.streams)
+		}
+	}
+	return fs.streams.add(ctx, eventNames)
+}
+
+// SubscribeLog subscribes to the FreeSWITCH console log at level (e.g. "debug", "info" or 0-7),
+// delivering every log/data payload to handler. The payload keeps the headers (Log-Level,
+// Log-File, etc.) followed by an empty line and the log text. Restored after reconnects.
+// The level names are console, alert, crit, err, warning, notice, info and debug, any case.
+func (fs *FSock) SubscribeLog(level string, handler func(string, int)) (err error) {
+	if !isLogLevel(level) {
+		return fmt.Errorf("unsupported log level: <%s>", level)
+	}
+	fs.mu.Lock()
 	defer fs.mu.Unlock()
 	if err = fs.reconnectIfNeeded(); err != nil {
 		return
 	}
-	return fs.fsConn.Send(cmdStr + "\n") // ToDo: check if we have to send a secondary new line
+	if err = fs.fsConn.subscribeLog(level, handler); err != nil {
+		return
+	}
+	fs.logLevel, fs.logHandler = level, handler
+	return
+}
+
+// logLevels are the names of the FreeSWITCH log levels, 0 to 7.
+var logLevels = []string{"console", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// isLogLevel reports whether level is one of the log level names or numbers log accepts.
+func isLogLevel(level string) bool {
+	if n, err := strconv.Atoi(level); err == nil {
+		return n >= 0 && n < len(logLevels)
+	}
+	return slices.Contains(logLevels, strings.ToLower(level))
+}
+
+// UnsubscribeLog stops the console log subscription, sending nolog.
+func (fs *FSock) UnsubscribeLog() (err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.logLevel, fs.logHandler = "", nil
+	if !fs.connected() {
+		return
+	}
+	return fs.fsConn.unsubscribeLog()
+}
+
+// PauseEvents stops the event delivery through noevents, keeping the connection up, e.g.
+// to shed load. The bgapi results and the heartbeats needed by the watchdog keep coming.
+// The pause survives reconnects, until ResumeEvents.
+func (fs *FSock) PauseEvents() (err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.connected() {
+		if err = fs.fsConn.pauseEvents(fs.bgapi); err != nil {
+			return
+		}
+	}
+	fs.eventsPaused = true
+	return
+}
+
+// ResumeEvents subscribes again to the events paused by PauseEvents, the ones of the
+// handlers added meanwhile included.
+func (fs *FSock) ResumeEvents() (err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.connected() {
+		if err = fs.fsConn.resumeEvents(fs.bgapi); err != nil {
+			return
+		}
+	}
+	fs.eventsPaused = false
+	return
+}
+
+// SetLinger enables linger for the given seconds, or disables it if not positive, on the
+// active connection as well as on the ones established after reconnects.
+func (fs *FSock) SetLinger(seconds int) (err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.connected() {
+		if err = fs.fsConn.setLinger(seconds); err != nil {
+			return
+		}
+	}
+	fs.linger = seconds
+	return
+}
+
+// PingError is returned by Ping when the connection is not usable.
+type PingError struct {
+	ConnIdx int
+	Err     error
+}
+
+func (e *PingError) Error() string {
+	return fmt.Sprintf("ping failed (connection index: %d): %v", e.ConnIdx, e.Err)
+}
+
+func (e *PingError) Unwrap() error { return e.Err }
+
+// Ping checks that the connection is actually usable by issuing api status, without
+// reconnecting. Connected only checks for a connection to exist, even a silently dropped one.
+func (fs *FSock) Ping() error {
+	return fs.PingCtx(context.Background())
+}
+
+// PingCtx is the same as Ping, bound by ctx.
+func (fs *FSock) PingCtx(ctx context.Context) error {
+	fs.mu.RLock()
+	fsConn, connIdx := fs.fsConn, fs.connIdx
+	connected := fs.connected()
+	fs.mu.RUnlock()
+	if !connected {
+		return &PingError{ConnIdx: connIdx, Err: ErrNotConnected}
+	}
+	rply, err := fsConn.SendCtx(ctx, "api status\n\n")
+	if err == nil && strings.TrimSpace(rply) == "" {
+		err = errors.New("empty status reply")
+	}
+	if err != nil {
+		return &PingError{ConnIdx: connIdx, Err: err}
+	}
+	return nil
+}
+
+// Generic proxy for commands
+func (fs *FSock) SendCmd(cmdStr string) (rply string, err error) {
+	return fs.SendCmdCtx(context.Background(), cmdStr)
+}
+
+// SendCmdCtx is the same as SendCmd, giving up on reconnecting and on waiting
+// for the reply once ctx is done.
+func (fs *FSock) SendCmdCtx(ctx context.Context, cmdStr string) (rply string, err error) {
+	fsConn, err := fs.activeConn(ctx)
+	if err != nil {
+		return
+	}
+	return fsConn.SendCtx(ctx, cmdStr+"\n") // ToDo: check if we have to send a secondary new line
+}
+
+// SendCmdReply is the same as SendCmd, returning the reply parsed into a Reply.
+// A -ERR reply is reported through Reply.OK, the error is kept for failing to get it.
+func (fs *FSock) SendCmdReply(cmdStr string) (Reply, error) {
+	return fs.SendCmdReplyCtx(context.Background(), cmdStr)
+}
+
+// SendCmdReplyCtx is the same as SendCmdReply, bound by ctx.
+func (fs *FSock) SendCmdReplyCtx(ctx context.Context, cmdStr string) (rply Reply, err error) {
+	fsConn, err := fs.activeConn(ctx)
+	if err != nil {
+		return
+	}
+	return fsConn.SendReplyCtx(ctx, cmdStr+"\n")
 }
 
+// SendCmdWithArgs sends cmd with the args as headers, followed by body if not empty.
+// The args are checked with CheckHeaders.
 func (fs *FSock) SendCmdWithArgs(cmd string, args map[string]string, body string) (string, error) {
+	if err := CheckHeaders(args); err != nil {
+		return "", err
+	}
 	for k, v := range args {
 		cmd += k + ": " + v + "\n"
 	}
@@ -225,7 +1012,41 @@ func (fs *FSock) SendCmdWithArgs(cmd string, args map[string]string, body string
 
 // Send API command
 func (fs *FSock) SendApiCmd(cmdStr string) (string, error) {
-	return fs.SendCmd("api " + cmdStr + "\n")
+	return fs.SendApiCmdCtx(context.Background(), cmdStr)
+}
+
+// SendApiCmdCtx is the same as SendApiCmd, bound by ctx.
+func (fs *FSock) SendApiCmdCtx(ctx context.Context, cmdStr string) (string, error) {
+	if err := checkCmd(cmdStr); err != nil {
+		return "", err
+	}
+	return fs.SendCmdCtx(ctx, "api "+cmdStr+"\n")
+}
+
+// SendApiCmdReply is the same as SendApiCmd, returning the reply parsed into a Reply.
+func (fs *FSock) SendApiCmdReply(cmdStr string) (Reply, error) {
+	if err := checkCmd(cmdStr); err != nil {
+		return Reply{}, err
+	}
+	return fs.SendCmdReply("api " + cmdStr + "\n")
+}
+
+// SendApiCmdStream is the same as SendApiCmd, streaming the reply body out of the
+// connection instead of buffering it, see FSConn.SendStream. The body must be closed.
+func (fs *FSock) SendApiCmdStream(cmdStr string) (io.ReadCloser, error) {
+	return fs.SendApiCmdStreamCtx(context.Background(), cmdStr)
+}
+
+// SendApiCmdStreamCtx is the same as SendApiCmdStream, bound by ctx.
+func (fs *FSock) SendApiCmdStreamCtx(ctx context.Context, cmdStr string) (io.ReadCloser, error) {
+	if err := checkCmd(cmdStr); err != nil {
+		return nil, err
+	}
+	fsConn, err := fs.activeConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fsConn.SendStreamCtx(ctx, "api "+cmdStr+"\n\n")
 }
 
 // SendMsgCmdWithBody command
@@ -233,6 +1054,9 @@ func (fs *FSock) SendMsgCmdWithBody(uuid string, cmdargs map[string]string, body
 	if len(cmdargs) == 0 {
 		return errors.New("need command arguments")
 	}
+	if err = CheckArg(uuid); err != nil {
+		return
+	}
 	_, err = fs.SendCmdWithArgs("sendmsg "+uuid+"\n", cmdargs, body)
 	return
 }
@@ -244,6 +1068,9 @@ func (fs *FSock) SendMsgCmd(uuid string, cmdargs map[string]string) error {
 
 // SendEventWithBody command
 func (fs *FSock) SendEventWithBody(eventSubclass string, eventParams map[string]string, body string) (string, error) {
+	if err := CheckArg(eventSubclass); err != nil {
+		return "", err
+	}
 	// Event-Name is overrided to CUSTOM by FreeSWITCH,
 	// so we use Event-Subclass instead
 	eventParams["Event-Subclass"] = eventSubclass
@@ -257,12 +1084,28 @@ func (fs *FSock) SendEvent(eventSubclass string, eventParams map[string]string)
 
 // Send BGAPI command
 func (fs *FSock) SendBgapiCmd(cmdStr string) (out chan string, err error) {
-	fs.mu.Lock()
+	return fs.SendBgapiCmdCtx(context.Background(), cmdStr)
+}
+
+// SendBgapiCmdCtx is the same as SendBgapiCmd, bound by ctx. Once ctx is done the job
+// is abandoned and the returned channel closed without a result.
+func (fs *FSock) SendBgapiCmdCtx(ctx context.Context, cmdStr string) (out chan string, err error) {
+	fsConn, err := fs.activeConn(ctx)
+	if err != nil {
+		return
+	}
+	return fsConn.SendBgapiCmdCtx(ctx, cmdStr)
+}
+
+// activeConn returns the connection to send the commands over, reconnecting if needed.
+// The lock is not held while awaiting the replies, so concurrent commands are pipelined.
+func (fs *FSock) activeConn(ctx context.Context) (*FSConn, error) {
+	fs.mu.Lock() // make sure the fsConn does not get nil-ed after the reconnect
 	defer fs.mu.Unlock()
-	if err := fs.reconnectIfNeeded(); err != nil {
-		return out, err
+	if err := fs.reconnectIfNeededCtx(ctx); err != nil {
+		return nil, err
 	}
-	return fs.fsConn.SendBgapiCmd(cmdStr)
+	return fs.fsConn, nil
 }
 
 func (fs *FSock) LocalAddr() net.Addr {
@@ -273,3 +1116,14 @@ func (fs *FSock) LocalAddr() net.Addr {
 	}
 	return fs.fsConn.LocalAddr()
 }
+
+// RemoteAddr returns the address of the FreeSWITCH the FSock is connected to, nil if not
+// connected. With standby addresses it tells which of them is in use.
+func (fs *FSock) RemoteAddr() net.Addr {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if !fs.connected() {
+		return nil
+	}
+	return fs.fsConn.RemoteAddr()
+}
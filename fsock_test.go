@@ -9,16 +9,31 @@ package fsock
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
 )
 
 const (
@@ -76,6 +91,40 @@ func TestEvent(t *testing.T) {
 	}
 }
 
+func TestReadHeaderBytesLongLine(t *testing.T) {
+	longHdr := "Reply-Text: +OK " + strings.Repeat("x", 64) + "\n"
+	fs := &FSConn{
+		lgr: nopLogger{},
+		rdr: bufio.NewReaderSize(strings.NewReader(longHdr+"Content-Type: command/reply\n\n"+HEADER), 16),
+	}
+	if hdr, err := fs.readHeaderBytes(); err != nil {
+		t.Error(err)
+	} else if exp := longHdr + "Content-Type: command/reply\n"; string(hdr) != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, string(hdr))
+	}
+	if hdr, err := fs.readHeaderBytes(); err != nil {
+		t.Error(err)
+	} else if exp := HEADER[:len(HEADER)-1]; string(hdr) != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, string(hdr))
+	}
+}
+
+func BenchmarkFSConnReadHeaderBytes(b *testing.B) {
+	rdr := strings.NewReader(HEADER)
+	fs := &FSConn{
+		lgr: nopLogger{},
+		rdr: bufio.NewReader(rdr),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rdr.Reset(HEADER)
+		fs.rdr.Reset(rdr)
+		if _, err := fs.readHeaderBytes(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestReadEvents(t *testing.T) {
 	data, err := os.ReadFile("test_data.txt")
 	if err != nil {
@@ -93,8 +142,12 @@ func TestReadEvents(t *testing.T) {
 		funcMutex.Unlock()
 	}
 
+	defer w.Close() // stops readEvents, closing the connection on EOF
+
 	fs := &FSConn{}
 	fs.lgr = nopLogger{}
+	fs.conn = &connMock3{}
+	fs.err = make(chan error, 1)
 	fs.rdr = bufio.NewReader(r)
 	fs.eventHandlers = map[string][]func(string, int){
 		"HEARTBEAT":                {evfunc},
@@ -316,7 +369,7 @@ func TestFSockAuthFailRead(t *testing.T) {
 func TestFSockSendBgapiCmdNonNilErr(t *testing.T) {
 	fs := &FSock{
 		mu:        &sync.RWMutex{},
-		delayFunc: fibDuration,
+		delayFunc: FibDuration,
 	}
 
 	expected := "not connected to FreeSWITCH"
@@ -327,19 +380,6 @@ func TestFSockSendBgapiCmdNonNilErr(t *testing.T) {
 	}
 }
 
-// fibDuration returns successive Fibonacci numbers converted to time.Duration.
-func fibDuration(durationUnit, maxDuration time.Duration) func() time.Duration {
-	a, b := 0, 1
-	return func() time.Duration {
-		a, b = b, a+b
-		fibNrAsDuration := time.Duration(a) * durationUnit
-		if maxDuration > 0 && maxDuration < fibNrAsDuration {
-			return maxDuration
-		}
-		return fibNrAsDuration
-	}
-}
-
 func TestFSockSendMsgCmdWithBodyEmptyArguments(t *testing.T) {
 	fs := &FSock{}
 	uuid := ""
@@ -380,7 +420,7 @@ func TestFSockLocalAddrNotConnected(t *testing.T) {
 func TestFSockReadEvents(t *testing.T) {
 	fs := &FSock{
 		mu:        &sync.RWMutex{},
-		delayFunc: fibDuration,
+		delayFunc: FibDuration,
 	}
 
 	expected := "not connected to FreeSWITCH"
@@ -422,12 +462,11 @@ func TestFSockSendCmdErrSend(t *testing.T) {
 
 func TestFSockSendCmdErrContains(t *testing.T) {
 	fs := &FSConn{
-		lgr:     nopLogger{},
-		conn:    &connMock3{},
-		replies: make(chan string, 1),
+		lgr:  nopLogger{},
+		conn: &connMock3{},
 	}
 
-	fs.replies <- "test-ERR"
+	go replyWhenAwaited(fs, "test-ERR")
 
 	expected := "test-ERR"
 	if rply, err := fs.Send("test"); err == nil || err.Error() != expected {
@@ -438,12 +477,26 @@ func TestFSockSendCmdErrContains(t *testing.T) {
 
 }
 
+// replyWhenAwaited delivers reply to the first command awaiting one on fsConn.
+func replyWhenAwaited(fsConn *FSConn, reply string) {
+	for {
+		fsConn.repliesMux.Lock()
+		waiting := len(fsConn.waiters) != 0
+		fsConn.repliesMux.Unlock()
+		if waiting {
+			fsConn.deliverReply(reply)
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func TestFSockReconnectIfNeeded(t *testing.T) {
 	fs := &FSock{
 		mu:         &sync.RWMutex{},
 		logger:     nopLogger{},
 		reconnects: 2,
-		delayFunc:  fibDuration,
+		delayFunc:  FibDuration,
 	}
 
 	expected := "dial tcp: missing address"
@@ -457,7 +510,7 @@ func TestFSockReconnectIfNeeded(t *testing.T) {
 func TestFSockSendMsgCmdWithBody(t *testing.T) {
 	fs := &FSock{
 		mu:        &sync.RWMutex{},
-		delayFunc: fibDuration,
+		delayFunc: FibDuration,
 	}
 	uuid := "testID"
 	cmdargs := map[string]string{
@@ -483,6 +536,53 @@ func TestFSockLocalAddr(t *testing.T) {
 	}
 }
 
+func TestFSockRemoteAddr(t *testing.T) {
+	fs := &FSock{
+		mu: &sync.RWMutex{},
+	}
+	if addr := fs.RemoteAddr(); addr != nil {
+		t.Errorf("\nExpected nil, got %v", addr)
+	}
+	standby := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := ln.Addr().String()
+	ln.Close() // nothing listening on the primary, failing over to the standby
+	fs, err = NewFSockWithOptions(primary, "ClueCon", WithStandbyAddrs(standby))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	_, standbyPort, _ := net.SplitHostPort(standby)
+	if _, port, _ := net.SplitHostPort(fs.RemoteAddr().String()); port != standbyPort {
+		t.Errorf("\nExpected port: <%+v>, \nReceived: <%+v>", standbyPort, fs.RemoteAddr())
+	}
+}
+
+func TestFSConnContentLength(t *testing.T) {
+	for _, tc := range []struct {
+		hdrs string
+		cl   int
+		has  bool
+	}{
+		{hdrs: "Content-Type: text/event-plain\nContent-Length: 12\n", cl: 12, has: true},
+		{hdrs: "Content-Length: 7", cl: 7, has: true},
+		{hdrs: "uuid_transfer Content-Length: 720\nContent-Type: text/event-plain\n", cl: 720, has: true},
+		{hdrs: "Content-Type: api/response\nX-Content-Length: 5\n"},
+		{hdrs: "Content-Type: command/reply\nReply-Text: -ERR no Content-Length: 5\n"},
+		{hdrs: "Content-Length-Extra: 5\n"},
+		{hdrs: "Content-Type: command/reply\n"},
+	} {
+		if cl, has, err := contentLength([]byte(tc.hdrs)); err != nil || cl != tc.cl || has != tc.has {
+			t.Errorf("%q: \nExpected: <%+v %+v>, \nReceived: <%+v %+v %v>", tc.hdrs, tc.cl, tc.has, cl, has, err)
+		}
+	}
+}
+
 func TestFSockreadEvent(t *testing.T) {
 	fs := &FSConn{
 		rdr: bufio.NewReader(bytes.NewBuffer([]byte("Content-Length\n\n"))),
@@ -736,7 +836,7 @@ func TestFSockNewFSockPool(t *testing.T) {
 		fSocks:        nil,
 		stopError:     chanErr,
 	}
-	fsnew := NewFSockPool(maxFSocks, fsaddr, fspw, reconns, maxWait, 0, 5*time.Second, fibDuration, evHandlers, evFilters, nil, connIdx, true, chanErr)
+	fsnew := NewFSockPool(maxFSocks, fsaddr, fspw, reconns, maxWait, 0, 5*time.Second, FibDuration, evHandlers, evFilters, nil, connIdx, true, chanErr)
 	fsnew.allowedConns = nil
 	fsnew.fSocks = nil
 	fsnew.delayFuncConstructor = nil
@@ -773,6 +873,7 @@ func TestFSockPushFSock(t *testing.T) {
 		fsConn: fsConn,
 		mu:     &sync.RWMutex{},
 	}
+	fsk.state.Store(int32(StateConnected))
 	fs.PushFSock(fsk)
 	if len(fs.fSocks) != 1 {
 		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 1, len(fs.fSocks))
@@ -845,18 +946,18 @@ func TestFSockPopFSock5(t *testing.T) {
 		passwd:               "testPw",
 		reconnects:           2,
 		maxReconnectInterval: 0,
-		delayFuncConstructor: fibDuration,
+		delayFuncConstructor: FibDuration,
 		eventHandlers:        make(map[string][]func(string, int)),
 		eventFilters:         make(map[string][]string),
 		logger:               nopLogger{},
 		connIdx:              0,
 		fSocks:               make(chan *FSock, 1),
-		allowedConns:         make(chan struct{}),
+		allowedConns:         make(chan struct{}, 1),
 		maxWaitConn:          20 * time.Millisecond,
 	}
 
 	expected := "dial tcp: address testAddr: missing port in address"
-	close(fs.allowedConns)
+	fs.allowedConns <- struct{}{}
 	fsock, err := fs.PopFSock()
 
 	if err.Error() != expected {
@@ -864,6 +965,395 @@ func TestFSockPopFSock5(t *testing.T) {
 	} else if fsock != nil {
 		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", nil, fsock)
 	}
+	if len(fs.allowedConns) != 1 { // the slot of the failed connection is given back
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 1, len(fs.allowedConns))
+	}
+}
+
+func TestFSockPopFSockCtx(t *testing.T) {
+	fs := &FSockPool{
+		fSocks:       make(chan *FSock, 1),
+		allowedConns: make(chan struct{}, 1),
+		maxWaitConn:  time.Minute,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if fsk, err := fs.PopFSockCtx(ctx); err != context.DeadlineExceeded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+	} else if fsk != nil {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", nil, fsk)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait not interrupted by the context, took: %v", elapsed)
+	}
+}
+
+func TestFSockPopFSockCtxCancelled(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	fs := NewFSockPool(2, addr, "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 5; i++ { // more than maxFSocks, each giving its slot back
+		if fsk, err := fs.PopFSockCtx(ctx); err == nil {
+			t.Fatalf("expected the pop to fail, received: %+v", fsk)
+		}
+	}
+	fsk, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsk.Disconnect()
+	if !fsk.Connected() {
+		t.Error("expected a connected FSock")
+	}
+}
+
+func TestFSockPopFSockValidation(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	fs := NewFSockPool(1, addr, "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil, WithPoolValidation(true))
+	dead := &FSock{mu: &sync.RWMutex{}, logger: nopLogger{}}
+	fs.fSocks <- dead
+	fsk, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsk.Disconnect()
+	if fsk == dead || !fsk.Connected() {
+		t.Errorf("expected the dead FSock to be replaced by a connected one, received: %+v", fsk)
+	}
+}
+
+func TestFSockPopFSockFailedReplacement(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := ln.Addr().String()
+	ln.Close()
+	for _, tc := range []struct {
+		name string
+		opt  PoolOption
+	}{
+		{name: "dead", opt: WithPoolValidation(true)},
+		{name: "expired", opt: WithMaxConnLifetime(time.Minute)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := NewFSockPool(1, unreachable, "ClueCon", 0, 50*time.Millisecond, 0, time.Second, FibDuration,
+				nil, nil, nil, 0, false, nil, tc.opt)
+			<-fs.allowedConns // taken by the pooled FSock
+			pooled := &FSock{mu: &sync.RWMutex{}, logger: nopLogger{}}
+			fs.connTimes = map[*FSock]pooledTimes{pooled: {created: time.Now().Add(-time.Hour)}}
+			fs.fSocks <- pooled
+			if _, err := fs.PopFSock(); err == nil {
+				t.Fatal("expected the replacement to fail")
+			}
+			fs.addr = mockFreeSWITCH(t, func(c net.Conn) { // the slot of the failed replacement is free
+				io.Copy(io.Discard, c)
+			})
+			fsk, err := fs.PopFSock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fsk.Disconnect()
+			if !fsk.Connected() {
+				t.Error("expected a connected FSock")
+			}
+		})
+	}
+}
+
+func TestFSockPoolClose(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	fs := NewFSockPool(1, addr, "ClueCon", 0, time.Minute, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil)
+	borrowed, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	popErr := make(chan error, 1)
+	go func() { // waits for the borrowed FSock
+		_, err := fs.PopFSock()
+		popErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := fs.Close(); err != nil {
+		t.Error(err)
+	}
+	select {
+	case err := <-popErr:
+		if err != ErrConnectionPoolClosed {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrConnectionPoolClosed, err)
+		}
+	case <-time.After(time.Second):
+		t.Error("waiting PopFSock not woken up by Close")
+	}
+	if _, err := fs.PopFSock(); err != ErrConnectionPoolClosed {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrConnectionPoolClosed, err)
+	}
+	fs.PushFSock(borrowed)
+	if borrowed.Connected() || len(fs.fSocks) != 0 {
+		t.Error("expected the FSock pushed after Close to be disconnected")
+	}
+}
+
+func TestFSockPoolCloseIdle(t *testing.T) {
+	fs := &FSockPool{
+		allowedConns: make(chan struct{}, 2),
+		fSocks:       make(chan *FSock, 2),
+	}
+	fs.allowedConns <- struct{}{}
+	idle := &FSock{mu: &sync.RWMutex{}, logger: nopLogger{}, fsConn: &FSConn{conn: &connMock{}}}
+	idle.state.Store(int32(StateConnected))
+	fs.PushFSock(idle)
+	if err := fs.Close(); err != nil {
+		t.Error(err)
+	}
+	if idle.Connected() {
+		t.Error("expected the idle FSock to be disconnected")
+	}
+	if len(fs.fSocks) != 0 || len(fs.allowedConns) != 0 {
+		t.Errorf("expected an empty pool, idle: %d, allowed: %d", len(fs.fSocks), len(fs.allowedConns))
+	}
+}
+
+func TestFSockPoolStats(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	fs := NewFSockPool(1, addr, "ClueCon", 0, 20*time.Millisecond, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil)
+	defer fs.Close()
+	fsk, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.PopFSock(); err != ErrConnectionPoolTimeout {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrConnectionPoolTimeout, err)
+	}
+	rcv := fs.Stats()
+	if rcv.Created != 1 || rcv.Idle != 0 || rcv.Borrowed != 1 || rcv.WaitCount != 1 {
+		t.Errorf("unexpected stats with a borrowed FSock: %+v", rcv)
+	}
+	if rcv.WaitDuration < 20*time.Millisecond {
+		t.Errorf("expected the wait duration to cover the pool timeout, received: %v", rcv.WaitDuration)
+	}
+	fs.PushFSock(fsk)
+	exp := PoolStats{
+		Created:      1,
+		Idle:         1,
+		WaitCount:    1,
+		WaitDuration: rcv.WaitDuration,
+	}
+	if rcv = fs.Stats(); rcv != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+}
+
+func TestFSockPoolWarmUp(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	kln := keepOpenListener{ln}
+	for range 2 {
+		mockFreeSWITCHOn(t, kln, func(c net.Conn) {
+			io.Copy(io.Discard, c)
+		})
+	}
+	fs := NewFSockPool(2, ln.Addr().String(), "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil, WithMinIdle(3))
+	defer fs.Close()
+	if err := fs.WarmUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rcv := fs.Stats(); rcv.Created != 2 || rcv.Idle != 2 || rcv.Borrowed != 0 {
+		t.Errorf("expected the pool warmed up to maxFSocks, received: %+v", rcv)
+	}
+	if len(fs.allowedConns) != 0 {
+		t.Errorf("expected no more connections allowed, received: %d", len(fs.allowedConns))
+	}
+}
+
+func TestFSockPoolMaxIdleTime(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	kln := keepOpenListener{ln}
+	for range 2 {
+		mockFreeSWITCHOn(t, kln, func(c net.Conn) {
+			io.Copy(io.Discard, c)
+		})
+	}
+	fs := NewFSockPool(1, ln.Addr().String(), "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil, WithMaxIdleTime(20*time.Millisecond))
+	defer fs.Close()
+	first, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.PushFSock(first)
+	time.Sleep(30 * time.Millisecond)
+	second, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.PushFSock(second)
+	if second == first || first.Connected() {
+		t.Error("expected the long idle FSock to be recycled")
+	}
+	if rcv := fs.Stats().Created; rcv != 2 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 2, rcv)
+	}
+}
+
+func TestFSockPoolPushReconnecting(t *testing.T) {
+	fs := NewFSockPool(1, "127.0.0.1:1", "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil)
+	defer fs.Close()
+	<-fs.allowedConns // taken by the pushed FSock
+	fsk := newFSock("127.0.0.1:1", "ClueCon")
+	fsk.setState(StateReconnecting)
+	fs.PushFSock(fsk)
+	if state := fsk.State(); state != StateClosed {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", StateClosed, state)
+	}
+	if len(fs.fSocks) != 0 || len(fs.allowedConns) != 1 {
+		t.Errorf("expected the FSock slot to be freed, idle: %d, allowed: %d",
+			len(fs.fSocks), len(fs.allowedConns))
+	}
+}
+
+func TestFSockPoolMaxConnLifetime(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	fs := NewFSockPool(1, addr, "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil, WithMaxConnLifetime(20*time.Millisecond))
+	defer fs.Close()
+	fsk, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	fs.PushFSock(fsk)
+	if fsk.Connected() {
+		t.Error("expected the FSock past its lifetime to be disconnected")
+	}
+	if len(fs.fSocks) != 0 || len(fs.allowedConns) != 1 || len(fs.connTimes) != 0 {
+		t.Errorf("expected the FSock slot to be freed, idle: %d, allowed: %d, tracked: %d",
+			len(fs.fSocks), len(fs.allowedConns), len(fs.connTimes))
+	}
+}
+
+func TestFSockNewFSockPoolFromConfig(t *testing.T) {
+	fs := NewFSockPoolFromConfig(PoolConfig{
+		Addr:        "127.0.0.1:8021",
+		Passwd:      "ClueCon",
+		MinIdle:     1,
+		MaxIdleTime: time.Minute,
+	})
+	if cap(fs.fSocks) != DefaultPoolMaxFSocks || len(fs.allowedConns) != DefaultPoolMaxFSocks {
+		t.Errorf("expected %d FSocks allowed, received: %d", DefaultPoolMaxFSocks, len(fs.allowedConns))
+	}
+	if fs.maxWaitConn != DefaultPoolMaxWaitConn {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", DefaultPoolMaxWaitConn, fs.maxWaitConn)
+	}
+	if fs.delayFuncConstructor == nil || fs.logger != (nopLogger{}) {
+		t.Error("expected the delay function and logger defaults")
+	}
+	if fs.minIdle != 1 || fs.maxIdleTime != time.Minute {
+		t.Errorf("unexpected pool settings, minIdle: %d, maxIdleTime: %v", fs.minIdle, fs.maxIdleTime)
+	}
+}
+
+func TestFSockPoolFSockOptions(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	fs := NewFSockPoolFromConfig(PoolConfig{
+		Addr:         addr,
+		Passwd:       "ClueCon",
+		FSockOptions: []Option{WithWriteTimeout(time.Second)},
+	})
+	defer fs.Close()
+	fsk, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.PushFSock(fsk)
+	if fsk.writeTimeout != time.Second {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", time.Second, fsk.writeTimeout)
+	}
+}
+
+func TestFSockPoolAddrs(t *testing.T) {
+	discard := func(c net.Conn) { io.Copy(io.Discard, c) }
+	addr1 := mockFreeSWITCH(t, discard)
+	addr2 := mockFreeSWITCH(t, discard)
+	fs := NewFSockPool(2, "", "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil, WithPoolAddrs(addr1, addr2))
+	defer fs.Close()
+	var addrs []string
+	for range 2 {
+		fsk, err := fs.PopFSock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fs.PushFSock(fsk)
+		addrs = append(addrs, fsk.addr)
+	}
+	if exp := []string{addr1, addr2}; !slices.Equal(addrs, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, addrs)
+	}
+}
+
+func TestFSockPoolAddrQuarantine(t *testing.T) {
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.Addr().String()
+	dead.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	kln := keepOpenListener{ln}
+	for range 2 {
+		mockFreeSWITCHOn(t, kln, func(c net.Conn) {
+			io.Copy(io.Discard, c)
+		})
+	}
+	fs := NewFSockPool(2, "", "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil,
+		WithPoolAddrs(deadAddr, ln.Addr().String()), WithAddrQuarantine(time.Minute))
+	defer fs.Close()
+	for range 2 {
+		fsk, err := fs.PopFSock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fs.PushFSock(fsk)
+		if fsk.addr != ln.Addr().String() {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ln.Addr().String(), fsk.addr)
+		}
+	}
+	if !fs.isQuarantined(deadAddr, time.Now()) {
+		t.Errorf("expected <%s> to be quarantined", deadAddr)
+	}
 }
 
 func TestFSockReadBodyTT(t *testing.T) {
@@ -1002,6 +1492,12 @@ func mockFreeSWITCH(t *testing.T, fn func(net.Conn)) string {
 	if err != nil {
 		t.Fatal(err)
 	}
+	return mockFreeSWITCHOn(t, ln, fn)
+}
+
+// mockFreeSWITCHOn is the same as mockFreeSWITCH, serving on the provided listener.
+func mockFreeSWITCHOn(t *testing.T, ln net.Listener, fn func(net.Conn)) string {
+	t.Helper()
 	go func() {
 		defer ln.Close()
 		conn, err := ln.Accept()
@@ -1037,6 +1533,8 @@ func mockFreeSWITCH(t *testing.T, fn func(net.Conn)) string {
 			switch {
 			case strings.Contains(request, "auth"):
 				_, err = conn.Write([]byte("Reply-Text: +OK accepted\n\n"))
+			case strings.Contains(request, "linger"):
+				_, err = conn.Write([]byte("Reply-Text: +OK will linger\n\n"))
 			case strings.Contains(request, "event plain"):
 				_, err = conn.Write([]byte("Reply-Text: +OK\n\n"))
 
@@ -1074,7 +1572,7 @@ func TestFSockHandleConnReset(t *testing.T) {
 		reconnects: 0, // no need to attempt reconnect
 		logger:     nopLogger{},
 		stopError:  make(chan error),
-		delayFunc:  fibDuration,
+		delayFunc:  FibDuration,
 	}
 	if err := fs.connect(); err != nil {
 		t.Fatal("failed to connect to FreeSWITCH:", err)
@@ -1106,7 +1604,7 @@ func TestFSockDisconnectIntentional(t *testing.T) {
 		reconnects: 5,
 		logger:     nopLogger{},
 		stopError:  make(chan error),
-		delayFunc:  fibDuration,
+		delayFunc:  FibDuration,
 	}
 
 	if err := fs.connect(); err != nil {
@@ -1123,6 +1621,64 @@ func TestFSockDisconnectIntentional(t *testing.T) {
 	}
 }
 
+func TestFSockOnError(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	errs := make(chan error, 1)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithReconnects(1), WithBackoffPolicy(new(backoffMock)),
+		WithOnError(func(err error) { errs <- err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	srv.Close()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected the reconnect failure")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the final disconnect")
+	}
+}
+
+func TestFSockStopErrors(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithReconnects(1), WithBackoffPolicy(new(backoffMock)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopErrs1, stopErrs2 := fs.StopErrors(ctx), fs.StopErrors(ctx)
+	cancelledCtx, cancelListening := context.WithCancel(context.Background())
+	stopErrs3 := fs.StopErrors(cancelledCtx)
+	cancelListening()
+
+	srv.Close()
+	for _, stopErrs := range []<-chan error{stopErrs1, stopErrs2} {
+		select {
+		case err := <-stopErrs:
+			if err == nil {
+				t.Error("expected the reconnect failure")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the final disconnect")
+		}
+	}
+	select {
+	case err, open := <-stopErrs3:
+		if open {
+			t.Errorf("expected the channel closed, received: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the channel to be closed")
+	}
+}
+
 // type mockLogger struct {
 // 	nopLogger
 // }
@@ -1152,7 +1708,7 @@ func TestFSockDisconnectUnexpectedErr(t *testing.T) {
 		reconnects: 1,
 		logger:     nopLogger{},
 		stopError:  make(chan error),
-		delayFunc:  fibDuration,
+		delayFunc:  FibDuration,
 	}
 
 	if err := fs.connect(); err != nil {
@@ -1165,3 +1721,2124 @@ func TestFSockDisconnectUnexpectedErr(t *testing.T) {
 		t.Errorf("<-fs.stopError=%q, want %q", err, wantErr)
 	}
 }
+
+func TestFSockDispatchEventCtxHandler(t *testing.T) {
+	l := &loggerMock{}
+	ctx, cancel := context.WithCancel(context.Background())
+	type handled struct {
+		ctx   context.Context
+		event string
+	}
+	calls := make(chan handled, 1)
+	fs := &FSConn{
+		connIdx: 3,
+		lgr:     l,
+		ctx:     ctx,
+		cancel:  cancel,
+		err:     make(chan error, 1),
+		rdr:     bufio.NewReader(bytes.NewBufferString("")),
+		conn:    new(connMock3),
+		ctxEventHandlers: map[string][]EventHandlerCtx{
+			"HEARTBEAT": {func(ctx context.Context, lgr FieldLogger, event string, connIdx int) {
+				lgr.With("event_name", "HEARTBEAT").Err("handled")
+				calls <- handled{ctx: ctx, event: event}
+			}},
+		},
+	}
+	event := "Event-Name: HEARTBEAT\n"
+	fs.dispatchEvent(event)
+
+	var h handled
+	select {
+	case h = <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("handler was not called")
+	}
+	if h.event != event {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", event, h.event)
+	}
+	if expected := "[conn_idx=3 event_name=HEARTBEAT] handled"; l.msg != expected {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", expected, l.msg)
+	}
+	if h.ctx.Err() != nil {
+		t.Fatalf("context cancelled before the connection was lost: %v", h.ctx.Err())
+	}
+
+	// Losing the connection cancels the handlers context.
+	fs.readEvents()
+	if h.ctx.Err() != context.Canceled {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.Canceled, h.ctx.Err())
+	}
+}
+
+// selfSignedTLSConfig generates a certificate for 127.0.0.1, returning the server
+// config together with a client config trusting it.
+func selfSignedTLSConfig(t *testing.T) (srvCfg, clntCfg *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fsock"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	srvCfg = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	clntCfg = &tls.Config{RootCAs: pool}
+	return
+}
+
+func TestFSockConnectTLS(t *testing.T) {
+	srvCfg, clntCfg := selfSignedTLSConfig(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srvCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopFS := make(chan struct{})
+	t.Cleanup(func() { close(stopFS) })
+	addr := mockFreeSWITCHOn(t, ln, func(net.Conn) {
+		<-stopFS
+	})
+
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithTLSConfig(clntCfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, isTLS := fs.fsConn.conn.(*tls.Conn); !isTLS {
+		t.Errorf("expected a TLS connection, got %T", fs.fsConn.conn)
+	}
+	if err := fs.Disconnect(); err != nil {
+		t.Error(err)
+	}
+
+	// A TLS client cannot complete the handshake with a plain TCP endpoint.
+	plainLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plainLn.Close()
+	go func() {
+		conn, err := plainLn.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("Content-Type: auth/request\n\n"))
+	}()
+	plainAddr := plainLn.Addr().String()
+	if _, err := NewFSockWithOptions(plainAddr, "ClueCon", WithTLSConfig(clntCfg)); err == nil {
+		t.Error("expected TLS handshake error")
+	}
+}
+
+func TestFSockConnectUnixSocket(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "esl.sock")
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopFS := make(chan struct{})
+	t.Cleanup(func() { close(stopFS) })
+	mockFreeSWITCHOn(t, ln, func(net.Conn) {
+		<-stopFS
+	})
+
+	fs, err := NewFSock("unix:"+sockPath, "ClueCon", 0, 0, time.Second, FibDuration,
+		map[string][]func(string, int){}, map[string][]string{},
+		nil, 0, false, make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network := fs.LocalAddr().Network(); network != "unix" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "unix", network)
+	}
+	if err := fs.Disconnect(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFSockConnectCtxCancelHandshake(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn) // never send the auth challenge
+	}()
+	fs := &FSock{
+		mu:        &sync.RWMutex{},
+		addr:      ln.Addr().String(),
+		logger:    nopLogger{},
+		delayFunc: FibDuration,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := fs.ConnectCtx(ctx); err != context.DeadlineExceeded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+	}
+	if fs.Connected() {
+		t.Error("expected not to be connected")
+	}
+}
+
+func TestFSockSendCmdCtxCancel(t *testing.T) {
+	stopFS := make(chan struct{})
+	t.Cleanup(func() { close(stopFS) })
+	addr := mockFreeSWITCH(t, func(net.Conn) {
+		<-stopFS // never reply to commands
+	})
+	fs, err := NewFSock(addr, "ClueCon", 0, 0, 0, FibDuration,
+		map[string][]func(string, int){}, map[string][]string{},
+		nil, 0, false, make(chan error, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := fs.SendApiCmdCtx(ctx, "status"); err != context.Canceled {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.Canceled, err)
+	}
+}
+
+// replyCommands reads the commands sent by fsock over c, replying +OK to each of them.
+// The commands received are forwarded on cmds.
+func replyCommands(t *testing.T, c net.Conn, cmds chan<- string) {
+	rdr := bufio.NewReader(c)
+	for {
+		line, err := rdr.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		cmds <- line
+		if _, err := c.Write([]byte("Content-Type: command/reply\nReply-Text: +OK\n\n")); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+}
+
+func TestFSockAddRemoveEventHandler(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		replyCommands(t, c, cmds)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	// Built out of the same function literal, told apart by their IDs only.
+	var answered []string
+	h1 := EventHandler(func(Event, int) { answered = append(answered, "h1") })
+	h2 := EventHandler(func(Event, int) { answered = append(answered, "h2") })
+	id1, err := fs.AddEventHandler("CHANNEL_ANSWER", h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-cmds; cmd != "event plain CHANNEL_ANSWER" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "event plain CHANNEL_ANSWER", cmd)
+	}
+	// Already subscribed, no command expected.
+	id2, err := fs.AddEventHandler("CHANNEL_ANSWER", h2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.AddEventHandler("CUSTOM sofia::register", h1); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-cmds; cmd != "event plain CUSTOM sofia::register" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "event plain CUSTOM sofia::register", cmd)
+	}
+
+	// Handlers left for the event, no command expected.
+	if err := fs.RemoveEventHandler(id1); err != nil {
+		t.Fatal(err)
+	}
+	for _, handler := range fs.fsConn.eventHandlers["CHANNEL_ANSWER"] {
+		handler("Event-Name: CHANNEL_ANSWER\n\n", 0)
+	}
+	if !reflect.DeepEqual(answered, []string{"h2"}) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", []string{"h2"}, answered)
+	}
+	if err := fs.RemoveEventHandler(id1); err != nil { // removed already
+		t.Fatal(err)
+	}
+	if err := fs.RemoveEventHandler(id2); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-cmds; cmd != "nixevent CHANNEL_ANSWER" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "nixevent CHANNEL_ANSWER", cmd)
+	}
+	if _, has := fs.eventHandlers["CHANNEL_ANSWER"]; has {
+		t.Errorf("expected no handlers left, received: %+v", fs.eventHandlers)
+	}
+	if len(fs.fsConn.eventHandlers["CUSTOM sofia::register"]) != 1 {
+		t.Errorf("unexpected handlers on the connection: %+v", fs.fsConn.eventHandlers)
+	}
+	select {
+	case cmd := <-cmds:
+		t.Errorf("unexpected command: %q", cmd)
+	default:
+	}
+}
+
+func TestFSockEventHandlersNotShared(t *testing.T) {
+	evHandlers := map[string][]func(string, int){"CHANNEL_ANSWER": {func(string, int) {}}}
+	fs1 := newFSock("127.0.0.1:1", "ClueCon", WithEventHandlers(evHandlers))
+	fs2 := newFSock("127.0.0.1:1", "ClueCon", WithEventHandlers(evHandlers))
+	if _, err := fs1.AddEventHandler("CHANNEL_ANSWER", func(string, int) {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs1.AddEventHandler("CHANNEL_HANGUP", func(string, int) {}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs2.eventHandlers) != 1 || len(fs2.eventHandlers["CHANNEL_ANSWER"]) != 1 ||
+		len(evHandlers) != 1 || len(evHandlers["CHANNEL_ANSWER"]) != 1 {
+		t.Errorf("handlers leaked, caller: %+v, other FSock: %+v", evHandlers, fs2.eventHandlers)
+	}
+}
+
+func TestFSockRemoveAllEventsHandler(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		replyCommands(t, c, cmds)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithBgapi(true),
+		WithEventHandlers(map[string][]func(string, int){"CHANNEL_ANSWER": {func(string, int) {}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	id, err := fs.AddEventHandler("ALL", func(string, int) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-cmds; cmd != "event plain all" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "event plain all", cmd)
+	}
+	if err := fs.RemoveEventHandler(id); err != nil {
+		t.Fatal(err)
+	}
+	// Dropped along all the events, the handled ones are subscribed again.
+	for _, exp := range []string{"nixevent all", "event plain CHANNEL_ANSWER BACKGROUND_JOB"} {
+		if cmd := <-cmds; cmd != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, cmd)
+		}
+	}
+}
+
+func TestFSockAddDeleteFilter(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		replyCommands(t, c, cmds)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithBgapi(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	if err := fs.AddFilter("Unique-ID", "uuid1"); err != nil {
+		t.Fatal(err)
+	}
+	// Already filtered, no command expected.
+	if err := fs.AddFilter("Unique-ID", "uuid1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.AddFilter("Unique-ID", "uuid2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.DeleteFilter("Unique-ID", "uuid1"); err != nil {
+		t.Fatal(err)
+	}
+	// Not filtered, no command expected.
+	if err := fs.DeleteFilter("Unique-ID", "uuid3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		"filter Event-Name BACKGROUND_JOB",
+		"filter Unique-ID uuid1",
+		"filter Unique-ID uuid2",
+		"filter delete Unique-ID uuid1",
+	} {
+		if cmd := <-cmds; cmd != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, cmd)
+		}
+	}
+	select {
+	case cmd := <-cmds:
+		t.Errorf("unexpected command: %q", cmd)
+	default:
+	}
+	expFilters := map[string][]string{"Unique-ID": {"uuid2"}}
+	if !reflect.DeepEqual(expFilters, fs.eventFilters) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", expFilters, fs.eventFilters)
+	}
+	if err := fs.DeleteFilter("Unique-ID", "uuid2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.eventFilters) != 0 {
+		t.Errorf("expected no filters left, received: %+v", fs.eventFilters)
+	}
+	// The last filter gone, the one of bgapi goes as well.
+	for _, exp := range []string{
+		"filter delete Unique-ID uuid2",
+		"filter delete Event-Name BACKGROUND_JOB",
+	} {
+		if cmd := <-cmds; cmd != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, cmd)
+		}
+	}
+}
+
+func TestFSockEventFiltersNotShared(t *testing.T) {
+	filters := map[string][]string{"Event-Name": {"CHANNEL_ANSWER"}}
+	fs1 := newFSock("127.0.0.1:1", "ClueCon", WithEventFilters(filters))
+	fs2 := newFSock("127.0.0.1:1", "ClueCon", WithEventFilters(filters))
+	if err := fs1.AddFilter("Event-Name", "CHANNEL_HANGUP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs1.AddFilter("Unique-ID", "uuid1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs1.DeleteFilter("Event-Name", "CHANNEL_ANSWER"); err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string][]string{"Event-Name": {"CHANNEL_ANSWER"}}
+	if !reflect.DeepEqual(exp, filters) || !reflect.DeepEqual(exp, fs2.eventFilters) {
+		t.Errorf("filters leaked, caller: %+v, other FSock: %+v", filters, fs2.eventFilters)
+	}
+}
+
+func TestFSockSubscribeMyEvents(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		rdr := bufio.NewReader(c)
+		for {
+			line, err := rdr.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			cmds <- line
+			if _, err := c.Write([]byte("Content-Type: command/reply\nReply-Text: +OK\n\n")); err != nil {
+				t.Error(err)
+				return
+			}
+			event := "Event-Name: CHANNEL_ANSWER\nUnique-ID: uuid1\n\n"
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	events := make(chan string, 1)
+	if err := fs.SubscribeMyEvents("uuid1", func(event string, _ int) {
+		events <- event
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-cmds; cmd != "myevents uuid1" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "myevents uuid1", cmd)
+	}
+	select {
+	case event := <-events:
+		if uuid := headerVal(event, "Unique-ID"); uuid != "uuid1" {
+			t.Errorf("\nExpected: %q, \nReceived: %q", "uuid1", uuid)
+		}
+	case <-time.After(time.Second):
+		t.Error("timed out waiting for the call leg event")
+	}
+}
+
+func TestFSockDispatchWorkers(t *testing.T) {
+	const noEvents = 20
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		for i := range noEvents {
+			event := fmt.Sprintf("Event-Name: CUSTOM\nEvent-Subclass: test\nEvent-Sequence: %d\n\n", i)
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		io.Copy(io.Discard, c)
+	})
+	var running, maxRunning atomic.Int32
+	seqs := make(chan string, noEvents)
+	handler := func(event string, _ int) {
+		if n := running.Add(1); n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		seqs <- headerVal(event, "Event-Sequence")
+	}
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithEventHandlers(map[string][]func(string, int){"CUSTOM test": {handler}}),
+		WithDispatchWorkers(1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	for i := range noEvents {
+		select {
+		case seq := <-seqs:
+			if exp := strconv.Itoa(i); seq != exp {
+				t.Errorf("\nExpected: %q, \nReceived: %q", exp, seq)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the events")
+		}
+	}
+	if rcv := maxRunning.Load(); rcv != 1 {
+		t.Errorf("expected the handler calls to run on a single worker, received: %d", rcv)
+	}
+	if rcv := fs.DispatchQueueDepth(); rcv != 0 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 0, rcv)
+	}
+}
+
+func TestFSockEvents(t *testing.T) {
+	sendEvents := make(chan struct{})
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		<-sendEvents
+		for _, event := range []string{
+			"Event-Name: HEARTBEAT\n\n",
+			"Event-Name: CHANNEL_ANSWER\nUnique-ID: uuid1\n\n",
+		} {
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		io.Copy(io.Discard, c)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithDispatchWorkers(1, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := fs.Events(ctx, "CHANNEL_ANSWER")
+	close(sendEvents)
+	select {
+	case ev := <-events:
+		if ev.Name() != "CHANNEL_ANSWER" || ev.UUID() != "uuid1" {
+			t.Errorf("unexpected event received: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+	cancel()
+	select {
+	case ev, open := <-events:
+		if open {
+			t.Errorf("expected the channel closed, received: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed after cancelling the context")
+	}
+}
+
+func TestFSockEventsOrdered(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword) // dispatching in goroutines
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := fs.Events(ctx, "DTMF")
+	const count = 200
+	for i := range count {
+		if err := srv.SendEvent(map[string]string{"Event-Name": "DTMF", "DTMF-Digit": strconv.Itoa(i)}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range count {
+		select {
+		case ev := <-events:
+			if digit := ev.GetHeader("DTMF-Digit"); digit != strconv.Itoa(i) {
+				t.Fatalf("\nExpected: <%+v>, \nReceived: <%+v>", i, digit)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for the event %d", i)
+		}
+	}
+}
+func TestFSockDefaultEventHandler(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		for _, event := range []string{
+			"Event-Name: CHANNEL_ANSWER\nUnique-ID: uuid1\n\n",
+			"Event-Name: CHANNEL_HANGUP\nUnique-ID: uuid1\n\n",
+		} {
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		io.Copy(io.Discard, c)
+	})
+	handled := make(chan string, 2)
+	unhandled := make(chan string, 2)
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {func(event string, _ int) { handled <- headerVal(event, "Event-Name") }},
+		}),
+		WithDefaultEventHandler(func(event string, _ int) { unhandled <- headerVal(event, "Event-Name") }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	for ch, exp := range map[chan string]string{handled: "CHANNEL_ANSWER", unhandled: "CHANNEL_HANGUP"} {
+		select {
+		case rcv := <-ch:
+			if rcv != exp {
+				t.Errorf("\nExpected: %q, \nReceived: %q", exp, rcv)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for %s", exp)
+		}
+	}
+	select {
+	case rcv := <-unhandled:
+		t.Errorf("unexpected event reaching the default handler: %s", rcv)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestFSockSyncDispatch(t *testing.T) {
+	const noEvents = 10
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		for i := range noEvents {
+			event := fmt.Sprintf("Event-Name: CHANNEL_ANSWER\nEvent-Sequence: %d\n\n", i)
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		io.Copy(io.Discard, c)
+	})
+	var seqs []string
+	done := make(chan struct{})
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {func(event string, _ int) {
+				time.Sleep(time.Millisecond) // would reorder the events if run concurrently
+				if seqs = append(seqs, headerVal(event, "Event-Sequence")); len(seqs) == noEvents {
+					close(done)
+				}
+			}},
+		}),
+		WithSyncDispatch(true), WithDispatchWorkers(4, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the events")
+	}
+	for i, seq := range seqs {
+		if exp := strconv.Itoa(i); seq != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, seq)
+		}
+	}
+	if fs.fsConn.workers != nil {
+		t.Error("expected no dispatch workers in sync mode")
+	}
+}
+
+func TestFSockEventQueue(t *testing.T) {
+	entered := make(chan struct{})
+	sent := make(chan struct{})
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		for i := range 4 {
+			if i == 1 {
+				<-entered // handler busy with the first event
+			}
+			event := fmt.Sprintf("Event-Name: CHANNEL_ANSWER\nEvent-Sequence: %d\n\n", i)
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		event := "Event-Name: HEARTBEAT\n\n" // read once the others are queued or dropped
+		if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(event), event); err != nil {
+			t.Error(err)
+			return
+		}
+		close(sent)
+		io.Copy(io.Discard, c)
+	})
+	release := make(chan struct{})
+	seqs := make(chan string, 4)
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {func(event string, _ int) {
+				if seq := headerVal(event, "Event-Sequence"); seq == "0" {
+					close(entered)
+					<-release
+				}
+				seqs <- headerVal(event, "Event-Sequence")
+			}},
+		}),
+		WithSyncDispatch(true), WithEventQueue(1, OverflowDropNewest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	<-sent
+	time.Sleep(20 * time.Millisecond) // let the reading loop queue them
+	if rcv := fs.DroppedEvents(); rcv != 3 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 3, rcv)
+	}
+	close(release)
+	for _, exp := range []string{"0", "1"} {
+		select {
+		case seq := <-seqs:
+			if seq != exp {
+				t.Errorf("\nExpected: %q, \nReceived: %q", exp, seq)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the events")
+		}
+	}
+}
+
+func TestFSockDeadLetterSink(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		event := "Event-Name: CHANNEL_ANSWER\nUnique-ID: uuid1\n\n"
+		if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(event), event); err != nil {
+			t.Error(err)
+			return
+		}
+		io.Copy(io.Discard, c)
+	})
+	type deadLetter struct {
+		event  string
+		reason error
+	}
+	deadLetters := make(chan deadLetter, 1)
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {func(string, int) { panic("handler failure") }},
+		}),
+		WithDeadLetterSink(func(event string, reason error, _ int) {
+			deadLetters <- deadLetter{event, reason}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	select {
+	case dl := <-deadLetters:
+		if uuid := headerVal(dl.event, "Unique-ID"); uuid != "uuid1" {
+			t.Errorf("\nExpected: %q, \nReceived: %q", "uuid1", uuid)
+		}
+		if exp := "event handler panicked: handler failure"; dl.reason == nil || dl.reason.Error() != exp {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, dl.reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the dead letter")
+	}
+}
+
+func TestFSockSequenceGapDetection(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		for _, seq := range []int{1, 2, 5} {
+			event := fmt.Sprintf("Event-Name: CHANNEL_ANSWER\nEvent-Sequence: %d\n\n", seq)
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		io.Copy(io.Discard, c)
+	})
+	gaps := make(chan [2]uint64, 1)
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {func(string, int) {}},
+		}),
+		WithSequenceGapDetection(func(last, seq uint64, _ int) {
+			gaps <- [2]uint64{last, seq}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	select {
+	case gap := <-gaps:
+		if exp := [2]uint64{2, 5}; gap != exp {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, gap)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the sequence gap")
+	}
+}
+
+func TestFSockEventLag(t *testing.T) {
+	fired := time.Now().Add(-time.Minute)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		event := fmt.Sprintf("Event-Name: CHANNEL_ANSWER\nEvent-Date-Timestamp: %d\n\n", fired.UnixMicro())
+		if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(event), event); err != nil {
+			t.Error(err)
+			return
+		}
+		io.Copy(io.Discard, c)
+	})
+	lags := make(chan time.Duration, 1)
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {func(string, int) {}},
+		}),
+		WithEventLag(func(lag time.Duration, eventName string, _ int) {
+			if eventName != "CHANNEL_ANSWER" {
+				t.Errorf("\nExpected: %q, \nReceived: %q", "CHANNEL_ANSWER", eventName)
+			}
+			lags <- lag
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	select {
+	case lag := <-lags:
+		if lag < time.Minute || lag > time.Minute+time.Second {
+			t.Errorf("expected a lag of about a minute, received: %v", lag)
+		}
+		if rcv := fs.EventLag(); rcv != lag {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", lag, rcv)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the event lag")
+	}
+}
+
+func TestFSockDisconnectLinger(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		if cmd, err := bufio.NewReader(c).ReadString('\n'); err != nil || cmd != "myevents uuid1\n" {
+			t.Errorf("unexpected command: %q, err: %v", cmd, err)
+		}
+		if _, err := c.Write([]byte("Content-Type: command/reply\nReply-Text: +OK Events Enabled\n\n")); err != nil {
+			t.Error(err)
+		}
+		notice := "Disconnected, goodbye.\n"
+		event := "Event-Name: CHANNEL_HANGUP_COMPLETE\nUnique-ID: uuid1\n\n"
+		if _, err := fmt.Fprintf(c,
+			"Content-Type: text/disconnect-notice\nContent-Disposition: linger\nContent-Length: %d\n\n%s"+
+				"Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(notice), notice, len(event), event); err != nil {
+			t.Error(err)
+		}
+		time.Sleep(50 * time.Millisecond) // FreeSWITCH closing after the lingered events
+	})
+	events := make(chan string, 1)
+	stopError := make(chan error, 1)
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithLinger(5),
+		WithStopError(stopError),
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_HANGUP_COMPLETE": {func(event string, _ int) { events <- event }},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SubscribeMyEvents("uuid1", func(string, int) {}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := fs.Disconnect(); err != nil {
+		t.Error(err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("expected Disconnect to return once the connection was closed, waited: %v", elapsed)
+	}
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Error("lingered event not dispatched")
+	}
+	select {
+	case err := <-stopError:
+		if err != nil {
+			t.Errorf("expected intentional shutdown, received: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the shutdown to be signaled")
+	}
+}
+
+func TestFSockDisconnectLingerInbound(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword, WithLinger(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now() // FreeSWITCH never closes the inbound connections, nothing to wait for
+	if err := fs.Disconnect(); err != nil {
+		t.Error(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Disconnect to return right away, waited: %v", elapsed)
+	}
+}
+
+func TestFSockSubscribeLog(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		rdr := bufio.NewReader(c)
+		for {
+			line, err := rdr.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			cmds <- line
+			if _, err := c.Write([]byte("Content-Type: command/reply\nReply-Text: +OK log level  [7]\n\n")); err != nil {
+				t.Error(err)
+				return
+			}
+			if line != "log debug" {
+				continue
+			}
+			logLine := "2024-01-01 10:00:00.000000 [DEBUG] switch_core.c:100 test\n"
+			if _, err := fmt.Fprintf(c, "Content-Type: log/data\nContent-Length: %d\nLog-Level: 7\nLog-File: switch_core.c\n\n%s",
+				len(logLine), logLine); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	logs := make(chan string, 1)
+	if err := fs.SubscribeLog("debug", func(logData string, _ int) {
+		logs <- logData
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case logData := <-logs:
+		logMap := EventToMap(logData)
+		if logMap["Log-Level"] != "7" {
+			t.Errorf("\nExpected: %q, \nReceived: %q", "7", logMap["Log-Level"])
+		}
+		if exp := "2024-01-01 10:00:00.000000 [DEBUG] switch_core.c:100 test\n"; logMap[EventBodyTag] != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, logMap[EventBodyTag])
+		}
+	case <-time.After(time.Second):
+		t.Error("timed out waiting for the log data")
+	}
+	if err := fs.UnsubscribeLog(); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{"log debug", "nolog"} {
+		if cmd := <-cmds; cmd != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, cmd)
+		}
+	}
+	if fs.logHandler != nil {
+		t.Error("expected the log handler to be removed")
+	}
+}
+
+func TestFSockUserAuthUnsafe(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	if _, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword, WithReconnects(1),
+		WithUserAuth("1000\nevent plain ALL", "example.com")); !errors.Is(err, ErrUnsafeArg) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrUnsafeArg, err)
+	}
+}
+
+func TestFSockPasswordProvider(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.SetPassword("first")
+	var passwd atomic.Value
+	passwd.Store("first")
+	errVault := errors.New("vault sealed")
+	var vaultErr atomic.Bool
+	provider := func() (string, error) {
+		if vaultErr.Load() {
+			return "", errVault
+		}
+		return passwd.Load().(string), nil
+	}
+	reconnected := make(chan struct{}, 1)
+	fs, err := NewFSockWithOptions(srv.Addr(), "", WithPasswordProvider(provider),
+		WithReconnects(50), WithBackoffPolicy(new(backoffMock)),
+		WithOnReconnect(func(int, net.Addr) { reconnected <- struct{}{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	srv.SetPassword("second")
+	passwd.Store("second")
+	srv.DropConnections()
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the reconnect")
+	}
+
+	vaultErr.Store(true)
+	if _, err := NewFSockWithOptions(srv.Addr(), "", WithPasswordProvider(provider),
+		WithReconnects(1)); !errors.Is(err, errVault) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", errVault, err)
+	}
+}
+
+func TestFSockRestoreSubscriptionsRejected(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	reconnected := make(chan struct{}, 1)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithReconnects(1000), WithBackoffPolicy(new(backoffMock)),
+		WithOnReconnect(func(int, net.Addr) { reconnected <- struct{}{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if err := fs.SubscribeLog("debug", func(string, int) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Stub("log", "-ERR no reply")
+	srv.DropConnections()
+	deadline := time.Now().Add(time.Second)
+	for countCommands(srv, "log debug") < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the log subscription to be retried")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-reconnected:
+		t.Fatal("reconnected without the log subscription")
+	default:
+	}
+
+	srv.Stub("log", "+OK log level debug [7]")
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the reconnect")
+	}
+	if _, err := fs.SendApiCmd("status"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFSockRestoreMyEventsChannelGone(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	reconnected := make(chan struct{}, 1)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithReconnects(50), WithBackoffPolicy(new(backoffMock)),
+		WithOnReconnect(func(int, net.Addr) { reconnected <- struct{}{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if err := fs.SubscribeMyEvents("uuid1", func(string, int) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Stub("myevents", "-ERR invalid uuid")
+	srv.DropConnections()
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the reconnect")
+	}
+	if n := countCommands(srv, "myevents uuid1"); n != 2 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 2, n)
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fs.myEventsUUID != "" || fs.myEventsHandler != nil {
+		t.Errorf("expected the myevents of %s to be dropped", fs.myEventsUUID)
+	}
+}
+
+func TestFSockRestoreFiltersBgapi(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	reconnected := make(chan struct{}, 1)
+	filters := map[string][]string{"Event-Name": {"CHANNEL_ANSWER"}}
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithEventFilters(filters), WithBgapi(true),
+		WithReconnects(50), WithBackoffPolicy(new(backoffMock)),
+		WithOnReconnect(func(int, net.Addr) { reconnected <- struct{}{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	srv.DropConnections()
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the reconnect")
+	}
+	if n := countCommands(srv, "filter Event-Name BACKGROUND_JOB"); n != 2 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 2, n)
+	}
+	if exp := []string{"CHANNEL_ANSWER"}; !reflect.DeepEqual(exp, filters["Event-Name"]) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, filters["Event-Name"])
+	}
+}
+
+func TestFSockRestoreRuntimeHandlersFilters(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	reconnected := make(chan struct{}, 1)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithReconnects(50), WithBackoffPolicy(new(backoffMock)),
+		WithOnReconnect(func(int, net.Addr) { reconnected <- struct{}{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if _, err := fs.AddEventHandler("CHANNEL_ANSWER", func(string, int) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.AddFilter("Unique-ID", "uuid1"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.DropConnections()
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the reconnect")
+	}
+	if n := countCommands(srv, "filter Unique-ID uuid1"); n != 2 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 2, n)
+	}
+	var lastEvents string
+	for _, cmd := range srv.Commands() {
+		if strings.HasPrefix(cmd, "event ") {
+			lastEvents = cmd
+		}
+	}
+	if !strings.Contains(lastEvents, "CHANNEL_ANSWER") {
+		t.Errorf("expected CHANNEL_ANSWER resubscribed, received: <%s>", lastEvents)
+	}
+}
+
+func TestFSockSetLinger(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	reconnected := make(chan struct{}, 1)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithReconnects(50), WithBackoffPolicy(new(backoffMock)),
+		WithOnReconnect(func(int, net.Addr) { reconnected <- struct{}{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if err := fs.SetLinger(5); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.DropConnections()
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the reconnect")
+	}
+	if n := countCommands(srv, "linger 5"); n != 2 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 2, n)
+	}
+	if err := fs.SetLinger(0); err != nil {
+		t.Fatal(err)
+	}
+	if n := countCommands(srv, "nolinger"); n != 1 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 1, n)
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fs.linger != 0 || fs.fsConn.linger != 0 {
+		t.Errorf("expected linger disabled, received: %d", fs.linger)
+	}
+}
+
+func TestFSockPauseResumeEvents(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	reconnected := make(chan struct{}, 1)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithEventHandlers(map[string][]func(string, int){"CHANNEL_ANSWER": {func(string, int) {}}}),
+		WithBgapi(true), WithReconnects(50), WithBackoffPolicy(new(backoffMock)),
+		WithOnReconnect(func(int, net.Addr) { reconnected <- struct{}{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	if err := fs.PauseEvents(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.AddEventHandler("CHANNEL_HANGUP", func(string, int) {}); err != nil {
+		t.Fatal(err)
+	}
+	srv.DropConnections()
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the reconnect")
+	}
+	if err := fs.ResumeEvents(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rcv []string
+	for _, cmd := range srv.Commands() {
+		if cmd == "noevents" || strings.HasPrefix(cmd, "event ") {
+			rcv = append(rcv, cmd)
+		}
+	}
+	exp := []string{
+		"event plain CHANNEL_ANSWER BACKGROUND_JOB",
+		"noevents",
+		"event plain BACKGROUND_JOB",
+		"event plain CHANNEL_ANSWER CHANNEL_HANGUP BACKGROUND_JOB", // reconnected
+		"noevents",
+		"event plain BACKGROUND_JOB",
+		"event plain CHANNEL_ANSWER CHANNEL_HANGUP BACKGROUND_JOB", // resumed
+	}
+	if len(rcv) == len(exp) { // the event names come unordered
+		for i := range rcv {
+			evs := strings.Fields(rcv[i])
+			slices.Sort(evs)
+			rcv[i] = strings.Join(evs, " ")
+			evs = strings.Fields(exp[i])
+			slices.Sort(evs)
+			exp[i] = strings.Join(evs, " ")
+		}
+	}
+	if !reflect.DeepEqual(exp, rcv) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+}
+
+// countCommands counts the commands received by srv starting with prefix.
+func TestFSockFiltersHeartbeat(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithEventFilters(map[string][]string{"Unique-ID": {"uuid1"}}),
+		WithHeartbeatWatchdog(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if n := countCommands(srv, "filter Event-Name HEARTBEAT"); n != 1 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 1, n)
+	}
+
+	// Filtered at runtime, the watchdog keeps receiving its HEARTBEATs.
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		replyCommands(t, c, cmds)
+	})
+	if fs, err = NewFSockWithOptions(addr, "ClueCon", WithHeartbeatWatchdog(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if err := fs.AddFilter("Unique-ID", "uuid1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.DeleteFilter("Unique-ID", "uuid1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		"filter Event-Name HEARTBEAT",
+		"filter Unique-ID uuid1",
+		"filter delete Unique-ID uuid1",
+		"filter delete Event-Name HEARTBEAT",
+	} {
+		if cmd := <-cmds; cmd != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, cmd)
+		}
+	}
+}
+
+func countCommands(srv *fsocktest.Server, prefix string) (n int) {
+	for _, cmd := range srv.Commands() {
+		if strings.HasPrefix(cmd, prefix) {
+			n++
+		}
+	}
+	return
+}
+
+func TestFSockSendBgapiCmdCtxCancel(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		replyCommands(t, c, cmds)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithBgapi(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := fs.SendBgapiCmdCtx(ctx, "originate user/1001 &park")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-cmds; cmd != "bgapi originate user/1001 &park" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "bgapi originate user/1001 &park", cmd)
+	}
+	cancel()
+	select {
+	case rply, open := <-out:
+		if open {
+			t.Errorf("expected the channel to be closed, received: %q", rply)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancelling the job")
+	}
+	fs.fsConn.bgapiMux.RLock()
+	defer fs.fsConn.bgapiMux.RUnlock()
+	if len(fs.fsConn.bgapiChan) != 0 {
+		t.Errorf("expected no jobs left, received: %+v", fs.fsConn.bgapiChan)
+	}
+}
+
+func TestFSockDoBackgroundJobAbandoned(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := &bgapiJob{out: make(chan string), ctx: ctx}
+	fsConn := &FSConn{
+		bgapiChan: map[string]*bgapiJob{"testID": job},
+		bgapiMux:  &sync.RWMutex{},
+		lgr:       nopLogger{},
+	}
+	fsConn.doBackgroundJob("Event-Name: BACKGROUND_JOB\nJob-UUID: testID\n\n+OK\n")
+	if _, open := <-job.out; open {
+		t.Error("expected the channel of the abandoned job to be closed")
+	}
+	if len(fsConn.bgapiChan) != 0 {
+		t.Errorf("expected no jobs left, received: %+v", fsConn.bgapiChan)
+	}
+}
+
+func TestFSockSendBgapiCmdJobTTL(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		replyCommands(t, c, cmds)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithBgapi(true), WithBgapiJobTTL(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	out, err := fs.SendBgapiCmd("originate user/1001 &park")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case rply := <-out:
+		if exp := "-ERR " + ErrBgapiJobExpired.Error(); rply != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, rply)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job not expired")
+	}
+	fs.fsConn.bgapiMux.RLock()
+	defer fs.fsConn.bgapiMux.RUnlock()
+	if len(fs.fsConn.bgapiChan) != 0 {
+		t.Errorf("expected no jobs left, received: %+v", fs.fsConn.bgapiChan)
+	}
+}
+
+func TestFSockPing(t *testing.T) {
+	fs := &FSock{mu: &sync.RWMutex{}, connIdx: 3}
+	var pingErr *PingError
+	if err := fs.Ping(); !errors.As(err, &pingErr) || !errors.Is(err, ErrNotConnected) || pingErr.ConnIdx != 3 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrNotConnected, err)
+	}
+
+	cmds := make(chan string, 1)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		rdr := bufio.NewReader(c)
+		line, err := rdr.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		cmds <- strings.TrimSpace(line)
+		status := "UP 0 years, 0 days, 0 hours, 1 minute\n"
+		if _, err := fmt.Fprintf(c, "Content-Type: api/response\nContent-Length: %d\n\n%s", len(status), status); err != nil {
+			t.Error(err)
+		}
+		// Leave the next pings unanswered.
+		io.Copy(io.Discard, rdr)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithReplyTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if err := fs.Ping(); err != nil {
+		t.Error(err)
+	}
+	if cmd := <-cmds; cmd != "api status" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "api status", cmd)
+	}
+	if err := fs.Ping(); !errors.As(err, &pingErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+	}
+}
+
+func TestFSockHeartbeatWatchdog(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c) // half-open, nothing sent back
+	})
+	stopError := make(chan error, 1)
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithHeartbeatWatchdog(100*time.Millisecond),
+		WithStopError(stopError))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	select {
+	case err := <-stopError:
+		if err == nil {
+			t.Error("expected the stale connection to be reported")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stale connection not detected")
+	}
+	if fs.Connected() {
+		t.Error("expected the stale connection to be dropped")
+	}
+}
+
+func TestFSockEventNamesHeartbeatWatchdog(t *testing.T) {
+	fsConn := &FSConn{
+		eventHandlers: map[string][]func(string, int){"CHANNEL_ANSWER": {func(string, int) {}}},
+		connOptions:   connOptions{heartbeatTimeout: time.Minute},
+	}
+	evNames := fsConn.eventNames()
+	slices.Sort(evNames)
+	if exp := []string{"CHANNEL_ANSWER", "HEARTBEAT"}; !reflect.DeepEqual(exp, evNames) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, evNames)
+	}
+}
+
+// keepOpenListener ignores Close, so mockFreeSWITCHOn can serve it more than once.
+type keepOpenListener struct {
+	net.Listener
+}
+
+func (keepOpenListener) Close() error { return nil }
+
+func TestFSockLifecycleHooks(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	kln := keepOpenListener{ln}
+	addr := mockFreeSWITCHOn(t, kln, func(c net.Conn) {
+		mockFreeSWITCHOn(t, kln, func(c net.Conn) {
+			io.Copy(io.Discard, c)
+		})
+		c.Close() // dropped, reconnecting to the second mock
+	})
+
+	hooks := make(chan string, 10)
+	hook := func(name string) ConnHook {
+		return func(connIdx int, remoteAddr net.Addr) {
+			if connIdx != 2 || remoteAddr.String() != ln.Addr().String() {
+				t.Errorf("unexpected %s hook arguments: %d, %v", name, connIdx, remoteAddr)
+			}
+			hooks <- name
+		}
+	}
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithConnIdx(2),
+		WithReconnects(3),
+		WithOnConnect(hook("connect")),
+		WithOnDisconnect(hook("disconnect")),
+		WithOnReconnect(hook("reconnect")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	rcv := make([]string, 0, 3)
+	for range 3 {
+		select {
+		case name := <-hooks:
+			rcv = append(rcv, name)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("hooks missing, received: %v", rcv)
+		}
+	}
+	slices.Sort(rcv) // hooks are asynchronous
+	if exp := []string{"connect", "disconnect", "reconnect"}; !reflect.DeepEqual(exp, rcv) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+}
+
+func TestFSockWriteTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	fsConn := newFSConn(client, 0, 0, make(chan error, 1), nopLogger{}, nil, nil)
+	fsConn.writeTimeout = 50 * time.Millisecond
+	defer fsConn.Disconnect()
+	// Nobody reads from server, the write cannot complete.
+	if err := fsConn.send("api status\n\n"); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", os.ErrDeadlineExceeded, err)
+	}
+}
+
+func TestFSockDialTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn) // never completes the TLS handshake
+	}()
+	start := time.Now()
+	_, err = NewFSockWithOptions(ln.Addr().String(), "ClueCon",
+		WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+		WithDialTimeout(100*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dial not bounded, took: %v", elapsed)
+	}
+}
+
+func TestFSockCustomDialer(t *testing.T) {
+	srvCfg, clntCfg := selfSignedTLSConfig(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srvCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopFS := make(chan struct{})
+	t.Cleanup(func() { close(stopFS) })
+	addr := mockFreeSWITCHOn(t, ln, func(net.Conn) {
+		<-stopFS
+	})
+
+	var dialed []string
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithTLSConfig(clntCfg),
+		WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, network+"://"+addr)
+			return new(net.Dialer).DialContext(ctx, network, addr)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if exp := []string{"tcp://" + addr}; !reflect.DeepEqual(exp, dialed) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, dialed)
+	}
+	if _, isTLS := fs.fsConn.conn.(*tls.Conn); !isTLS {
+		t.Errorf("expected a TLS connection, got %T", fs.fsConn.conn)
+	}
+
+	errDial := errors.New("dial refused")
+	if _, err := NewFSockWithOptions(addr, "ClueCon",
+		WithDialer(func(context.Context, string, string) (net.Conn, error) {
+			return nil, errDial
+		})); err != errDial {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", errDial, err)
+	}
+}
+
+func TestFSockNewFSConnFromConn(t *testing.T) {
+	cmds := make(chan string, 1)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		replyCommands(t, c, cmds)
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsConn, err := NewFSConnFromConn(conn, "ClueCon", 0, time.Second, make(chan error, 1), nopLogger{},
+		nil, map[string][]func(string, int){"HEARTBEAT": {func(string, int) {}}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsConn.Disconnect()
+	if rply, err := fsConn.Send("api status\n\n"); err != nil || rply != "+OK" {
+		t.Errorf("unexpected reply: %q, err: %v", rply, err)
+	}
+	if cmd := <-cmds; cmd != "api status" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "api status", cmd)
+	}
+
+	client, server := net.Pipe()
+	go func() {
+		server.Write([]byte("Content-Type: text/disconnect-notice\n\n"))
+	}()
+	if _, err := NewFSConnFromConn(client, "ClueCon", 0, time.Second, make(chan error, 1), nopLogger{},
+		nil, nil, false); err == nil || err.Error() != "no auth challenge received" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "no auth challenge received", err)
+	}
+	if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected the connection to be closed, received: %v", err)
+	}
+}
+
+func TestFSockFailoverAndFailback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primaryAddr := ln.Addr().String()
+	ln.Close() // primary down
+
+	standbyAddr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	fs, err := NewFSockWithOptions(primaryAddr, "ClueCon",
+		WithStandbyAddrs(standbyAddr),
+		WithFailback(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	remoteAddr := func() string {
+		fs.mu.RLock()
+		defer fs.mu.RUnlock()
+		return fs.fsConn.conn.RemoteAddr().String()
+	}
+	if _, standbyPort, _ := net.SplitHostPort(standbyAddr); !strings.HasSuffix(remoteAddr(), ":"+standbyPort) {
+		t.Errorf("expected to fail over to %s, connected to %s", standbyAddr, remoteAddr())
+	}
+
+	// Primary back up.
+	if ln, err = net.Listen("tcp", primaryAddr); err != nil {
+		t.Skipf("cannot listen again on the primary address: %v", err)
+	}
+	go func() {
+		probe, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		probe.Close()
+		mockFreeSWITCHOn(t, ln, func(c net.Conn) {
+			io.Copy(io.Discard, c)
+		})
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for remoteAddr() != primaryAddr {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected to fail back to %s, connected to %s", primaryAddr, remoteAddr())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFSockSetReplyTimeout(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c) // the commands are never answered
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	fs.SetReplyTimeout(50 * time.Millisecond)
+	if _, err := fs.SendApiCmd("status"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+	}
+	if fs.replyTimeout != 50*time.Millisecond {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 50*time.Millisecond, fs.replyTimeout)
+	}
+}
+
+func TestFSockSetLogger(t *testing.T) {
+	send := make(chan struct{})
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		<-send
+		event := "Event-Name: CHANNEL_ANSWER\n\n"
+		if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(event), event); err != nil {
+			t.Error(err)
+			return
+		}
+		io.Copy(io.Discard, c)
+	})
+	var before, after syncBuffer
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithLogger(NewSlogLogger(slog.New(slog.NewTextHandler(&before, nil)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	fs.SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(&after, nil))))
+	close(send)
+	for start := time.Now(); !strings.Contains(after.String(), "No dispatcher"); time.Sleep(5 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("the event was not logged by the new logger: %s", after.String())
+		}
+	}
+	if strings.Contains(before.String(), "No dispatcher") {
+		t.Errorf("the event was logged by the replaced logger: %s", before.String())
+	}
+	fs.SetLogger(nil)
+	if _, isNop := fs.getLogger().(nopLogger); !isNop {
+		t.Errorf("expected nopLogger, received: %T", fs.getLogger())
+	}
+}
+
+func TestFSockPoolSetLogger(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	fs := NewFSockPool(1, addr, "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil)
+	defer fs.Close()
+	fsk, err := fs.PopFSock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lgr := new(loggerMock)
+	fs.SetLogger(lgr)
+	if fsk.getLogger() == Logger(lgr) {
+		t.Error("borrowed FSock changed before being pushed back")
+	}
+	fs.PushFSock(fsk)
+	if fsk.getLogger() != Logger(lgr) {
+		t.Errorf("\nExpected: <%T>, \nReceived: <%T>", lgr, fsk.getLogger())
+	}
+	fs.SetLogger(nil)
+	if _, isNop := fsk.getLogger().(nopLogger); !isNop {
+		t.Errorf("idle FSock kept its logger: %T", fsk.getLogger())
+	}
+}
+
+func TestFSockConnInfo(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		event := "Event-Name: CHANNEL_ANSWER\n\n"
+		if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(event), event); err != nil {
+			t.Error(err)
+			return
+		}
+		io.Copy(io.Discard, c)
+	})
+	infos := make(chan ConnInfo, 2)
+	var logged syncBuffer
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithConnIdx(3),
+		WithConnID("fs-eu-1"),
+		WithConnMetadata(map[string]string{"site": "eu"}),
+		WithSlogLogger(slog.New(slog.NewTextHandler(&logged, nil))),
+		WithConnInfoEventHandlers(map[string][]func(string, ConnInfo){
+			"CHANNEL_ANSWER": {func(_ string, info ConnInfo) { infos <- info }},
+		}),
+		WithEventHandlersCtx(map[string][]EventHandlerCtx{
+			"CHANNEL_ANSWER": {func(ctx context.Context, lgr FieldLogger, _ string, _ int) {
+				info, _ := ConnInfoFromContext(ctx)
+				infos <- info
+				lgr.With("event_name", "CHANNEL_ANSWER").Info("handled")
+			}},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	exp := ConnInfo{Idx: 3, ID: "fs-eu-1", Metadata: map[string]string{"site": "eu"}}
+	for range 2 {
+		select {
+		case info := <-infos:
+			if !reflect.DeepEqual(info, exp) {
+				t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, info)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("event not handled")
+		}
+	}
+	if rcv := fs.ConnInfo(); !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+	for start := time.Now(); !strings.Contains(logged.String(), "msg=handled"); time.Sleep(5 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("handler log missing: %s", logged.String())
+		}
+	}
+	if exp := "msg=handled conn_idx=3 conn_id=fs-eu-1 site=eu event_name=CHANNEL_ANSWER"; !strings.Contains(logged.String(), exp) {
+		t.Errorf("expected %q within: %s", exp, logged.String())
+	}
+}
+
+func TestFSockShutdown(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		rdr := bufio.NewReader(c)
+		var jobUUID string
+		for hasUUID := false; !hasUUID; {
+			line, err := rdr.ReadString('\n')
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			jobUUID, hasUUID = strings.CutPrefix(strings.TrimSpace(line), "Job-UUID:")
+		}
+		if _, err := c.Write([]byte("Content-Type: command/reply\nReply-Text: +OK Job-UUID: " + jobUUID + "\n\n")); err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(50 * time.Millisecond) // the job takes a while
+		for _, event := range []string{
+			"Event-Name: CHANNEL_ANSWER\n\n",
+			"Event-Name: BACKGROUND_JOB\nJob-UUID: " + jobUUID + "\nContent-Length: 4\n\n+OK\n",
+		} {
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		io.Copy(io.Discard, rdr)
+	})
+	var handled atomic.Bool
+	stopError := make(chan error, 1)
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithBgapi(true), WithStopError(stopError),
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {func(string, int) {
+				time.Sleep(50 * time.Millisecond)
+				handled.Store(true)
+			}},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := fs.SendBgapiCmd("originate user/1001 &park")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobRply := make(chan string, 1)
+	go func() { jobRply <- <-out }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := fs.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !handled.Load() {
+		t.Error("shut down before the event was handled")
+	}
+	select {
+	case rply := <-jobRply:
+		if rply != "+OK\n" {
+			t.Errorf("\nExpected: %q, \nReceived: %q", "+OK\n", rply)
+		}
+	case <-time.After(time.Second):
+		t.Error("bgapi job stranded")
+	}
+	if _, err := fs.SendApiCmd("status"); err != ErrShutdown {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrShutdown, err)
+	}
+	if err := <-stopError; err != nil {
+		t.Errorf("expected an intentional shutdown, received: %v", err)
+	}
+}
+
+func TestFSockShutdownCtx(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		replyCommands(t, c, cmds)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithBgapi(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.SendBgapiCmd("originate user/1001 &park"); err != nil { // never completed
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := fs.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+	}
+	if fs.Connected() {
+		t.Error("still connected after the shutdown")
+	}
+}
+
+func TestFSockPipelinedCommands(t *testing.T) {
+	const cmdsNr = 5
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		rdr := bufio.NewReader(c)
+		readCmd := func() string {
+			for {
+				line, err := rdr.ReadString('\n')
+				if err != nil {
+					return ""
+				}
+				if line = strings.TrimSpace(line); line != "" {
+					return strings.TrimPrefix(line, "api ")
+				}
+			}
+		}
+		reply := func(body string) {
+			if _, err := fmt.Fprintf(c, "Content-Type: api/response\nContent-Length: %d\n\n%s", len(body), body); err != nil {
+				t.Error(err)
+			}
+		}
+		// all the commands arrive before the first reply is sent
+		var cmds []string
+		for range cmdsNr {
+			cmds = append(cmds, readCmd())
+		}
+		for _, cmd := range cmds {
+			reply(cmd)
+		}
+		// the reply of the command which gave up waiting is not handed to the next one
+		slow := readCmd()
+		fast := readCmd()
+		reply(slow)
+		reply(fast)
+		io.Copy(io.Discard, c)
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	var wg sync.WaitGroup
+	for i := range cmdsNr {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exp := strconv.Itoa(i)
+			if rply, err := fs.SendApiCmd(exp); err != nil {
+				t.Error(err)
+			} else if rply != exp {
+				t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rply)
+			}
+		}()
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fs.SendApiCmdCtx(ctx, "slow"); err != context.DeadlineExceeded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+	}
+	if rply, err := fs.SendApiCmd("fast"); err != nil {
+		t.Error(err)
+	} else if rply != "fast" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "fast", rply)
+	}
+}
+
+func TestFSockSRV(t *testing.T) {
+	srv1, srv2 := fsocktest.NewServer(t), fsocktest.NewServer(t)
+	srvAddr := func(srv *fsocktest.Server) *net.SRV {
+		host, port, _ := net.SplitHostPort(srv.Addr())
+		portNr, _ := strconv.Atoi(port)
+		return &net.SRV{Target: host + ".", Port: uint16(portNr)}
+	}
+	dead := &net.SRV{Target: "127.0.0.1.", Port: 1}
+	var mu sync.Mutex
+	targets := []*net.SRV{dead, srvAddr(srv1)}
+	defer func(orig func(context.Context, string, string, string) (string, []*net.SRV, error)) {
+		lookupSRV = orig
+	}(lookupSRV)
+	lookupSRV = func(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+		if name != "_esl._tcp.fs.cgrates.org" {
+			return "", nil, fmt.Errorf("unexpected name: %s", name)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return name, targets, nil
+	}
+	connected := make(chan string, 2)
+	hook := func(_ int, remoteAddr net.Addr) { connected <- remoteAddr.String() }
+	fs, err := NewFSockWithOptions("srv:_esl._tcp.fs.cgrates.org", fsocktest.DefaultPassword,
+		WithReconnects(50), WithBackoffPolicy(new(backoffMock)),
+		WithOnConnect(hook), WithOnReconnect(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if rcv := <-connected; rcv != srv1.Addr() {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", srv1.Addr(), rcv)
+	}
+
+	mu.Lock()
+	targets = []*net.SRV{srvAddr(srv2)} // failed over through DNS
+	mu.Unlock()
+	srv1.Close()
+	select {
+	case rcv := <-connected:
+		if rcv != srv2.Addr() {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", srv2.Addr(), rcv)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not reconnected to the target resolved anew")
+	}
+}
+
+func TestFSockKeepAlive(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	for _, period := range []time.Duration{time.Second, -1} {
+		fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword, WithKeepAlive(period))
+		if err != nil {
+			t.Fatalf("keepalive %v: %v", period, err)
+		}
+		fs.Disconnect()
+	}
+
+	// Reaching the TCP connection through the wrappers, closed so setting fails.
+	conn, err := net.Dial("tcp", srv.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	for name, wrapped := range map[string]net.Conn{
+		"tcp":      conn,
+		"tls":      tls.Client(conn, &tls.Config{}),
+		"buffered": &bufferedConn{Conn: conn, rdr: bufio.NewReader(conn)},
+	} {
+		if err := setKeepAlive(wrapped, time.Second); err == nil {
+			t.Errorf("%s: expected the error of the closed connection", name)
+		}
+		if err := setKeepAlive(wrapped, 0); err != nil {
+			t.Errorf("%s: expected the connection untouched, received: %v", name, err)
+		}
+	}
+	pipe, _ := net.Pipe()
+	if err := setKeepAlive(pipe, time.Second); err != nil {
+		t.Errorf("expected the non TCP connection untouched, received: %v", err)
+	}
+}
+
+func TestFSockState(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs := newFSock(srv.Addr(), fsocktest.DefaultPassword,
+		WithReconnects(1), WithBackoffPolicy(new(backoffMock)))
+	if state := fs.State(); state != StateDisconnected {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", StateDisconnected, state)
+	}
+	if err := fs.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if state := fs.State(); state != StateConnected || !fs.Connected() {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", StateConnected, state)
+	}
+	if err := fs.Disconnect(); err != nil {
+		t.Error(err)
+	}
+	if state := fs.State(); state != StateClosed || fs.Connected() {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", StateClosed, state)
+	}
+
+	if err := fs.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopErrs := fs.StopErrors(ctx)
+	srv.Close() // no reconnecting to it
+	select {
+	case <-stopErrs:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the final disconnect")
+	}
+	if state := fs.State(); state != StateDisconnected {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", StateDisconnected, state)
+	}
+	if rcv := ConnState(9).String(); rcv != "ConnState(9)" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "ConnState(9)", rcv)
+	}
+	if rcv := StateReconnecting.String(); rcv != "reconnecting" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "reconnecting", rcv)
+	}
+}
+
+func TestFSockDisconnectDuringReconnect(t *testing.T) {
+	fs := newFSock("127.0.0.1:1", "ClueCon")
+	fs.state.Store(int32(StateReconnecting))
+	fs.mu.Lock() // held by the reconnect
+	done := make(chan error, 1)
+	go func() { done <- fs.Disconnect() }()
+	for fs.State() != StateClosed {
+		time.Sleep(time.Millisecond)
+	}
+	fs.setState(StateConnected) // the reconnect completing
+	fs.mu.Unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Disconnect did not return")
+	}
+	if state := fs.State(); state != StateClosed {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", StateClosed, state)
+	}
+}
@@ -0,0 +1,252 @@
+/*
+fsockcluster.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ClusterNode describes one FreeSWITCH instance of a FSockCluster.
+type ClusterNode struct {
+	Name    string   // identifies the node when routing commands and dispatching events
+	Addr    string   // address of the event socket
+	Passwd  string   // event socket password
+	Options []Option // applied after the options common to all the nodes
+}
+
+// ClusterEventHandler handles the events of a FSockCluster, tagged with the name
+// of the node they originate from.
+type ClusterEventHandler func(event, node string, connIdx int)
+
+// NewFSockCluster connects to every node, with one FSock each, dispatching the events of
+// all of them to eventHandlers. The nodes get their index as connIdx unless their options
+// say otherwise. Fails if any of the nodes cannot be connected.
+func NewFSockCluster(nodes []ClusterNode, eventHandlers map[string][]ClusterEventHandler,
+	opts ...Option) (*FSockCluster, error) {
+	fsc := &FSockCluster{
+		eventHandlers: make(map[string][]ClusterEventHandler),
+		nodes:         make(map[string]*clusterNode, len(nodes)),
+	}
+	for evName, handlers := range eventHandlers {
+		fsc.eventHandlers[evName] = slices.Clone(handlers)
+	}
+	for i, node := range nodes {
+		if _, has := fsc.nodes[node.Name]; has {
+			fsc.Disconnect()
+			return nil, fmt.Errorf("duplicate cluster node: <%s>", node.Name)
+		}
+		cn := &clusterNode{dispatchers: make(map[string]HandlerID)}
+		nodeHandlers := make(map[string]func(string, int), len(fsc.eventHandlers))
+		for evName := range fsc.eventHandlers {
+			nodeHandlers[evName] = cn.dispatcher(fsc, node.Name, evName)
+		}
+		nodeOpts := append([]Option{WithConnIdx(i)}, opts...)
+		nodeOpts = append(nodeOpts, node.Options...)
+		nodeOpts = append(nodeOpts, withRemovableEventHandlers(nodeHandlers, cn.dispatchers))
+		var err error
+		if cn.fs, err = NewFSockWithOptions(node.Addr, node.Passwd, nodeOpts...); err != nil {
+			fsc.Disconnect()
+			return nil, fmt.Errorf("cluster node <%s>: %w", node.Name, err)
+		}
+		fsc.nodes[node.Name] = cn
+		fsc.names = append(fsc.names, node.Name)
+	}
+	return fsc, nil
+}
+
+// FSockCluster manages the connections towards several FreeSWITCH instances, merging
+// their events into one handler registry and routing the commands by node name.
+type FSockCluster struct {
+	mu            sync.RWMutex // protects eventHandlers, handlerIDs and lastHandlerID
+	subMu         sync.Mutex   // serializes the node subscriptions, held while sending their commands
+	eventHandlers map[string][]ClusterEventHandler
+	handlerIDs    map[string][]HandlerID // of the handlers added at runtime, ending eventHandlers
+	lastHandlerID HandlerID
+	nodes         map[string]*clusterNode
+	names         []string // node names, in configuration order
+}
+
+// clusterNode is the FSock of one node, along with the IDs of the handlers dispatching
+// its events to the cluster, one per event name.
+type clusterNode struct {
+	fs          *FSock
+	dispatchers map[string]HandlerID
+}
+
+// dispatcher returns the handler of the node dispatching evName to the cluster.
+func (cn *clusterNode) dispatcher(fsc *FSockCluster, node, evName string) func(string, int) {
+	return func(event string, connIdx int) {
+		fsc.dispatchEvent(evName, event, node, connIdx)
+	}
+}
+
+// subscribe adds, unless added already, the handler of the node dispatching evName to the cluster.
+func (cn *clusterNode) subscribe(fsc *FSockCluster, node, evName string) error {
+	if _, has := cn.dispatchers[evName]; has {
+		return nil
+	}
+	id, err := cn.fs.AddEventHandler(evName, cn.dispatcher(fsc, node, evName))
+	cn.dispatchers[evName] = id // kept over reconnects even if subscribing failed
+	return err
+}
+
+// withRemovableEventHandlers adds the handlers on construction, one per event name, as
+// AddEventHandler does, storing their IDs into ids.
+func withRemovableEventHandlers(handlers map[string]func(string, int), ids map[string]HandlerID) Option {
+	return func(fs *FSock) { fs.removableHandlers, fs.removableIDs = handlers, ids }
+}
+
+// dispatchEvent hands the event received by node to the cluster handlers of evName.
+func (fsc *FSockCluster) dispatchEvent(evName, event, node string, connIdx int) {
+	fsc.mu.RLock()
+	handlers := fsc.eventHandlers[evName]
+	fsc.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(event, node, connIdx)
+	}
+}
+
+// Nodes returns the names of the nodes, in configuration order.
+func (fsc *FSockCluster) Nodes() []string {
+	return slices.Clone(fsc.names)
+}
+
+// Node returns the FSock connected to the named node, nil if there is no such node.
+func (fsc *FSockCluster) Node(name string) *FSock {
+	cn, has := fsc.nodes[name]
+	if !has {
+		return nil
+	}
+	return cn.fs
+}
+
+// node returns the named node, erroring if there is no such node.
+func (fsc *FSockCluster) node(name string) (*clusterNode, error) {
+	cn, has := fsc.nodes[name]
+	if !has {
+		return nil, fmt.Errorf("unknown cluster node: <%s>", name)
+	}
+	return cn, nil
+}
+
+// AddEventHandler registers the handler for the event on all the nodes, subscribing
+// to it where needed. The returned id removes it, see RemoveEventHandler.
+func (fsc *FSockCluster) AddEventHandler(eventName string, handler ClusterEventHandler) (id HandlerID, err error) {
+	fsc.subMu.Lock()
+	defer fsc.subMu.Unlock()
+	fsc.mu.Lock()
+	fsc.lastHandlerID++
+	id = fsc.lastHandlerID
+	fsc.eventHandlers[eventName] = append(fsc.eventHandlers[eventName], handler)
+	if fsc.handlerIDs == nil {
+		fsc.handlerIDs = make(map[string][]HandlerID)
+	}
+	fsc.handlerIDs[eventName] = append(fsc.handlerIDs[eventName], id)
+	fsc.mu.Unlock()
+	// Out of mu, the events keep being dispatched while the nodes reply.
+	var errs []error
+	for _, name := range fsc.names {
+		if err := fsc.nodes[name].subscribe(fsc, name, eventName); err != nil {
+			errs = append(errs, fmt.Errorf("cluster node <%s>: %w", name, err))
+		}
+	}
+	return id, errors.Join(errs...)
+}
+
+// RemoveEventHandler unregisters the handler added with AddEventHandler under id,
+// unsubscribing the nodes once no handlers are left for the event. Unknown IDs are ignored.
+func (fsc *FSockCluster) RemoveEventHandler(id HandlerID) (err error) {
+	fsc.subMu.Lock()
+	defer fsc.subMu.Unlock()
+	eventName, left, found := fsc.removeHandler(id)
+	if !found || left != 0 {
+		return
+	}
+	var errs []error
+	for _, name := range fsc.names {
+		cn := fsc.nodes[name]
+		nodeID, has := cn.dispatchers[eventName]
+		if !has {
+			continue
+		}
+		delete(cn.dispatchers, eventName)
+		if err := cn.fs.RemoveEventHandler(nodeID); err != nil {
+			errs = append(errs, fmt.Errorf("cluster node <%s>: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeHandler drops the handler with the given id from the registry, returning its
+// event and the number of handlers left for it.
+func (fsc *FSockCluster) removeHandler(id HandlerID) (eventName string, left int, found bool) {
+	fsc.mu.Lock()
+	defer fsc.mu.Unlock()
+	for eventName, ids := range fsc.handlerIDs {
+		idx := slices.Index(ids, id)
+		if idx == -1 {
+			continue
+		}
+		handlers := fsc.eventHandlers[eventName]
+		hIdx := len(handlers) - len(ids) + idx // the runtime handlers end the list, in the same order
+		if handlers = slices.Delete(slices.Clone(handlers), hIdx, hIdx+1); len(handlers) != 0 {
+			fsc.eventHandlers[eventName] = handlers
+		} else {
+			delete(fsc.eventHandlers, eventName)
+		}
+		if ids = slices.Delete(slices.Clone(ids), idx, idx+1); len(ids) != 0 {
+			fsc.handlerIDs[eventName] = ids
+		} else {
+			delete(fsc.handlerIDs, eventName)
+		}
+		return eventName, len(handlers), true
+	}
+	return
+}
+
+// SendCmd sends the command to the named node.
+func (fsc *FSockCluster) SendCmd(node, cmdStr string) (string, error) {
+	cn, err := fsc.node(node)
+	if err != nil {
+		return "", err
+	}
+	return cn.fs.SendCmd(cmdStr)
+}
+
+// SendApiCmd sends the API command to the named node.
+func (fsc *FSockCluster) SendApiCmd(node, cmdStr string) (string, error) {
+	cn, err := fsc.node(node)
+	if err != nil {
+		return "", err
+	}
+	return cn.fs.SendApiCmd(cmdStr)
+}
+
+// SendBgapiCmd sends the BGAPI command to the named node.
+func (fsc *FSockCluster) SendBgapiCmd(node, cmdStr string) (chan string, error) {
+	cn, err := fsc.node(node)
+	if err != nil {
+		return nil, err
+	}
+	return cn.fs.SendBgapiCmd(cmdStr)
+}
+
+// Disconnect disconnects from all the nodes.
+func (fsc *FSockCluster) Disconnect() error {
+	var errs []error
+	for name, cn := range fsc.nodes {
+		if err := cn.fs.Disconnect(); err != nil {
+			errs = append(errs, fmt.Errorf("cluster node <%s>: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,114 @@
+/*
+fsockcluster_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// mockClusterNode serves one event socket connection, sending a HEARTBEAT
+// event with the given hostname and answering the commands received.
+func mockClusterNode(t *testing.T, hostname string, cmds chan<- string) string {
+	return mockFreeSWITCH(t, func(c net.Conn) {
+		event := "Event-Name: HEARTBEAT\nFreeSWITCH-Hostname: " + hostname + "\n\n"
+		if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(event), event); err != nil {
+			t.Error(err)
+			return
+		}
+		replyCommands(t, c, cmds)
+	})
+}
+
+func TestFSockClusterDispatchAndRoute(t *testing.T) {
+	cmds1, cmds2 := make(chan string, 10), make(chan string, 10)
+	type taggedEvent struct {
+		hostname, node string
+		connIdx        int
+	}
+	events := make(chan taggedEvent, 10)
+	fsc, err := NewFSockCluster([]ClusterNode{
+		{Name: "fs1", Addr: mockClusterNode(t, "host1", cmds1), Passwd: "ClueCon"},
+		{Name: "fs2", Addr: mockClusterNode(t, "host2", cmds2), Passwd: "ClueCon"},
+	}, map[string][]ClusterEventHandler{
+		"HEARTBEAT": {func(event, node string, connIdx int) {
+			events <- taggedEvent{headerVal(event, "FreeSWITCH-Hostname"), node, connIdx}
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsc.Disconnect()
+
+	var rcv []taggedEvent
+	for range 2 {
+		select {
+		case ev := <-events:
+			rcv = append(rcv, ev)
+		case <-time.After(time.Second):
+			t.Fatalf("events missing, received: %+v", rcv)
+		}
+	}
+	slices.SortFunc(rcv, func(a, b taggedEvent) int { return a.connIdx - b.connIdx })
+	if exp := []taggedEvent{{"host1", "fs1", 0}, {"host2", "fs2", 1}}; !reflect.DeepEqual(exp, rcv) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+
+	if exp := []string{"fs1", "fs2"}; !reflect.DeepEqual(exp, fsc.Nodes()) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, fsc.Nodes())
+	}
+	if _, err := fsc.SendApiCmd("fs2", "status"); err != nil {
+		t.Error(err)
+	}
+	if cmd := <-cmds2; cmd != "api status" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "api status", cmd)
+	}
+	if _, err := fsc.SendApiCmd("fs3", "status"); err == nil || err.Error() != "unknown cluster node: <fs3>" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "unknown cluster node: <fs3>", err)
+	}
+
+	answer := func(string, string, int) {}
+	id1, err := fsc.AddEventHandler("CHANNEL_ANSWER", answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := fsc.AddEventHandler("CHANNEL_ANSWER", answer) // the same function, removed apart
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsc.RemoveEventHandler(id1); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fsc.eventHandlers["CHANNEL_ANSWER"]); n != 1 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 1, n)
+	}
+	if err := fsc.RemoveEventHandler(id2); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmds := range []chan string{cmds1, cmds2} {
+		for _, exp := range []string{"event plain CHANNEL_ANSWER", "nixevent CHANNEL_ANSWER"} {
+			if cmd := <-cmds; cmd != exp {
+				t.Errorf("\nExpected: %q, \nReceived: %q", exp, cmd)
+			}
+		}
+	}
+}
+
+func TestFSockClusterDuplicateNode(t *testing.T) {
+	addr := mockClusterNode(t, "host1", make(chan string, 10))
+	if _, err := NewFSockCluster([]ClusterNode{
+		{Name: "fs1", Addr: addr, Passwd: "ClueCon"},
+		{Name: "fs1", Addr: addr, Passwd: "ClueCon"},
+	}, nil); err == nil || err.Error() != "duplicate cluster node: <fs1>" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "duplicate cluster node: <fs1>", err)
+	}
+}
@@ -9,8 +9,13 @@ Provides FreeSWITCH socket communication.
 package fsock
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,10 +27,11 @@ func NewFSockPool(maxFSocks int,
 	delayFuncConstructor func(time.Duration, time.Duration) func() time.Duration,
 	eventHandlers map[string][]func(string, int),
 	eventFilters map[string][]string,
-	logger logger,
+	logger Logger,
 	connIdx int,
 	bgapi bool,
 	stopError chan error,
+	opts ...PoolOption,
 ) *FSockPool {
 	if logger == nil ||
 		(reflect.ValueOf(logger).Kind() == reflect.Ptr && reflect.ValueOf(logger).IsNil()) {
@@ -48,12 +54,80 @@ func NewFSockPool(maxFSocks int,
 		bgapi:                bgapi,
 		stopError:            stopError,
 	}
+	for _, opt := range opts {
+		opt(pool)
+	}
 	for i := 0; i < maxFSocks; i++ {
 		pool.allowedConns <- struct{}{} // Empty initiate so we do not need to wait later when we pop
 	}
 	return pool
 }
 
+// Defaults applied by NewFSockPoolFromConfig for the settings left unset.
+const (
+	DefaultPoolMaxFSocks   = 1
+	DefaultPoolMaxWaitConn = 2 * time.Second
+
+	// DefaultPoolAddrQuarantine is the time an address failing to connect is skipped for.
+	DefaultPoolAddrQuarantine = 10 * time.Second
+)
+
+// PoolConfig gathers the FSockPool settings, so new ones can be added without
+// breaking the callers. The zero value of every field is a valid setting.
+type PoolConfig struct {
+	MaxFSocks            int           // maximum FSocks connected at once, defaults to DefaultPoolMaxFSocks
+	Addr                 string        // FreeSWITCH address
+	Passwd               string        // event socket password
+	Reconnects           int           // reconnect attempts of each FSock
+	MaxWaitConn          time.Duration // maximum wait for a FSock on pop, defaults to DefaultPoolMaxWaitConn
+	MaxReconnectInterval time.Duration
+	ReplyTimeout         time.Duration
+	DelayFunc            func(time.Duration, time.Duration) func() time.Duration // defaults to FibDuration
+	EventHandlers        map[string][]func(string, int)
+	CtxEventHandlers     map[string][]EventHandlerCtx
+	EventFilters         map[string][]string
+	Logger               Logger
+	ConnIdx              int
+	Bgapi                bool
+	StopError            chan error
+	TLSConfig            *tls.Config
+	MinIdle              int           // see WithMinIdle
+	Validate             bool          // see WithPoolValidation
+	MaxIdleTime          time.Duration // see WithMaxIdleTime
+	MaxConnLifetime      time.Duration // see WithMaxConnLifetime
+	Addrs                []string      // see WithPoolAddrs, Addr being ignored when set
+	AddrQuarantine       time.Duration // see WithAddrQuarantine
+	FSockOptions         []Option      // applied to every FSock connected by the pool
+}
+
+// NewFSockPoolFromConfig instantiates a new FSockPool out of cfg, with defaults for the unset settings.
+func NewFSockPoolFromConfig(cfg PoolConfig) *FSockPool {
+	if cfg.MaxFSocks <= 0 {
+		cfg.MaxFSocks = DefaultPoolMaxFSocks
+	}
+	if cfg.MaxWaitConn <= 0 {
+		cfg.MaxWaitConn = DefaultPoolMaxWaitConn
+	}
+	if cfg.DelayFunc == nil {
+		cfg.DelayFunc = FibDuration
+	}
+	return NewFSockPool(cfg.MaxFSocks, cfg.Addr, cfg.Passwd, cfg.Reconnects,
+		cfg.MaxWaitConn, cfg.MaxReconnectInterval, cfg.ReplyTimeout, cfg.DelayFunc,
+		cfg.EventHandlers, cfg.EventFilters, cfg.Logger,
+		cfg.ConnIdx, cfg.Bgapi, cfg.StopError,
+		WithMinIdle(cfg.MinIdle),
+		WithPoolValidation(cfg.Validate),
+		WithMaxIdleTime(cfg.MaxIdleTime),
+		WithMaxConnLifetime(cfg.MaxConnLifetime),
+		WithPoolAddrs(cfg.Addrs...),
+		WithAddrQuarantine(cfg.AddrQuarantine),
+		WithFSockOptions(append([]Option{
+			WithEventHandlersCtx(cfg.CtxEventHandlers),
+			WithTLSConfig(cfg.TLSConfig),
+		}, cfg.FSockOptions...)...),
+	)
+}
+
 // Connection handler for commands sent to FreeSWITCH
 type FSockPool struct {
 	connIdx              int
@@ -66,32 +140,347 @@ type FSockPool struct {
 	maxWaitConn          time.Duration // Maximum duration to wait for a connection to be returned by Pop
 	eventHandlers        map[string][]func(string, int)
 	eventFilters         map[string][]string
-	logger               logger
+	logger               Logger
 	allowedConns         chan struct{} // Will be populated with members allowed
 	fSocks               chan *FSock   // Keep here reference towards the list of opened sockets
 	bgapi                bool
 	stopError            chan error
+	validateOnPop        bool // ping the idle FSocks before handing them out
+	minIdle              int  // idle FSocks to be connected by WarmUp
+	maxIdleTime          time.Duration
+	maxConnLifetime      time.Duration
+	fsockOpts            []Option // extra options of the connected FSocks
+	addrs                []string // all the FreeSWITCH addresses, when more than addr
+	addrQuarantine       time.Duration
+	metrics              *Metrics
+	nextAddr             atomic.Uint64 // round-robin index over addrs
+
+	mu        sync.Mutex             // protects closed, done, connTimes, logger and loggerSet
+	loggerSet bool                   // SetLogger was called, the pushed back FSocks adopt logger
+	closed    bool                   // set by Close
+	done      chan struct{}          // closed by Close, created on first use
+	connTimes map[*FSock]pooledTimes // tracked only with maxIdleTime or maxConnLifetime
+
+	quarantinedUntil map[string]time.Time // addresses skipped after failing to connect
+
+	created      atomic.Uint64 // FSocks connected by the pool
+	borrowed     atomic.Int64  // FSocks popped and not pushed back yet
+	waitCount    atomic.Uint64 // pops which had to wait for a FSock
+	waitDuration atomic.Int64  // cumulated time spent waiting, in nanoseconds
+}
+
+// pooledTimes keeps the moments relevant for recycling a pooled FSock.
+type pooledTimes struct {
+	created   time.Time
+	idleSince time.Time
+}
+
+// PoolStats is a snapshot of the FSockPool usage.
+type PoolStats struct {
+	Created      uint64        // FSocks connected by the pool since its creation
+	Idle         int           // FSocks waiting in the pool to be popped
+	Borrowed     int           // FSocks popped and not pushed back yet
+	WaitCount    uint64        // pops which found no idle FSock and had to wait
+	WaitDuration time.Duration // total time spent by the pops waiting
+}
+
+// Stats returns the usage statistics of the pool.
+func (fs *FSockPool) Stats() PoolStats {
+	if fs == nil {
+		return PoolStats{}
+	}
+	return PoolStats{
+		Created:      fs.created.Load(),
+		Idle:         len(fs.fSocks),
+		Borrowed:     int(fs.borrowed.Load()),
+		WaitCount:    fs.waitCount.Load(),
+		WaitDuration: time.Duration(fs.waitDuration.Load()),
+	}
+}
+
+// PoolOption configures the optional FSockPool settings.
+type PoolOption func(*FSockPool)
+
+// WithPoolValidation pings the idle FSocks before PopFSock hands them out, replacing
+// the ones whose connection died meanwhile with new ones.
+func WithPoolValidation(validate bool) PoolOption {
+	return func(pool *FSockPool) { pool.validateOnPop = validate }
+}
+
+// WithMinIdle sets the number of idle FSocks WarmUp establishes ahead of the first pops,
+// limited by maxFSocks.
+func WithMinIdle(minIdle int) PoolOption {
+	return func(pool *FSockPool) { pool.minIdle = minIdle }
+}
+
+// WithMaxIdleTime recycles the FSocks which stayed idle in the pool for longer than
+// maxIdleTime, before firewalls silently drop their sessions. Zero disables it.
+func WithMaxIdleTime(maxIdleTime time.Duration) PoolOption {
+	return func(pool *FSockPool) { pool.maxIdleTime = maxIdleTime }
+}
+
+// WithMaxConnLifetime recycles the FSocks connected for longer than maxConnLifetime,
+// checked when they are pushed back or popped. Zero disables it.
+func WithMaxConnLifetime(maxConnLifetime time.Duration) PoolOption {
+	return func(pool *FSockPool) { pool.maxConnLifetime = maxConnLifetime }
+}
+
+// WithPoolAddrs spreads the connections of the pool round-robin over addrs, replacing
+// its single address. Addresses failing to connect are quarantined for a while.
+func WithPoolAddrs(addrs ...string) PoolOption {
+	return func(pool *FSockPool) { pool.addrs = addrs }
+}
+
+// WithAddrQuarantine sets the time an address failing to connect is skipped for,
+// DefaultPoolAddrQuarantine if not positive.
+func WithAddrQuarantine(d time.Duration) PoolOption {
+	return func(pool *FSockPool) { pool.addrQuarantine = d }
+}
+
+// WithPoolMetrics includes the pool usage in m, until the pool is closed, collecting as
+// well the activity of the FSocks connected by the pool, see WithMetrics. Ignored if m is nil.
+func WithPoolMetrics(m *Metrics) PoolOption {
+	return func(pool *FSockPool) {
+		if m == nil {
+			return
+		}
+		pool.metrics = m
+		m.addPool(pool)
+	}
+}
+
+// WithFSockOptions applies opts to every FSock connected by the pool, after its own settings.
+func WithFSockOptions(opts ...Option) PoolOption {
+	return func(pool *FSockPool) { pool.fsockOpts = opts }
+}
+
+// WarmUp connects new FSocks until minIdle of them are idle in the pool, sparing
+// the first pops the dial and auth latency. Stops early once maxFSocks are connected.
+func (fs *FSockPool) WarmUp(ctx context.Context) error {
+	if fs == nil {
+		return errors.New("unconfigured connection pool")
+	}
+	for len(fs.fSocks) < fs.minIdle {
+		if _, closed := fs.closeState(); closed {
+			return ErrConnectionPoolClosed
+		}
+		select {
+		case <-fs.allowedConns:
+		default: // all the allowed connections are established
+			return nil
+		}
+		fsock, err := fs.connectAllowed(ctx)
+		if err != nil {
+			return err
+		}
+		fs.putBack(fsock)
+	}
+	return nil
 }
 
 func (fs *FSockPool) PopFSock() (fsock *FSock, err error) {
+	return fs.PopFSockCtx(context.Background())
+}
+
+// PopFSockCtx is the same as PopFSock, giving up on waiting for a connection,
+// as well as on establishing a new one, once ctx is done.
+func (fs *FSockPool) PopFSockCtx(ctx context.Context) (fsock *FSock, err error) {
 	if fs == nil {
 		return nil, errors.New("unconfigured connection pool")
 	}
+	if fsock, err = fs.popFSock(ctx); err == nil {
+		fs.borrowed.Add(1)
+	}
+	return
+}
+
+// popFSock hands out an idle FSock, creating a new one if allowed, or waits for one.
+func (fs *FSockPool) popFSock(ctx context.Context) (fsock *FSock, err error) {
+	done, closed := fs.closeState()
+	if closed {
+		return nil, ErrConnectionPoolClosed
+	}
 	if len(fs.fSocks) != 0 { // Select directly if available, so we avoid randomness of selection
-		fsock = <-fs.fSocks
-		return
+		return fs.validate(ctx, <-fs.fSocks)
+	}
+	select { // Connect a new fsock right away if still allowed
+	case <-fs.allowedConns:
+		return fs.connectAllowed(ctx)
+	default:
 	}
+	fs.waitCount.Add(1)
+	defer func(start time.Time) { fs.waitDuration.Add(int64(time.Since(start))) }(time.Now())
 	tm := time.NewTimer(fs.maxWaitConn)
+	defer tm.Stop()
 	select { // No fsock available in the pool, wait for first one showing up
 	case fsock = <-fs.fSocks:
-		tm.Stop()
-		return
+		return fs.validate(ctx, fsock)
 	case <-fs.allowedConns:
-		tm.Stop()
-		return NewFSock(fs.addr, fs.passwd, fs.reconnects, fs.replyTimeout, fs.maxReconnectInterval, fs.delayFuncConstructor,
-			fs.eventHandlers, fs.eventFilters, fs.logger, fs.connIdx, fs.bgapi, fs.stopError)
+		return fs.connectAllowed(ctx)
 	case <-tm.C:
 		return nil, ErrConnectionPoolTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-done:
+		return nil, ErrConnectionPoolClosed
+	}
+}
+
+// validate replaces the idle fsock with a new one if it is due for recycling or,
+// when validation is configured, if it does not answer the ping.
+func (fs *FSockPool) validate(ctx context.Context, fsock *FSock) (*FSock, error) {
+	if fs.expired(fsock, time.Now()) {
+		fs.getLogger().Debug("<FSock> Recycling expired pooled connection")
+		fs.discard(fsock)
+		return fs.connectAllowed(ctx)
+	}
+	if !fs.validateOnPop {
+		return fsock, nil
+	}
+	err := fsock.PingCtx(ctx)
+	if err == nil {
+		return fsock, nil
+	}
+	fs.getLogger().Warning(fmt.Sprintf("<FSock> Replacing dead pooled connection: %v", err))
+	fs.discard(fsock)
+	return fs.connectAllowed(ctx)
+}
+
+// connectAllowed connects a new fsock in place of an allowed connection, e.g. the one
+// freed by a discarded fsock, giving it back to allowedConns on failure.
+func (fs *FSockPool) connectAllowed(ctx context.Context) (fsock *FSock, err error) {
+	if fsock, err = fs.newFSock(ctx); err != nil {
+		fs.allowedConns <- struct{}{}
+	}
+	return
+}
+
+// tracksConns reports whether the FSocks need their times tracked for recycling.
+func (fs *FSockPool) tracksConns() bool {
+	return fs.maxIdleTime > 0 || fs.maxConnLifetime > 0
+}
+
+// expired reports whether the idle fsock exceeded maxIdleTime or maxConnLifetime at now.
+func (fs *FSockPool) expired(fsock *FSock, now time.Time) bool {
+	if !fs.tracksConns() {
+		return false
+	}
+	fs.mu.Lock()
+	times, has := fs.connTimes[fsock]
+	fs.mu.Unlock()
+	if !has {
+		return false
+	}
+	return (fs.maxConnLifetime > 0 && now.Sub(times.created) > fs.maxConnLifetime) ||
+		(fs.maxIdleTime > 0 && now.Sub(times.idleSince) > fs.maxIdleTime)
+}
+
+// discard disconnects the fsock and stops tracking it.
+func (fs *FSockPool) discard(fsock *FSock) {
+	fsock.Disconnect()
+	if fs.tracksConns() {
+		fs.mu.Lock()
+		delete(fs.connTimes, fsock)
+		fs.mu.Unlock()
+	}
+}
+
+// newFSock connects a new FSock, configured as the pool is, to the next address
+// in round-robin. The addresses failing to connect are quarantined and skipped
+// by the next connects, unless all of them are quarantined.
+func (fs *FSockPool) newFSock(ctx context.Context) (fsock *FSock, err error) {
+	addrs := fs.poolAddrs()
+	start := int(fs.nextAddr.Add(1) - 1)
+	now := time.Now()
+	var tried bool
+	for _, skipQuarantined := range []bool{true, false} {
+		if !skipQuarantined && tried {
+			break
+		}
+		for i := range addrs {
+			addr := addrs[(start+i)%len(addrs)]
+			if skipQuarantined && fs.isQuarantined(addr, now) {
+				continue
+			}
+			tried = true
+			if fsock, err = fs.connectFSock(ctx, addr); err == nil {
+				fs.trackConn(fsock)
+				return
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			if len(addrs) > 1 {
+				fs.getLogger().Warning(fmt.Sprintf("<FSock> Quarantining pool address <%s> after error: %v", addr, err))
+				fs.quarantine(addr)
+			}
+		}
+	}
+	return nil, err
+}
+
+// poolAddrs returns the FreeSWITCH addresses the pool connects to.
+func (fs *FSockPool) poolAddrs() []string {
+	if len(fs.addrs) == 0 {
+		return []string{fs.addr}
+	}
+	return fs.addrs
+}
+
+// isQuarantined reports whether addr failed recently enough to be skipped at now.
+func (fs *FSockPool) isQuarantined(addr string, now time.Time) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return now.Before(fs.quarantinedUntil[addr])
+}
+
+// quarantine excludes addr from the next connects for the configured duration.
+func (fs *FSockPool) quarantine(addr string) {
+	d := fs.addrQuarantine
+	if d <= 0 {
+		d = DefaultPoolAddrQuarantine
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.quarantinedUntil == nil {
+		fs.quarantinedUntil = make(map[string]time.Time)
+	}
+	fs.quarantinedUntil[addr] = time.Now().Add(d)
+}
+
+// connectFSock connects a new FSock to addr.
+func (fs *FSockPool) connectFSock(ctx context.Context, addr string) (fsock *FSock, err error) {
+	opts := append([]Option{
+		WithReconnects(fs.reconnects),
+		WithMaxReconnectInterval(fs.maxReconnectInterval),
+		WithReplyTimeout(fs.replyTimeout),
+		WithDelayFunc(fs.delayFuncConstructor),
+		WithEventHandlers(fs.eventHandlers),
+		WithEventFilters(fs.eventFilters),
+		WithLogger(fs.getLogger()),
+		WithConnIdx(fs.connIdx),
+		WithBgapi(fs.bgapi),
+		WithStopError(fs.stopError),
+		WithMetrics(fs.metrics),
+	}, fs.fsockOpts...)
+	fsock = newFSock(addr, fs.passwd, opts...)
+	if err = fsock.ConnectCtx(ctx); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// trackConn accounts the freshly connected fsock.
+func (fs *FSockPool) trackConn(fsock *FSock) {
+	fs.created.Add(1)
+	if fs.tracksConns() {
+		now := time.Now()
+		fs.mu.Lock()
+		if fs.connTimes == nil {
+			fs.connTimes = make(map[*FSock]pooledTimes)
+		}
+		fs.connTimes[fsock] = pooledTimes{created: now, idleSince: now}
+		fs.mu.Unlock()
 	}
 }
 
@@ -99,9 +488,121 @@ func (fs *FSockPool) PushFSock(fsk *FSock) {
 	if fs == nil { // Did not initialize the pool
 		return
 	}
-	if fsk == nil || !fsk.Connected() {
-		fs.allowedConns <- struct{}{}
+	if fsk != nil {
+		fs.borrowed.Add(-1)
+	}
+	fs.putBack(fsk)
+}
+
+// putBack keeps fsk idle in the pool, or frees its slot if it is no longer usable.
+func (fs *FSockPool) putBack(fsk *FSock) {
+	now := time.Now()
+	fs.mu.Lock()
+	times, tracked := fs.connTimes[fsk]
+	closed := fs.closed // returned after Close, nothing to keep it for
+	if closed || fsk == nil || !fsk.Connected() ||
+		tracked && fs.maxConnLifetime > 0 && now.Sub(times.created) > fs.maxConnLifetime {
+		delete(fs.connTimes, fsk)
+		fs.mu.Unlock()
+		if fsk != nil { // outside the lock, as it may linger; stops the reconnects too
+			fsk.Disconnect()
+		}
+		if !closed {
+			fs.allowedConns <- struct{}{}
+		}
 		return
 	}
+	defer fs.mu.Unlock()
+	if fs.loggerSet {
+		fsk.SetLogger(fs.logger)
+	}
+	if tracked {
+		times.idleSince = now
+		fs.connTimes[fsk] = times
+	}
 	fs.fSocks <- fsk
 }
+
+// getLogger returns the logger in use.
+func (fs *FSockPool) getLogger() Logger {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.logger
+}
+
+// SetLogger replaces the logger of the pool and of its FSocks, the idle ones right away,
+// the borrowed ones once pushed back. A nil logger disables the logging.
+func (fs *FSockPool) SetLogger(lgr Logger) {
+	if lgr == nil ||
+		(reflect.ValueOf(lgr).Kind() == reflect.Ptr && reflect.ValueOf(lgr).IsNil()) {
+		lgr = nopLogger{}
+	}
+	fs.mu.Lock()
+	fs.logger, fs.loggerSet = lgr, true
+	fs.mu.Unlock()
+	for range len(fs.fSocks) {
+		select {
+		case fsk := <-fs.fSocks:
+			fsk.SetLogger(lgr)
+			fs.mu.Lock()
+			closed := fs.closed
+			if !closed {
+				fs.fSocks <- fsk // the slot taken is still free
+			}
+			fs.mu.Unlock()
+			if closed {
+				fsk.Disconnect()
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// closeState returns the channel closed by Close and whether Close was already called.
+func (fs *FSockPool) closeState() (done chan struct{}, closed bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.done == nil {
+		fs.done = make(chan struct{})
+	}
+	return fs.done, fs.closed
+}
+
+// Close stops the pool from handing out connections, waking up the callers waiting for
+// one, and disconnects the pooled FSocks. The ones still borrowed are disconnected once
+// pushed back. Returns the disconnect errors, joined.
+func (fs *FSockPool) Close() error {
+	if fs == nil {
+		return nil
+	}
+	fs.mu.Lock()
+	if fs.closed {
+		fs.mu.Unlock()
+		return nil
+	}
+	fs.closed = true
+	if fs.done == nil {
+		fs.done = make(chan struct{})
+	}
+	close(fs.done)
+	fs.connTimes = nil
+	fs.mu.Unlock()
+	if fs.metrics != nil {
+		fs.metrics.removePool(fs)
+	}
+
+	var errs []error
+	for {
+		select {
+		case fsk := <-fs.fSocks:
+			if err := fsk.Disconnect(); err != nil {
+				errs = append(errs, err)
+			}
+		case <-fs.allowedConns: // no more connections to be created
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+fsockserver.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrServerClosed = errors.New("FSockServer closed")
+)
+
+// NewFSockServer returns a server for the outbound event socket mode, where FreeSWITCH dials
+// into the application (dialplan socket application). Every accepted call is handed
+// to handler as a Session; the connection is closed once handler returns.
+func NewFSockServer(addr string, handler func(*Session), logger Logger) *FSockServer {
+	if logger == nil ||
+		(reflect.ValueOf(logger).Kind() == reflect.Ptr && reflect.ValueOf(logger).IsNil()) {
+		logger = nopLogger{}
+	}
+	return &FSockServer{
+		addr:    addr,
+		handler: handler,
+		logger:  logger,
+	}
+}
+
+// FSockServer accepts outbound event socket connections from FreeSWITCH.
+type FSockServer struct {
+	addr    string
+	handler func(*Session)
+	logger  Logger
+
+	mu       sync.Mutex
+	ln       net.Listener
+	closed   bool
+	connsIdx atomic.Int64 // index handed to the accepted connections
+}
+
+// ListenAndServe listens on the configured address, TCP or unix socket,
+// and serves the incoming connections.
+func (srv *FSockServer) ListenAndServe() error {
+	ln, err := net.Listen(networkAddr(srv.addr))
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ln)
+}
+
+// Serve accepts connections on ln, handling each of them in its own goroutine.
+// It always returns a non-nil error, ErrServerClosed after Close.
+func (srv *FSockServer) Serve(ln net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	srv.ln = ln
+	srv.mu.Unlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go srv.serveConn(conn)
+	}
+}
+
+// Close stops the server from accepting new connections. Sessions already handed
+// to the handler are not affected.
+func (srv *FSockServer) Close() (err error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.closed = true
+	if srv.ln != nil {
+		err = srv.ln.Close()
+	}
+	return
+}
+
+// Addr returns the address the server is listening on, nil if not yet serving.
+func (srv *FSockServer) Addr() net.Addr {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.ln == nil {
+		return nil
+	}
+	return srv.ln.Addr()
+}
+
+// serveConn connects the session and runs the handler on it.
+func (srv *FSockServer) serveConn(conn net.Conn) {
+	connIdx := int(srv.connsIdx.Add(1))
+	sess, err := newSession(conn, connIdx, srv.logger)
+	if err != nil {
+		srv.logger.Err(fmt.Sprintf(
+			"<FSock> Failed to connect outbound session from %s (connection index: %d): %v",
+			conn.RemoteAddr(), connIdx, err))
+		conn.Close()
+		return
+	}
+	defer sess.Disconnect()
+	srv.handler(sess)
+}
+
+// Session is one outbound event socket connection, bound to the call that
+// originated it. Commands can be sent through the embedded FSConn, or through
+// the helpers, e.g. Execute or Hangup.
+type Session struct {
+	*FSConn
+	channelData map[string]string
+	channel     ChannelData
+}
+
+// newSession issues connect on the freshly accepted connection, storing the
+// returned channel data, and starts reading the events.
+func newSession(conn net.Conn, connIdx int, lgr Logger) (*Session, error) {
+	fsConn := newFSConn(conn, connIdx, 0, make(chan error, 1), lgr,
+		make(map[string][]func(string, int)), make(map[string][]EventHandlerCtx))
+	fsConn.outbound = true
+	if err := fsConn.send("connect\n\n"); err != nil {
+		fsConn.cancel()
+		return nil, err
+	}
+	rply, err := fsConn.readHeaders()
+	if err != nil {
+		fsConn.cancel()
+		return nil, err
+	}
+	if !strings.Contains(rply, "command/reply") {
+		fsConn.cancel()
+		return nil, fmt.Errorf("unexpected connect reply received: <%s>", rply)
+	}
+	go fsConn.readEvents()
+	chanData := FSEventStrToMap(rply, nil)
+	return &Session{
+		FSConn:      fsConn,
+		channelData: chanData,
+		channel:     NewChannelData(chanData),
+	}, nil
+}
+
+// ChannelData returns the channel headers and variables received as reply to connect.
+func (sess *Session) ChannelData() map[string]string {
+	return sess.channelData
+}
+
+// UUID returns the unique identifier of the channel handled by the session.
+func (sess *Session) UUID() string {
+	return sess.channelData["Unique-ID"]
+}
+
+// Context returns a context cancelled once the session connection is lost,
+// typically when FreeSWITCH hangs up the call.
+func (sess *Session) Context() context.Context {
+	return sess.ctx
+}
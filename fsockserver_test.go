@@ -0,0 +1,119 @@
+/*
+fsockserver_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialOutbound acts as FreeSWITCH dialing into an outbound server: it expects the
+// connect command and replies with chanData.
+func dialOutbound(t *testing.T, addr, chanData string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdr := bufio.NewReader(conn)
+	if line, err := rdr.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	} else if line != "connect\n" {
+		t.Fatalf("expected connect command, received %q", line)
+	}
+	rdr.ReadString('\n')
+	if _, err := conn.Write([]byte(chanData)); err != nil {
+		t.Fatal(err)
+	}
+	return conn, rdr
+}
+
+func TestFSockServerSession(t *testing.T) {
+	type result struct {
+		uuid, caller, rply string
+		err                error
+	}
+	results := make(chan result, 1)
+	srv := NewFSockServer("127.0.0.1:0", func(sess *Session) {
+		rply, err := sess.Send("api uptime\n\n")
+		results <- result{
+			uuid:   sess.UUID(),
+			caller: sess.ChannelData()["Caller-Caller-ID-Number"],
+			rply:   rply,
+			err:    err,
+		}
+	}, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvErr := make(chan error, 1)
+	go func() { srvErr <- srv.Serve(ln) }()
+
+	conn, rdr := dialOutbound(t, ln.Addr().String(),
+		"Content-Type: command/reply\nReply-Text: +OK\nUnique-ID: 4967ceb1\nCaller-Caller-ID-Number: 1001\n\n")
+	defer conn.Close()
+	if line, err := rdr.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	} else if line != "api uptime\n" {
+		t.Fatalf("expected api command, received %q", line)
+	}
+	rdr.ReadString('\n')
+	if _, err := conn.Write([]byte("Content-Type: api/response\nContent-Length: 4\n\n1234")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case res := <-results:
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		if res.uuid != "4967ceb1" || res.caller != "1001" || res.rply != "1234" {
+			t.Errorf("unexpected session result: %+v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler did not run")
+	}
+
+	// The connection is closed once the handler returns.
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := rdr.ReadByte(); err == nil {
+		t.Error("expected the session connection to be closed")
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := <-srvErr; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrServerClosed, err)
+	}
+}
+
+func TestFSockServerUnexpectedConnectReply(t *testing.T) {
+	l := &loggerMock{}
+	srv := NewFSockServer("127.0.0.1:0", func(*Session) {
+		t.Error("handler should not run")
+	}, l)
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		srv.serveConn(server)
+		close(done)
+	}()
+	rdr := bufio.NewReader(client)
+	rdr.ReadString('\n')
+	rdr.ReadString('\n')
+	client.Write([]byte("Content-Type: text/disconnect-notice\n\n"))
+	<-done
+	if !strings.Contains(l.msg, "unexpected connect reply received") {
+		t.Errorf("unexpected log message: %q", l.msg)
+	}
+}
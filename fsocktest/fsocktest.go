@@ -0,0 +1,388 @@
+/*
+fsocktest.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides a mock FreeSWITCH event socket for testing.
+
+*/
+
+// Package fsocktest provides a mock FreeSWITCH event socket, so the users of fsock can
+// test their event handlers and commands without a live FreeSWITCH.
+package fsocktest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DefaultPassword is the password the Server accepts unless changed with SetPassword.
+const DefaultPassword = "ClueCon"
+
+// Server is a mock FreeSWITCH listening on the loopback interface. It authenticates the
+// clients, through auth or userauth with any user@domain, acknowledges their
+// subscriptions, records the commands received and answers them with the stubbed replies,
+// +OK by default. Safe for concurrent use.
+type Server struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	password string
+	stubs    []stub
+	conns    map[*serverConn]struct{}
+	commands []string
+	expected []string
+	received chan struct{} // closed and replaced whenever a command arrives
+	closed   bool
+}
+
+// stub is the reply given to the commands starting with prefix.
+type stub struct {
+	prefix string
+	reply  string
+}
+
+// serverConn is one client connection, its writes serialized.
+type serverConn struct {
+	net.Conn
+	wMu sync.Mutex
+}
+
+// write sends frame to the client.
+func (sc *serverConn) write(frame string) error {
+	sc.wMu.Lock()
+	defer sc.wMu.Unlock()
+	_, err := io.WriteString(sc.Conn, frame)
+	return err
+}
+
+// NewServer starts a Server, closed once the test tb ends.
+func NewServer(tb testing.TB) *Server {
+	tb.Helper()
+	srv, err := Listen("127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("fsocktest: cannot listen: %v", err)
+	}
+	tb.Cleanup(srv.Close)
+	return srv
+}
+
+// Listen starts a Server on addr, to be closed by the caller.
+func Listen(addr string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &Server{
+		ln:       ln,
+		password: DefaultPassword,
+		conns:    make(map[*serverConn]struct{}),
+		received: make(chan struct{}),
+	}
+	go srv.serve()
+	return srv, nil
+}
+
+// Addr returns the address the Server listens on, to be given to fsock.
+func (srv *Server) Addr() string {
+	return srv.ln.Addr().String()
+}
+
+// Close stops the Server, dropping its connections.
+func (srv *Server) Close() {
+	srv.mu.Lock()
+	srv.closed = true
+	srv.mu.Unlock()
+	srv.ln.Close()
+	srv.DropConnections()
+}
+
+// DropConnections closes the connections of the clients, which may connect again,
+// e.g. for testing their reconnects.
+func (srv *Server) DropConnections() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for sc := range srv.conns {
+		sc.Close()
+		delete(srv.conns, sc)
+	}
+}
+
+// SetPassword changes the password accepted from the clients connecting afterwards,
+// the others being answered with -ERR invalid and disconnected.
+func (srv *Server) SetPassword(password string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.password = password
+}
+
+// Stub answers the commands starting with prefix, e.g. "api status", with reply.
+// The api commands get it as the body of their api/response, the bgapi ones as the
+// body of their BACKGROUND_JOB event and the rest as their Reply-Text. The latest
+// stub matching a command wins.
+func (srv *Server) Stub(prefix, reply string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.stubs = append(srv.stubs, stub{prefix: prefix, reply: reply})
+}
+
+// Expect registers commands which must be received before Verify is called.
+func (srv *Server) Expect(cmds ...string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.expected = append(srv.expected, cmds...)
+}
+
+// Verify returns an error listing the expected commands not received yet.
+func (srv *Server) Verify() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	var missing []string
+	for _, cmd := range srv.expected {
+		if !slices.Contains(srv.commands, cmd) {
+			missing = append(missing, cmd)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("commands not received: %q", missing)
+	}
+	return nil
+}
+
+// Commands returns the commands received after authentication, in order, each with its
+// headers on the following lines.
+func (srv *Server) Commands() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return slices.Clone(srv.commands)
+}
+
+// WaitCommand waits up to timeout for a command starting with prefix, returning it.
+func (srv *Server) WaitCommand(prefix string, timeout time.Duration) (string, error) {
+	deadline := time.After(timeout)
+	for seen := 0; ; {
+		srv.mu.Lock()
+		cmds, received := srv.commands[seen:], srv.received
+		seen = len(srv.commands)
+		srv.mu.Unlock()
+		for _, cmd := range cmds {
+			if strings.HasPrefix(cmd, prefix) {
+				return cmd, nil
+			}
+		}
+		select {
+		case <-received:
+		case <-deadline:
+			return "", fmt.Errorf("no command starting with %q received in %v", prefix, timeout)
+		}
+	}
+}
+
+// SendEvent sends an event made of the headers, Event-Name first and the rest sorted,
+// with an optional body, to every connected client. The values are URL encoded as
+// FreeSWITCH does.
+func (srv *Server) SendEvent(headers map[string]string, body string) error {
+	var sb strings.Builder
+	if evName, has := headers["Event-Name"]; has {
+		fmt.Fprintf(&sb, "Event-Name: %s\n", urlEncode(evName))
+	}
+	hdrNames := make([]string, 0, len(headers))
+	for hdrName := range headers {
+		if hdrName != "Event-Name" {
+			hdrNames = append(hdrNames, hdrName)
+		}
+	}
+	slices.Sort(hdrNames)
+	for _, hdrName := range hdrNames {
+		fmt.Fprintf(&sb, "%s: %s\n", hdrName, urlEncode(headers[hdrName]))
+	}
+	if body != "" {
+		fmt.Fprintf(&sb, "Content-Length: %d\n\n%s", len(body), body)
+	} else {
+		sb.WriteString("\n")
+	}
+	return srv.SendRawEvent(sb.String())
+}
+
+// SendRawEvent sends the plain event, headers and body as FreeSWITCH serializes them,
+// to every connected client.
+func (srv *Server) SendRawEvent(event string) error {
+	return srv.broadcast(fmt.Sprintf("Content-Length: %d\nContent-Type: text/event-plain\n\n%s", len(event), event))
+}
+
+// broadcast writes frame to every connected client.
+func (srv *Server) broadcast(frame string) error {
+	srv.mu.Lock()
+	conns := make([]*serverConn, 0, len(srv.conns))
+	for sc := range srv.conns {
+		conns = append(conns, sc)
+	}
+	srv.mu.Unlock()
+	if len(conns) == 0 {
+		return errors.New("no client connected")
+	}
+	var errs []error
+	for _, sc := range conns {
+		errs = append(errs, sc.write(frame))
+	}
+	return errors.Join(errs...)
+}
+
+// serve accepts the clients until the Server is closed.
+func (srv *Server) serve() {
+	for {
+		conn, err := srv.ln.Accept()
+		if err != nil {
+			return
+		}
+		sc := &serverConn{Conn: conn}
+		srv.mu.Lock()
+		if srv.closed {
+			srv.mu.Unlock()
+			conn.Close()
+			return
+		}
+		srv.conns[sc] = struct{}{}
+		srv.mu.Unlock()
+		go srv.handle(sc)
+	}
+}
+
+// handle authenticates the client, then answers its commands until it disconnects.
+func (srv *Server) handle(sc *serverConn) {
+	defer func() {
+		sc.Close()
+		srv.mu.Lock()
+		delete(srv.conns, sc)
+		srv.mu.Unlock()
+	}()
+	if sc.write("Content-Type: auth/request\n\n") != nil {
+		return
+	}
+	rdr := bufio.NewReader(sc)
+	cmd, err := readCommand(rdr)
+	if err != nil {
+		return
+	}
+	srv.mu.Lock()
+	password := srv.password
+	srv.mu.Unlock()
+	accepted := cmd == "auth "+password
+	if userAuth, isUserAuth := strings.CutPrefix(cmd, "userauth "); isUserAuth {
+		user, passwd, _ := strings.Cut(userAuth, ":")
+		accepted = strings.Contains(user, "@") && passwd == password
+	}
+	if !accepted {
+		sc.write("Content-Type: command/reply\nReply-Text: -ERR invalid\n\n")
+		return
+	}
+	if sc.write("Content-Type: command/reply\nReply-Text: +OK accepted\n\n") != nil {
+		return
+	}
+	for {
+		if cmd, err = readCommand(rdr); err != nil {
+			return
+		}
+		if srv.reply(sc, cmd) != nil {
+			return
+		}
+	}
+}
+
+// reply records cmd and answers it.
+func (srv *Server) reply(sc *serverConn, cmd string) error {
+	srv.mu.Lock()
+	srv.commands = append(srv.commands, cmd)
+	close(srv.received)
+	srv.received = make(chan struct{})
+	reply, stubbed := "+OK", false
+	for i := len(srv.stubs) - 1; i >= 0; i-- {
+		if strings.HasPrefix(cmd, srv.stubs[i].prefix) {
+			reply, stubbed = srv.stubs[i].reply, true
+			break
+		}
+	}
+	srv.mu.Unlock()
+
+	line, hdrs, _ := strings.Cut(cmd, "\n")
+	switch {
+	case strings.HasPrefix(line, "api "):
+		return sc.write(fmt.Sprintf("Content-Type: api/response\nContent-Length: %d\n\n%s", len(reply), reply))
+	case strings.HasPrefix(line, "bgapi "):
+		jobUUID := headerValue(hdrs, "Job-UUID")
+		if jobUUID == "" {
+			jobUUID = newUUID()
+		}
+		if err := sc.write(fmt.Sprintf("Content-Type: command/reply\nReply-Text: +OK Job-UUID: %s\nJob-UUID: %s\n\n",
+			jobUUID, jobUUID)); err != nil {
+			return err
+		}
+		if !stubbed {
+			reply = "+OK\n"
+		}
+		event := fmt.Sprintf("Event-Name: BACKGROUND_JOB\nJob-UUID: %s\nJob-Command: %s\nContent-Length: %d\n\n%s",
+			jobUUID, urlEncode(strings.TrimPrefix(line, "bgapi ")), len(reply), reply)
+		return sc.write(fmt.Sprintf("Content-Length: %d\nContent-Type: text/event-plain\n\n%s", len(event), event))
+	default:
+		return sc.write(fmt.Sprintf("Content-Type: command/reply\nReply-Text: %s\n\n", reply))
+	}
+}
+
+// readCommand reads one command, its headers included, skipping the body if any.
+func readCommand(rdr *bufio.Reader) (string, error) {
+	var lines []string
+	for {
+		line, err := rdr.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			lines = append(lines, line)
+			continue
+		}
+		if len(lines) != 0 {
+			break
+		}
+	}
+	cmd := strings.Join(lines, "\n")
+	if cl, err := strconv.Atoi(headerValue(cmd, "Content-Length")); err == nil && cl > 0 {
+		if _, err = rdr.Discard(cl); err != nil {
+			return "", err
+		}
+	}
+	return cmd, nil
+}
+
+// headerValue returns the value of the header hdr within hdrs.
+func headerValue(hdrs, hdr string) string {
+	for _, line := range strings.Split(hdrs, "\n") {
+		if name, val, has := strings.Cut(line, ":"); has && strings.EqualFold(strings.TrimSpace(name), hdr) {
+			return strings.TrimSpace(val)
+		}
+	}
+	return ""
+}
+
+// urlEncode encodes the header value the way FreeSWITCH does.
+func urlEncode(hdrVal string) string {
+	return strings.ReplaceAll(url.QueryEscape(hdrVal), "+", "%20")
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	io.ReadFull(rand.Reader, b)
+	b[6] = (b[6] & 0x0F) | 0x40
+	b[8] = (b[8] &^ 0x40) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,119 @@
+/*
+fsocktest_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides a mock FreeSWITCH event socket for testing.
+*/
+package fsocktest_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock"
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestServerCommands(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.Stub("api status", "UP 0 years, 0 days\n")
+	srv.Stub("bgapi originate", "+OK 1234\n")
+	srv.Expect("event plain CHANNEL_ANSWER", "api status")
+	fs, err := fsock.NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		fsock.WithEventHandlers(map[string][]func(string, int){"CHANNEL_ANSWER": nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if rply, err := fs.SendApiCmd("status"); err != nil {
+		t.Error(err)
+	} else if exp := "UP 0 years, 0 days\n"; rply != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, rply)
+	}
+	out, err := fs.SendBgapiCmd("originate user/1001 &park")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case rply := <-out:
+		if exp := "+OK 1234\n"; rply != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, rply)
+		}
+	case <-time.After(time.Second):
+		t.Error("no bgapi result")
+	}
+	if err := srv.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServerSendEvent(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	events := make(chan string, 1)
+	fs, err := fsock.NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		fsock.WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {func(event string, _ int) { events <- event }},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if err := srv.SendEvent(map[string]string{
+		"Event-Name":            "CHANNEL_ANSWER",
+		"Caller-Caller-ID-Name": "John Doe",
+	}, ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if name := fsock.NewEvent(event).GetHeader("Caller-Caller-ID-Name"); name != "John Doe" {
+			t.Errorf("unexpected event: %q", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("event not dispatched")
+	}
+}
+
+func TestServerWaitCommand(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := fsock.NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	go fs.SendCmd("sendmsg 1234\ncall-command: hangup\n\n")
+	cmd, err := srv.WaitCommand("sendmsg", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "sendmsg 1234\ncall-command: hangup"; cmd != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, cmd)
+	}
+	if _, err := srv.WaitCommand("api", 10*time.Millisecond); err == nil {
+		t.Error("expected timeout")
+	}
+}
+
+func TestServerAuth(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.SetPassword("secret")
+	if _, err := fsock.NewFSockWithOptions(srv.Addr(), "ClueCon"); err == nil ||
+		!strings.Contains(err.Error(), "-ERR invalid") {
+		t.Errorf("expected auth failure, received: %v", err)
+	}
+	fs, err := fsock.NewFSockWithOptions(srv.Addr(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.Disconnect()
+	if _, err := fsock.NewFSockWithOptions(srv.Addr(), "ClueCon",
+		fsock.WithUserAuth("1000", "example.com")); err == nil || !strings.Contains(err.Error(), "-ERR invalid") {
+		t.Errorf("expected userauth failure, received: %v", err)
+	}
+	if fs, err = fsock.NewFSockWithOptions(srv.Addr(), "secret",
+		fsock.WithUserAuth("1000", "example.com")); err != nil {
+		t.Fatal(err)
+	}
+	fs.Disconnect()
+}
@@ -0,0 +1,98 @@
+/*
+ivr.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// digitsVar is the channel variable play_and_get_digits stores the digits into.
+const digitsVar = "fsock_collected_digits"
+
+// PlaybackResult is the outcome of Playback.
+type PlaybackResult struct {
+	Response   string // e.g. FILE PLAYED or FILE NOT FOUND
+	Terminator string // DTMF digit which interrupted the playback, empty if none
+}
+
+// Playback plays file to the channel with the given uuid, returning once done or
+// interrupted. As for ExecuteApp, the CHANNEL_EXECUTE_COMPLETE events must be
+// subscribed to.
+func (fs *FSock) Playback(ctx context.Context, uuid, file string) (PlaybackResult, error) {
+	res, err := fs.ExecuteApp(ctx, uuid, "playback", file)
+	if err != nil {
+		return PlaybackResult{}, err
+	}
+	return PlaybackResult{
+		Response:   res.Response,
+		Terminator: res.Event.GetVariable("playback_terminator_used"),
+	}, nil
+}
+
+// DigitsPrompt describes the prompt of PlayAndGetDigits.
+type DigitsPrompt struct {
+	MinDigits    int
+	MaxDigits    int
+	Tries        int           // 1 if 0
+	Timeout      time.Duration // waiting for the first digit after the prompt, 5s if 0
+	Terminators  string        // digits ending the input, e.g. #, none if empty
+	File         string        // prompt played
+	InvalidFile  string        // played after invalid input, silence if empty
+	Regexp       string        // the digits must match, [0-9]+ if empty
+	DigitTimeout time.Duration // waiting between the digits, Timeout if 0
+}
+
+// PlayAndGetDigits plays the prompt to the channel with the given uuid and collects the
+// digits through play_and_get_digits, returning them, empty if no valid input was
+// received after all the tries. As for ExecuteApp, the CHANNEL_EXECUTE_COMPLETE events
+// must be subscribed to.
+func (fs *FSock) PlayAndGetDigits(ctx context.Context, uuid string, prompt DigitsPrompt) (string, error) {
+	args, err := prompt.args()
+	if err != nil {
+		return "", err
+	}
+	res, err := fs.ExecuteApp(ctx, uuid, "play_and_get_digits", args)
+	if err != nil {
+		return "", err
+	}
+	return res.Event.GetVariable(digitsVar), nil
+}
+
+// args composes the play_and_get_digits arguments out of the prompt.
+func (prompt DigitsPrompt) args() (string, error) {
+	tries := prompt.Tries
+	if tries == 0 {
+		tries = 1
+	}
+	timeout := prompt.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	digitTimeout := prompt.DigitTimeout
+	if digitTimeout == 0 {
+		digitTimeout = timeout
+	}
+	terminators := prompt.Terminators
+	if terminators == "" {
+		terminators = "none"
+	}
+	invalidFile := prompt.InvalidFile
+	if invalidFile == "" {
+		invalidFile = "silence_stream://250"
+	}
+	regexp := prompt.Regexp
+	if regexp == "" {
+		regexp = "[0-9]+"
+	}
+	return ApiCmd(strconv.Itoa(prompt.MinDigits), strconv.Itoa(prompt.MaxDigits), strconv.Itoa(tries),
+		strconv.FormatInt(timeout.Milliseconds(), 10), terminators, prompt.File, invalidFile,
+		digitsVar, regexp, strconv.FormatInt(digitTimeout.Milliseconds(), 10))
+}
@@ -0,0 +1,82 @@
+/*
+ivr_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+// completeExecution answers the application app executed on uuid through sendmsg with a
+// CHANNEL_EXECUTE_COMPLETE carrying hdrs, returning its execute-app-arg.
+func completeExecution(t *testing.T, srv *fsocktest.Server, uuid, app string, hdrs map[string]string) <-chan string {
+	appArg := make(chan string, 1)
+	go func() {
+		defer close(appArg)
+		cmd, err := srv.WaitCommand("sendmsg "+uuid+"\ncall-command: execute\nexecute-app-name: "+app, time.Second)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		hdrs["Event-Name"] = "CHANNEL_EXECUTE_COMPLETE"
+		hdrs["Application-UUID"] = HeaderValue(cmd, "Event-UUID")
+		if err = srv.SendEvent(hdrs, ""); err != nil {
+			t.Error(err)
+		}
+		appArg <- HeaderValue(cmd, "execute-app-arg")
+	}()
+	return appArg
+}
+
+func TestFSockPlayback(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithEventHandlers(map[string][]func(string, int){"CHANNEL_EXECUTE_COMPLETE": nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	appArg := completeExecution(t, srv, "3f1c7b2e", "playback", map[string]string{
+		"Application-Response":              "FILE PLAYED",
+		"variable_playback_terminator_used": "#",
+	})
+	exp := PlaybackResult{Response: "FILE PLAYED", Terminator: "#"}
+	if res, err := fs.Playback(ctx, "3f1c7b2e", "ivr/welcome.wav"); err != nil {
+		t.Error(err)
+	} else if res != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, res)
+	}
+	if arg := <-appArg; arg != "ivr/welcome.wav" {
+		t.Errorf("unexpected playback argument: %q", arg)
+	}
+
+	appArg = completeExecution(t, srv, "3f1c7b2e", "play_and_get_digits", map[string]string{
+		"Application-Response":            "_none_",
+		"variable_fsock_collected_digits": "1234",
+	})
+	if digits, err := fs.PlayAndGetDigits(ctx, "3f1c7b2e", DigitsPrompt{
+		MinDigits:   4,
+		MaxDigits:   4,
+		Tries:       3,
+		Terminators: "#",
+		File:        "ivr/enter pin.wav",
+	}); err != nil {
+		t.Error(err)
+	} else if digits != "1234" {
+		t.Errorf("unexpected digits: %q", digits)
+	}
+	if exp, arg := "4 4 3 5000 # 'ivr/enter pin.wav' silence_stream://250 fsock_collected_digits [0-9]+ 5000",
+		<-appArg; arg != exp {
+		t.Errorf("\nExpected: <%s>, \nReceived: <%s>", exp, arg)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+lazyevent.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"strings"
+	"time"
+)
+
+// LazyEvent is a view of a plain text event scanning for the headers on demand, unlike
+// NewEvent and EventToMap which parse and URL decode all of them. Cheaper for the handlers
+// reading only a few headers. The headers found are cached. Not safe for concurrent use.
+type LazyEvent struct {
+	raw   string
+	po    parseOptions
+	hdrs  string            // headers part of raw
+	body  string            // body of the event, if any
+	cache map[string]string // URL decoded headers looked up so far, missing ones included
+}
+
+// NewLazyEvent returns the view of the plain text event, without parsing it.
+func NewLazyEvent(raw string, opts ...ParseOption) *LazyEvent {
+	hdrs, body, _ := strings.Cut(raw, "\n\n")
+	return &LazyEvent{
+		raw:  raw,
+		po:   newParseOptions(opts),
+		hdrs: hdrs,
+		body: body,
+	}
+}
+
+// LazyEventHandler adapts a handler of lazy events to the plain handlers signature,
+// so it can be registered within the event handlers.
+func LazyEventHandler(handler func(*LazyEvent, int)) func(string, int) {
+	return func(event string, connIdx int) {
+		handler(NewLazyEvent(event), connIdx)
+	}
+}
+
+// GetHeader returns the value of the header, URL decoded unless RawValues was given,
+// empty if missing.
+func (ev *LazyEvent) GetHeader(name string) string {
+	if val, cached := ev.cache[name]; cached {
+		return val
+	}
+	val, _ := lookupHeader(ev.hdrs, name)
+	val = ev.po.decode(val)
+	if ev.cache == nil {
+		ev.cache = make(map[string]string)
+	}
+	ev.cache[name] = val
+	return val
+}
+
+// GetVariable returns the value of the channel variable, empty if missing.
+func (ev *LazyEvent) GetVariable(name string) string {
+	return ev.GetHeader("variable_" + name)
+}
+
+// Name returns the Event-Name header.
+func (ev *LazyEvent) Name() string {
+	return ev.GetHeader("Event-Name")
+}
+
+// Subclass returns the Event-Subclass header, set on CUSTOM events.
+func (ev *LazyEvent) Subclass() string {
+	return ev.GetHeader("Event-Subclass")
+}
+
+// UUID returns the Unique-ID of the channel the event refers to.
+func (ev *LazyEvent) UUID() string {
+	return ev.GetHeader("Unique-ID")
+}
+
+// Timestamp returns the time the event was fired at, based on the Event-Date-Timestamp
+// header. The zero time is returned if the header is missing or invalid.
+func (ev *LazyEvent) Timestamp() time.Time {
+	return parseEventTimestamp(ev.GetHeader("Event-Date-Timestamp"))
+}
+
+// Body returns the body of the event, if any.
+func (ev *LazyEvent) Body() string {
+	return ev.body
+}
+
+// Raw returns the event as received from FreeSWITCH.
+func (ev *LazyEvent) Raw() string {
+	return ev.raw
+}
+
+// Event parses all the headers, for the handlers needing them after all.
+func (ev *LazyEvent) Event() Event {
+	return NewEvent(ev.raw, func(po *parseOptions) { *po = ev.po })
+}
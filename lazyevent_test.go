@@ -0,0 +1,69 @@
+/*
+lazyevent_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLazyEvent(t *testing.T) {
+	raw := "Event-Name: CHANNEL_ANSWER\nOriginal-Event-Name: CHANNEL_PARK\n" +
+		"Unique-ID: 4967ceb1-c6f9-4af9-9855-df323d6763ad\nEvent-Date-Timestamp: 1703257952506074\n" +
+		"variable_sip_from_user: 1001\nCaller-Channel-Name: sofia/internal/1001%40192.168.56.120%3A5081\n\nsome body"
+	ev := NewLazyEvent(raw)
+	exp := NewEvent(raw)
+	for _, hdr := range []string{"Event-Name", "Original-Event-Name", "Unique-ID", "Caller-Channel-Name", "Missing"} {
+		if rcv := ev.GetHeader(hdr); rcv != exp.GetHeader(hdr) {
+			t.Errorf("%s\nExpected: <%+v>, \nReceived: <%+v>", hdr, exp.GetHeader(hdr), rcv)
+		}
+	}
+	if ev.Name() != "CHANNEL_ANSWER" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "CHANNEL_ANSWER", ev.Name())
+	}
+	if ev.GetVariable("sip_from_user") != "1001" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "1001", ev.GetVariable("sip_from_user"))
+	}
+	if !ev.Timestamp().Equal(exp.Timestamp()) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp.Timestamp(), ev.Timestamp())
+	}
+	if ev.Body() != exp.Body || ev.Raw() != raw {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp.Body, ev.Body())
+	}
+	if _, cached := ev.cache["Missing"]; !cached {
+		t.Error("missing header not cached")
+	}
+}
+
+// benchEvent is a CHANNEL_ANSWER event with channel variables, sized like the real ones.
+var benchEvent = func() string {
+	var sb strings.Builder
+	sb.WriteString("Event-Name: CHANNEL_ANSWER\nCore-UUID: 44d90754-93de-4dd7-807a-9ad31e45d4de\n" +
+		"Unique-ID: 4967ceb1-c6f9-4af9-9855-df323d6763ad\nCaller-Caller-ID-Number: 1001\n")
+	for i := range 150 {
+		fmt.Fprintf(&sb, "variable_header_%d: value%%20%d\n", i, i)
+	}
+	sb.WriteString("variable_sip_from_user: 1001\n\n")
+	return sb.String()
+}()
+
+func BenchmarkNewEventFewHeaders(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ev := NewEvent(benchEvent)
+		_, _, _ = ev.Name(), ev.UUID(), ev.GetVariable("sip_from_user")
+	}
+}
+
+func BenchmarkLazyEventFewHeaders(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ev := NewLazyEvent(benchEvent)
+		_, _, _ = ev.Name(), ev.UUID(), ev.GetVariable("sip_from_user")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+logger.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// Levels the syslog severities without a slog equivalent are logged at.
+const (
+	LevelNotice    = slog.LevelInfo + 2
+	LevelCritical  = slog.LevelError + 4
+	LevelAlert     = slog.LevelError + 8
+	LevelEmergency = slog.LevelError + 12
+)
+
+// SlogLogger adapts a *slog.Logger to the Logger interface. Being a FieldLogger, the
+// package attaches structured fields to its records, like conn_idx, addr or event_name.
+type SlogLogger struct {
+	lgr *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to lgr, slog.Default() if nil.
+func NewSlogLogger(lgr *slog.Logger) *SlogLogger {
+	if lgr == nil {
+		lgr = slog.Default()
+	}
+	return &SlogLogger{lgr: lgr}
+}
+
+// With returns a logger attaching the args fields, alternating keys and values, to its records.
+func (sl *SlogLogger) With(args ...any) Logger {
+	return &SlogLogger{lgr: sl.lgr.With(args...)}
+}
+
+func (sl *SlogLogger) log(level slog.Level, msg string) error {
+	sl.lgr.Log(context.Background(), level, msg)
+	return nil
+}
+
+func (sl *SlogLogger) Alert(msg string) error   { return sl.log(LevelAlert, msg) }
+func (sl *SlogLogger) Close() error             { return nil }
+func (sl *SlogLogger) Crit(msg string) error    { return sl.log(LevelCritical, msg) }
+func (sl *SlogLogger) Debug(msg string) error   { return sl.log(slog.LevelDebug, msg) }
+func (sl *SlogLogger) Emerg(msg string) error   { return sl.log(LevelEmergency, msg) }
+func (sl *SlogLogger) Err(msg string) error     { return sl.log(slog.LevelError, msg) }
+func (sl *SlogLogger) Info(msg string) error    { return sl.log(slog.LevelInfo, msg) }
+func (sl *SlogLogger) Notice(msg string) error  { return sl.log(LevelNotice, msg) }
+func (sl *SlogLogger) Warning(msg string) error { return sl.log(slog.LevelWarn, msg) }
+
+// ZapSugaredLogger is the part of *zap.SugaredLogger used by ZapLogger, declared here
+// so the package does not depend on zap.
+type ZapSugaredLogger interface {
+	Debugw(msg string, keysAndValues ...any)
+	Infow(msg string, keysAndValues ...any)
+	Warnw(msg string, keysAndValues ...any)
+	Errorw(msg string, keysAndValues ...any)
+	Sync() error
+}
+
+// ZapLogger adapts a *zap.SugaredLogger to the Logger interface, the fields attached
+// through With being passed along with every message. Notice is logged at info level,
+// Crit, Alert and Emerg at error level, zap's higher levels panicking or exiting.
+type ZapLogger struct {
+	lgr    ZapSugaredLogger
+	fields []any
+}
+
+// NewZapLogger returns a Logger writing to lgr.
+func NewZapLogger(lgr ZapSugaredLogger) *ZapLogger {
+	return &ZapLogger{lgr: lgr}
+}
+
+// With returns a logger attaching the args fields, alternating keys and values, to its messages.
+func (zl *ZapLogger) With(args ...any) Logger {
+	return &ZapLogger{lgr: zl.lgr, fields: append(slices.Clip(zl.fields), args...)}
+}
+
+func (zl *ZapLogger) Alert(msg string) error   { zl.lgr.Errorw(msg, zl.fields...); return nil }
+func (zl *ZapLogger) Close() error             { return zl.lgr.Sync() }
+func (zl *ZapLogger) Crit(msg string) error    { zl.lgr.Errorw(msg, zl.fields...); return nil }
+func (zl *ZapLogger) Debug(msg string) error   { zl.lgr.Debugw(msg, zl.fields...); return nil }
+func (zl *ZapLogger) Emerg(msg string) error   { zl.lgr.Errorw(msg, zl.fields...); return nil }
+func (zl *ZapLogger) Err(msg string) error     { zl.lgr.Errorw(msg, zl.fields...); return nil }
+func (zl *ZapLogger) Info(msg string) error    { zl.lgr.Infow(msg, zl.fields...); return nil }
+func (zl *ZapLogger) Notice(msg string) error  { zl.lgr.Infow(msg, zl.fields...); return nil }
+func (zl *ZapLogger) Warning(msg string) error { zl.lgr.Warnw(msg, zl.fields...); return nil }
+
+// LogrusFieldLogger is the part of logrus.FieldLogger used by LogrusLogger, declared here
+// so the package does not depend on logrus. Satisfied by *logrus.Logger and *logrus.Entry.
+type LogrusFieldLogger interface {
+	Debug(args ...any)
+	Info(args ...any)
+	Warn(args ...any)
+	Error(args ...any)
+}
+
+// LogrusLogger adapts a logrus.FieldLogger to the Logger interface. Notice is logged at
+// info level, Crit, Alert and Emerg at error level, logrus' higher levels panicking or
+// exiting. Pass an *logrus.Entry built with WithFields to attach fields of your own, the
+// ones of the package being written within the messages.
+type LogrusLogger struct {
+	lgr LogrusFieldLogger
+}
+
+// NewLogrusLogger returns a Logger writing to lgr.
+func NewLogrusLogger(lgr LogrusFieldLogger) *LogrusLogger {
+	return &LogrusLogger{lgr: lgr}
+}
+
+func (ll *LogrusLogger) Alert(msg string) error   { ll.lgr.Error(msg); return nil }
+func (ll *LogrusLogger) Close() error             { return nil }
+func (ll *LogrusLogger) Crit(msg string) error    { ll.lgr.Error(msg); return nil }
+func (ll *LogrusLogger) Debug(msg string) error   { ll.lgr.Debug(msg); return nil }
+func (ll *LogrusLogger) Emerg(msg string) error   { ll.lgr.Error(msg); return nil }
+func (ll *LogrusLogger) Err(msg string) error     { ll.lgr.Error(msg); return nil }
+func (ll *LogrusLogger) Info(msg string) error    { ll.lgr.Info(msg); return nil }
+func (ll *LogrusLogger) Notice(msg string) error  { ll.lgr.Info(msg); return nil }
+func (ll *LogrusLogger) Warning(msg string) error { ll.lgr.Warn(msg); return nil }
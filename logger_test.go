@@ -0,0 +1,190 @@
+/*
+logger_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlogLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	lgr := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	for _, tc := range []struct {
+		log   func(string) error
+		level string
+	}{
+		{lgr.Debug, "level=DEBUG"},
+		{lgr.Info, "level=INFO"},
+		{lgr.Notice, "level=INFO+2"},
+		{lgr.Warning, "level=WARN"},
+		{lgr.Err, "level=ERROR"},
+		{lgr.Crit, "level=ERROR+4"},
+		{lgr.Alert, "level=ERROR+8"},
+		{lgr.Emerg, "level=ERROR+12"},
+	} {
+		buf.Reset()
+		if err := tc.log("msg"); err != nil {
+			t.Error(err)
+		}
+		if !strings.Contains(buf.String(), tc.level) {
+			t.Errorf("expected %q within: %s", tc.level, buf.String())
+		}
+	}
+	buf.Reset()
+	ConnInfo{Idx: 5}.scope(lgr).Info("scoped")
+	if !strings.Contains(buf.String(), "conn_idx=5") || strings.Contains(buf.String(), "[conn_idx=5]") {
+		t.Errorf("expected a conn_idx field, received: %s", buf.String())
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of the logger.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
+
+func TestSlogLoggerFields(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		event := "Event-Name: CHANNEL_ANSWER\n\n"
+		if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(event), event); err != nil {
+			t.Error(err)
+			return
+		}
+		io.Copy(io.Discard, c)
+	})
+	var buf syncBuffer
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithConnIdx(4),
+		WithSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "No dispatcher") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(record["msg"].(string), "No dispatcher") {
+			continue
+		}
+		if record["conn_idx"] != float64(4) || record["event_name"] != "CHANNEL_ANSWER" {
+			t.Errorf("unexpected fields of the record: %v", record)
+		}
+		return
+	}
+	t.Errorf("no dispatcher warning logged: %s", buf.String())
+}
+
+// zapMock records the messages logged through the ZapSugaredLogger methods.
+type zapMock struct {
+	logged []string
+}
+
+func (zm *zapMock) logw(level, msg string, kvs ...any) {
+	zm.logged = append(zm.logged, fmt.Sprint(level, " ", msg, " ", kvs))
+}
+
+func (zm *zapMock) Debugw(msg string, kvs ...any) { zm.logw("debug", msg, kvs...) }
+func (zm *zapMock) Infow(msg string, kvs ...any)  { zm.logw("info", msg, kvs...) }
+func (zm *zapMock) Warnw(msg string, kvs ...any)  { zm.logw("warn", msg, kvs...) }
+func (zm *zapMock) Errorw(msg string, kvs ...any) { zm.logw("error", msg, kvs...) }
+func (zm *zapMock) Sync() error                   { return nil }
+
+func TestZapLogger(t *testing.T) {
+	zm := new(zapMock)
+	lgr := NewZapLogger(zm)
+	lgr.Debug("dbg")
+	lgr.Notice("ntc")
+	lgr.Warning("wrn")
+	scoped := ConnInfo{Idx: 3}.scope(lgr)
+	scoped.Crit("crt")
+	scoped.With("addr", "127.0.0.1:8021").Err("err")
+	lgr.Info("inf")
+	exp := []string{
+		"debug dbg []",
+		"info ntc []",
+		"warn wrn []",
+		"error crt [conn_idx 3]",
+		"error err [conn_idx 3 addr 127.0.0.1:8021]",
+		"info inf []",
+	}
+	if !reflect.DeepEqual(zm.logged, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, zm.logged)
+	}
+}
+
+// logrusMock records the messages logged through the LogrusFieldLogger methods.
+type logrusMock struct {
+	logged []string
+}
+
+func (lm *logrusMock) Debug(args ...any) { lm.logged = append(lm.logged, "debug "+fmt.Sprint(args...)) }
+func (lm *logrusMock) Info(args ...any)  { lm.logged = append(lm.logged, "info "+fmt.Sprint(args...)) }
+func (lm *logrusMock) Warn(args ...any)  { lm.logged = append(lm.logged, "warn "+fmt.Sprint(args...)) }
+func (lm *logrusMock) Error(args ...any) { lm.logged = append(lm.logged, "error "+fmt.Sprint(args...)) }
+
+func TestLogrusLogger(t *testing.T) {
+	lm := new(logrusMock)
+	lgr := NewLogrusLogger(lm)
+	lgr.Debug("dbg")
+	lgr.Notice("ntc")
+	lgr.Warning("wrn")
+	ConnInfo{Idx: 3}.scope(lgr).Emerg("emg")
+	exp := []string{
+		"debug dbg",
+		"info ntc",
+		"warn wrn",
+		"error [conn_idx=3] emg",
+	}
+	if !reflect.DeepEqual(lm.logged, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, lm.logged)
+	}
+}
+
+func TestConnInfoLogger(t *testing.T) {
+	lM := new(loggerMock)
+	info := ConnInfo{Idx: 2, ID: "fs1"}
+	info.logger(lM).Err("plain")
+	if exp := "[conn_id=fs1] plain"; lM.msg != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, lM.msg)
+	}
+	info.scope(lM).Err("scoped")
+	if exp := "[conn_id=fs1 conn_idx=2] scoped"; lM.msg != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, lM.msg)
+	}
+	ConnInfo{Idx: 2}.logger(lM).Err("no id")
+	if exp := "no id"; lM.msg != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, lM.msg)
+	}
+}
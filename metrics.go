@@ -0,0 +1,157 @@
+/*
+metrics.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics collects the activity of the FSocks and pools configured with WithMetrics
+// and WithPoolMetrics. It is pulled with Snapshot or, in the Prometheus text format,
+// with WritePrometheus. Safe for concurrent use, the zero value is ready to use.
+type Metrics struct {
+	mu             sync.Mutex
+	eventsReceived map[string]uint64
+	pools          []*FSockPool
+
+	commandsSent     atomic.Uint64
+	replies          atomic.Uint64
+	replyLatencySum  atomic.Int64 // nanoseconds
+	replyLatencyMax  atomic.Int64 // nanoseconds
+	reconnects       atomic.Uint64
+	bgapiOutstanding atomic.Int64
+}
+
+// MetricsSnapshot is the state of the Metrics at a given moment.
+type MetricsSnapshot struct {
+	EventsReceived   map[string]uint64 // indexed by event name, subclass included for CUSTOM events
+	CommandsSent     uint64            // commands sent awaiting a reply, bgapi included
+	Replies          uint64            // replies received to the commands sent
+	ReplyLatencySum  time.Duration     // total time spent waiting for the replies
+	ReplyLatencyMax  time.Duration     // longest wait for a reply
+	Reconnects       uint64            // connections re-established after a loss
+	BgapiOutstanding int               // bgapi jobs waiting for their result
+	Pool             PoolStats         // summed over the pools
+}
+
+// NewMetrics returns an empty Metrics.
+func NewMetrics() *Metrics {
+	return new(Metrics)
+}
+
+// Snapshot returns the current metrics.
+func (m *Metrics) Snapshot() (snap MetricsSnapshot) {
+	m.mu.Lock()
+	snap.EventsReceived = make(map[string]uint64, len(m.eventsReceived))
+	for evName, count := range m.eventsReceived {
+		snap.EventsReceived[evName] = count
+	}
+	pools := slices.Clone(m.pools)
+	m.mu.Unlock()
+	snap.CommandsSent = m.commandsSent.Load()
+	snap.Replies = m.replies.Load()
+	snap.ReplyLatencySum = time.Duration(m.replyLatencySum.Load())
+	snap.ReplyLatencyMax = time.Duration(m.replyLatencyMax.Load())
+	snap.Reconnects = m.reconnects.Load()
+	snap.BgapiOutstanding = int(m.bgapiOutstanding.Load())
+	for _, pool := range pools {
+		stats := pool.Stats()
+		snap.Pool.Created += stats.Created
+		snap.Pool.Idle += stats.Idle
+		snap.Pool.Borrowed += stats.Borrowed
+		snap.Pool.WaitCount += stats.WaitCount
+		snap.Pool.WaitDuration += stats.WaitDuration
+	}
+	return
+}
+
+// WritePrometheus writes the current metrics to w in the Prometheus text exposition format,
+// so they can be served on a scrape endpoint without depending on the Prometheus client.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	snap := m.Snapshot()
+	var sb strings.Builder
+	sb.WriteString("# HELP fsock_events_received_total Events received from FreeSWITCH.\n")
+	sb.WriteString("# TYPE fsock_events_received_total counter\n")
+	evNames := getMapKeys(snap.EventsReceived)
+	slices.Sort(evNames)
+	for _, evName := range evNames {
+		fmt.Fprintf(&sb, "fsock_events_received_total{event=\"%s\"} %d\n",
+			promLabelEscaper.Replace(evName), snap.EventsReceived[evName])
+	}
+	for _, metric := range []struct {
+		name, help, typ string
+		value           any
+	}{
+		{"fsock_commands_sent_total", "Commands sent to FreeSWITCH.", "counter", snap.CommandsSent},
+		{"fsock_reply_latency_seconds_sum", "Time spent waiting for the command replies.", "counter", snap.ReplyLatencySum.Seconds()},
+		{"fsock_reply_latency_seconds_count", "Command replies received.", "counter", snap.Replies},
+		{"fsock_reply_latency_seconds_max", "Longest wait for a command reply.", "gauge", snap.ReplyLatencyMax.Seconds()},
+		{"fsock_reconnects_total", "Connections re-established after a loss.", "counter", snap.Reconnects},
+		{"fsock_bgapi_jobs_outstanding", "Bgapi jobs waiting for their result.", "gauge", snap.BgapiOutstanding},
+		{"fsock_pool_created_total", "Connections established by the pools.", "counter", snap.Pool.Created},
+		{"fsock_pool_idle", "Connections idle in the pools.", "gauge", snap.Pool.Idle},
+		{"fsock_pool_borrowed", "Connections popped out of the pools.", "gauge", snap.Pool.Borrowed},
+		{"fsock_pool_waits_total", "Pops which waited for a connection.", "counter", snap.Pool.WaitCount},
+		{"fsock_pool_wait_seconds_total", "Time spent by the pops waiting.", "counter", snap.Pool.WaitDuration.Seconds()},
+	} {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n%s %v\n",
+			metric.name, metric.help, metric.name, metric.typ, metric.name, metric.value)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// eventReceived counts an event named evName.
+func (m *Metrics) eventReceived(evName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.eventsReceived == nil {
+		m.eventsReceived = make(map[string]uint64)
+	}
+	m.eventsReceived[evName]++
+}
+
+// commandSent counts a command sent.
+func (m *Metrics) commandSent() {
+	m.commandsSent.Add(1)
+}
+
+// replyReceived records the latency of a command reply.
+func (m *Metrics) replyReceived(latency time.Duration) {
+	m.replies.Add(1)
+	m.replyLatencySum.Add(int64(latency))
+	for {
+		maxLatency := m.replyLatencyMax.Load()
+		if int64(latency) <= maxLatency || m.replyLatencyMax.CompareAndSwap(maxLatency, int64(latency)) {
+			return
+		}
+	}
+}
+
+// addPool includes the usage of pool in the metrics.
+func (m *Metrics) addPool(pool *FSockPool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools = append(m.pools, pool)
+}
+
+// removePool excludes the usage of pool from the metrics, once closed.
+func (m *Metrics) removePool(pool *FSockPool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools = slices.DeleteFunc(m.pools, func(p *FSockPool) bool { return p == pool })
+}
@@ -0,0 +1,102 @@
+/*
+metrics_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsFSock(t *testing.T) {
+	cmds := make(chan string, 10)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		event := "Event-Name: CUSTOM\nEvent-Subclass: sofia%3A%3Aregister\n\n"
+		if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+			len(event), event); err != nil {
+			t.Error(err)
+			return
+		}
+		replyCommands(t, c, cmds)
+	})
+	m := NewMetrics()
+	received := make(chan struct{})
+	fs, err := NewFSockWithOptions(addr, "ClueCon",
+		WithEventHandlers(map[string][]func(string, int){
+			"CUSTOM sofia::register": {func(string, int) { close(received) }},
+		}),
+		WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+	if _, err := fs.SendCmd("api status\n\n"); err != nil {
+		t.Fatal(err)
+	}
+	snap := m.Snapshot()
+	if snap.EventsReceived["CUSTOM sofia::register"] != 1 {
+		t.Errorf("unexpected events received: %+v", snap.EventsReceived)
+	}
+	if snap.CommandsSent != 1 || snap.Replies != 1 || snap.ReplyLatencySum <= 0 ||
+		snap.ReplyLatencyMax != snap.ReplyLatencySum {
+		t.Errorf("unexpected command metrics: %+v", snap)
+	}
+}
+
+func TestMetricsWritePrometheus(t *testing.T) {
+	m := NewMetrics()
+	m.eventReceived("CHANNEL_ANSWER")
+	m.eventReceived(`CUSTOM my"event`)
+	m.commandSent()
+	m.replyReceived(time.Second)
+	m.replyReceived(500 * time.Millisecond)
+	m.bgapiOutstanding.Add(2)
+	m.addPool(&FSockPool{fSocks: make(chan *FSock, 1)})
+	var sb strings.Builder
+	if err := m.WritePrometheus(&sb); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		"# TYPE fsock_events_received_total counter\n",
+		"fsock_events_received_total{event=\"CHANNEL_ANSWER\"} 1\n",
+		"fsock_events_received_total{event=\"CUSTOM my\\\"event\"} 1\n",
+		"fsock_commands_sent_total 1\n",
+		"fsock_reply_latency_seconds_sum 1.5\n",
+		"fsock_reply_latency_seconds_count 2\n",
+		"fsock_reply_latency_seconds_max 1\n",
+		"fsock_bgapi_jobs_outstanding 2\n",
+		"fsock_pool_idle 0\n",
+	} {
+		if !strings.Contains(sb.String(), exp) {
+			t.Errorf("expected %q within: %s", exp, sb.String())
+		}
+	}
+}
+
+func TestMetricsPoolClose(t *testing.T) {
+	m := NewMetrics()
+	pool := NewFSockPool(1, "127.0.0.1:1", "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil, WithPoolMetrics(m))
+	NewFSockPool(1, "127.0.0.1:1", "ClueCon", 0, time.Second, 0, time.Second, FibDuration,
+		nil, nil, nil, 0, false, nil, WithPoolMetrics(nil)) // no metrics, nothing to panic on
+	if len(m.pools) != 1 {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", 1, len(m.pools))
+	}
+	if err := pool.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(m.pools) != 0 {
+		t.Errorf("closed pool still in the metrics: %+v", m.pools)
+	}
+}
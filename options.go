@@ -0,0 +1,348 @@
+/*
+options.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Option configures a FSock created with NewFSockWithOptions.
+type Option func(*FSock)
+
+// WithReconnects sets the number of reconnect attempts, -1 for infinite. Defaults to 0.
+func WithReconnects(reconnects int) Option {
+	return func(fs *FSock) { fs.reconnects = reconnects }
+}
+
+// WithMaxReconnectInterval caps the delay between two reconnect attempts.
+func WithMaxReconnectInterval(maxReconnectInterval time.Duration) Option {
+	return func(fs *FSock) { fs.maxReconnectInterval = maxReconnectInterval }
+}
+
+// WithReconnectBudget bounds the total time spent reconnecting, giving up once the next
+// attempt would start later than budget after the first one, even with reconnects left or
+// infinite. The final error wraps ErrReconnectBudget. Defaults to 0, no bound.
+func WithReconnectBudget(budget time.Duration) Option {
+	return func(fs *FSock) { fs.reconnectBudget = budget }
+}
+
+// WithReplyTimeout sets how long to wait for the reply of a command, 0 for no timeout.
+func WithReplyTimeout(replyTimeout time.Duration) Option {
+	return func(fs *FSock) { fs.replyTimeout = replyTimeout }
+}
+
+// WithStandbyAddrs sets the addresses of standby FreeSWITCH nodes, tried in order
+// once the primary address fails.
+func WithStandbyAddrs(addrs ...string) Option {
+	return func(fs *FSock) { fs.standbyAddrs = addrs }
+}
+
+// WithFailback prefers the primary address: reconnects start with it and, while on a
+// standby node, the primary is probed every interval, moving back once it recovers.
+func WithFailback(interval time.Duration) Option {
+	return func(fs *FSock) { fs.failbackInterval = interval }
+}
+
+// WithDialTimeout bounds connecting to FreeSWITCH, TLS handshake included.
+// Defaults to no timeout, leaving it to the operating system.
+func WithDialTimeout(dialTimeout time.Duration) Option {
+	return func(fs *FSock) { fs.dialTimeout = dialTimeout }
+}
+
+// WithKeepAlive sets the period of the TCP keepalive probes, detecting the dead peers at
+// the OS level even while no events flow, next to the HEARTBEAT watchdog. Negative disables
+// them, 0 keeps the ones of the dialer, 15 seconds for the default one.
+func WithKeepAlive(period time.Duration) Option {
+	return func(fs *FSock) { fs.keepAlive = period }
+}
+
+// WithDialer sets the function establishing the connections, giving control over source
+// address binding, keepalive, proxies or DNS. TLS, if configured, is still handled by FSock.
+func WithDialer(dialFunc DialFunc) Option {
+	return func(fs *FSock) { fs.dialFunc = dialFunc }
+}
+
+// WithProxy dials FreeSWITCH through the proxy, given as socks5://host:port or
+// http://host:port for HTTP CONNECT, with the optional user:password@ authenticating to
+// it. The proxy is reached through the dialer set with WithDialer, if any. The host names
+// are resolved by the proxy, the unix sockets cannot be proxied.
+func WithProxy(proxy *url.URL) Option {
+	return func(fs *FSock) { fs.proxy = proxy }
+}
+
+// WithContentHandler hands the messages received with contentType, e.g. text/rude-rejection,
+// to handler, replacing the built-in handling if any. The replies to the commands,
+// api/response and command/reply, cannot be taken over. The handler runs on the goroutine
+// reading the connection, so no replies are read meanwhile: waiting for replies of commands
+// sent on the same connection deadlocks until the reply timeout, start a goroutine for them.
+func WithContentHandler(contentType string, handler ContentHandler) Option {
+	return func(fs *FSock) {
+		if contentType == "api/response" || contentType == "command/reply" {
+			return
+		}
+		if fs.contentHandlers == nil {
+			fs.contentHandlers = make(map[string]ContentHandler)
+		}
+		fs.contentHandlers[contentType] = handler
+	}
+}
+
+// WithPasswordProvider gets the password from provider on every connect, reconnects
+// included, instead of using the one given to the constructor, so it can be fetched
+// from a secrets store and rotated. A provider error fails the connect attempt.
+func WithPasswordProvider(provider func() (string, error)) Option {
+	return func(fs *FSock) { fs.passwdProvider = provider }
+}
+
+// WithUserAuth logs in as user@domain through userauth, with the password given to the
+// constructor being the one of the user, for the deployments restricting the event socket
+// per user through their esl-allowed-* directory params.
+func WithUserAuth(user, domain string) Option {
+	return func(fs *FSock) { fs.authUser = user + "@" + domain }
+}
+
+// WithWriteTimeout sets the deadline of every write to FreeSWITCH, so a wedged peer
+// cannot block sending a command. Defaults to no timeout.
+func WithWriteTimeout(writeTimeout time.Duration) Option {
+	return func(fs *FSock) { fs.writeTimeout = writeTimeout }
+}
+
+// WithDelayFunc sets the constructor of the delays between reconnect attempts.
+// Defaults to FibDuration, see also ExponentialDelay and ConstantDelay.
+func WithDelayFunc(delayFunc func(time.Duration, time.Duration) func() time.Duration) Option {
+	return func(fs *FSock) { fs.delayFunc = delayFunc }
+}
+
+// WithBackoffPolicy sets the policy deciding the delays between reconnect attempts,
+// taking over the delay function. See NewExponentialJitterBackoff.
+func WithBackoffPolicy(backoff BackoffPolicy) Option {
+	return func(fs *FSock) { fs.backoff = backoff }
+}
+
+// WithEventHandlers sets the handlers of the events, indexed by event name.
+func WithEventHandlers(eventHandlers map[string][]func(string, int)) Option {
+	return func(fs *FSock) { fs.eventHandlers = eventHandlers }
+}
+
+// WithTypedEventHandlers adds handlers receiving the events already parsed, indexed by event name.
+// They are dispatched along the ones set with WithEventHandlers.
+func WithTypedEventHandlers(typedEventHandlers map[string][]func(Event, int)) Option {
+	return func(fs *FSock) { fs.typedEventHandlers = typedEventHandlers }
+}
+
+// WithConnInfoEventHandlers adds handlers receiving the ConnInfo of the FSock instead of its
+// index, indexed by event name. They are dispatched along the ones set with WithEventHandlers.
+func WithConnInfoEventHandlers(infoEventHandlers map[string][]func(string, ConnInfo)) Option {
+	return func(fs *FSock) { fs.infoEventHandlers = infoEventHandlers }
+}
+
+// WithEventHandlersCtx sets the context-aware handlers of the events, indexed by event name.
+// Their context carries the ConnInfo, see ConnInfoFromContext.
+func WithEventHandlersCtx(ctxEventHandlers map[string][]EventHandlerCtx) Option {
+	return func(fs *FSock) { fs.ctxEventHandlers = ctxEventHandlers }
+}
+
+// WithEventFilters sets the event filters, indexed by header name.
+func WithEventFilters(eventFilters map[string][]string) Option {
+	return func(fs *FSock) { fs.eventFilters = eventFilters }
+}
+
+// WithLogger sets the logger. Nothing is logged by default.
+func WithLogger(logger Logger) Option {
+	return func(fs *FSock) { fs.logger = logger }
+}
+
+// WithSlogLogger logs through lgr, with structured fields like conn_idx, addr and event_name.
+func WithSlogLogger(lgr *slog.Logger) Option {
+	return func(fs *FSock) { fs.logger = NewSlogLogger(lgr) }
+}
+
+// WithConnIdx sets the identifier handed to the event handlers.
+func WithConnIdx(connIdx int) Option {
+	return func(fs *FSock) { fs.connIdx = connIdx }
+}
+
+// WithConnID names the connections, the ID being attached to their logs and ConnInfo.
+func WithConnID(connID string) Option {
+	return func(fs *FSock) { fs.connID = connID }
+}
+
+// WithConnMetadata describes the connections, e.g. with the site or the tenant they serve,
+// the metadata being attached to their structured logs and ConnInfo.
+func WithConnMetadata(metadata map[string]string) Option {
+	return func(fs *FSock) { fs.connMetadata = maps.Clone(metadata) }
+}
+
+// WithBgapi enables or disables the support for bgapi commands.
+func WithBgapi(bgapi bool) Option {
+	return func(fs *FSock) { fs.bgapi = bgapi }
+}
+
+// WithStopError sets the channel communicating the final disconnect.
+func WithStopError(stopError chan error) Option {
+	return func(fs *FSock) { fs.stopError = stopError }
+}
+
+// WithOnError sets the callback informed about the final disconnect, as an alternative
+// to the stopError channel not needing a goroutine draining it. It receives the error
+// failing the reconnects, nil if the connection was closed on purpose, and is invoked in
+// its own goroutine.
+func WithOnError(onError func(error)) Option {
+	return func(fs *FSock) { fs.onError = onError }
+}
+
+// WithTLSConfig enables TLS for the connection to FreeSWITCH.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(fs *FSock) { fs.tlsConfig = tlsConfig }
+}
+
+// WithEventFormat sets the format events are subscribed in, EventFormatPlain or EventFormatXML.
+// Defaults to EventFormatPlain.
+func WithEventFormat(format string) Option {
+	return func(fs *FSock) { fs.eventFormat = format }
+}
+
+// WithLinger sends linger after auth, asking FreeSWITCH to keep delivering the queued
+// events for the given seconds after hangup. Disconnect waits for them, up to that long.
+func WithLinger(seconds int) Option {
+	return func(fs *FSock) { fs.linger = seconds }
+}
+
+// WithBgapiJobTTL expires the bgapi jobs whose BACKGROUND_JOB is not received within ttl,
+// e.g. lost over a reconnect, delivering "-ERR bgapi job expired" on their channel.
+func WithBgapiJobTTL(ttl time.Duration) Option {
+	return func(fs *FSock) { fs.bgapiJobTTL = ttl }
+}
+
+// WithHeartbeatWatchdog subscribes to HEARTBEAT, let through the event filters as well,
+// and drops the connection once nothing is received from FreeSWITCH for timeout,
+// reconnecting if allowed. Keep timeout above the FreeSWITCH heartbeat interval, 20
+// seconds by default.
+func WithHeartbeatWatchdog(timeout time.Duration) Option {
+	return func(fs *FSock) { fs.heartbeatTimeout = timeout }
+}
+
+// ConnHook is notified about the connection lifecycle, with the remote address of
+// the connection concerned. Hooks are invoked in their own goroutine.
+type ConnHook func(connIdx int, remoteAddr net.Addr)
+
+// WithDefaultEventHandler sets the handler receiving the events no other handler matched,
+// instead of logging them. Useful for auditing and catching subscription mistakes.
+func WithDefaultEventHandler(handler func(string, int)) Option {
+	return func(fs *FSock) { fs.defaultHandler = handler }
+}
+
+// WithDispatchWorkers runs the event handlers on a fixed number of workers instead of
+// one goroutine per handler and event. Up to queueSize handler calls wait for a free
+// worker, further events are read only once the queue has room again. Meanwhile no
+// replies are read either, so handlers waiting for replies of commands sent on the same
+// connection can deadlock, until the reply timeout, once all the workers do so with the
+// queue full. Size the pool for them, or send their commands on another connection.
+func WithDispatchWorkers(workers, queueSize int) Option {
+	return func(fs *FSock) {
+		fs.dispatchWorkers = workers
+		fs.dispatchQueue = queueSize
+	}
+}
+
+// WithEventQueue buffers up to capacity events between the reading of the connection and
+// their dispatching, policy deciding the fate of the events received once it is full.
+// The bgapi results are never dropped. See FSock.DroppedEvents. The queue of each Events
+// consumer is bounded the same. With OverflowBlock the connection is not read while the
+// queue is full, replies included, so handlers waiting for replies of commands sent on
+// the same connection can deadlock until the reply timeout.
+func WithEventQueue(capacity int, policy OverflowPolicy) Option {
+	return func(fs *FSock) {
+		fs.eventQueueSize = capacity
+		fs.overflowPolicy = policy
+	}
+}
+
+// WithDeadLetterSink routes the events dropped by the full event queue, as well as the
+// ones whose handler panicked, to sink instead of losing them. With a sink configured,
+// the panics of the handlers are recovered. See DeadLetterWriter.
+func WithDeadLetterSink(sink DeadLetterSink) Option {
+	return func(fs *FSock) { fs.deadLetter = sink }
+}
+
+// WithSequenceGapDetection follows the Event-Sequence of the received events, across
+// reconnects, logging the gaps and notifying handler, if not nil. FreeSWITCH numbers all
+// its events in one sequence, so it is meaningful only with all the events subscribed and
+// no filters, as billing-grade consumers need to know when the stream is incomplete.
+func WithSequenceGapDetection(handler SequenceGapHandler) Option {
+	return func(fs *FSock) { fs.sequences = &sequenceTracker{handler: handler} }
+}
+
+// WithEventLag measures the lag of the received events, the time between FreeSWITCH firing
+// them, as of Event-Date-Timestamp, and their dispatching, showing when the network or the
+// event queue fall behind. The last lag is returned by FSock.EventLag, handler, if not nil,
+// receiving each of them. Relies on the clocks of the two hosts being in sync.
+func WithEventLag(handler EventLagHandler) Option {
+	return func(fs *FSock) { fs.lag = &lagMeter{handler: handler} }
+}
+
+// WithMetrics collects the activity of the FSock into m: events received, commands sent
+// and their reply latencies, reconnects and outstanding bgapi jobs. The same Metrics can
+// be shared by several FSocks.
+func WithMetrics(m *Metrics) Option {
+	return func(fs *FSock) { fs.metrics = m }
+}
+
+// WithWireTrace writes to w every raw frame sent to and received from FreeSWITCH, headers
+// and bodies apart, for diagnosing protocol issues without capturing the traffic. Frames
+// longer than maxFrame bytes are truncated, unless maxFrame is not positive. The auth
+// password is masked, see WithRedactor for masking more. Meant for debugging, it slows down the connection.
+func WithWireTrace(w io.Writer, maxFrame int) Option {
+	return func(fs *FSock) { fs.tracer = &wireTracer{w: w, maxFrame: maxFrame} }
+}
+
+// WithEventRecorder writes every event received to w, together with the time it arrived,
+// so the stream can be replayed later through NewReplayConn, e.g. for reproducing an
+// incident or load testing the handlers. The writes are serialized, w needs not be safe
+// for concurrent use.
+func WithEventRecorder(w io.Writer) Option {
+	return func(fs *FSock) { fs.recorder = &eventRecorder{w: w} }
+}
+
+// WithRedactor passes the messages logged, the frames traced and the errors built out of
+// the FreeSWITCH replies through redact, so sensitive api arguments, e.g. passwords given
+// to the user directory commands, can be masked. The auth password is always masked.
+func WithRedactor(redact Redactor) Option {
+	return func(fs *FSock) { fs.redactor = redact }
+}
+
+// WithSyncDispatch invokes the handlers one after the other within the loop reading the
+// connection, guaranteeing the events are handled in the order received, while slow handlers
+// leave the events queued in the socket buffers. The handlers must not wait for replies
+// of commands sent on the same connection, those being read by the same loop.
+// Takes precedence over WithDispatchWorkers.
+func WithSyncDispatch(syncDispatch bool) Option {
+	return func(fs *FSock) { fs.syncDispatch = syncDispatch }
+}
+
+// WithOnConnect sets the hook invoked once the first connection is established.
+func WithOnConnect(hook ConnHook) Option {
+	return func(fs *FSock) { fs.onConnect = hook }
+}
+
+// WithOnDisconnect sets the hook invoked whenever the connection drops or is closed.
+func WithOnDisconnect(hook ConnHook) Option {
+	return func(fs *FSock) { fs.onDisconnect = hook }
+}
+
+// WithOnReconnect sets the hook invoked every time the connection is re-established.
+func WithOnReconnect(hook ConnHook) Option {
+	return func(fs *FSock) { fs.onReconnect = hook }
+}
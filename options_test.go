@@ -0,0 +1,80 @@
+/*
+options_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptionsNewFSockDefaults(t *testing.T) {
+	fs := newFSock("127.0.0.1:8021", "ClueCon")
+	if fs.addr != "127.0.0.1:8021" || fs.passwd != "ClueCon" {
+		t.Errorf("unexpected address/password: %q/%q", fs.addr, fs.passwd)
+	}
+	if _, isNop := fs.logger.(nopLogger); !isNop {
+		t.Errorf("\nExpected: <%T>, \nReceived: <%T>", nopLogger{}, fs.logger)
+	}
+	if fs.delayFunc == nil || fs.eventHandlers == nil || fs.ctxEventHandlers == nil || fs.eventFilters == nil {
+		t.Errorf("expected non-nil defaults, received: %+v", fs)
+	}
+	if fs.reconnects != 0 || fs.replyTimeout != 0 || fs.bgapi {
+		t.Errorf("unexpected defaults: %+v", fs)
+	}
+	var nilLogger *loggerMock
+	if fs = newFSock("", "", WithLogger(nilLogger)); fs.logger != (nopLogger{}) {
+		t.Errorf("\nExpected: <%T>, \nReceived: <%T>", nopLogger{}, fs.logger)
+	}
+}
+
+func TestOptionsNewFSock(t *testing.T) {
+	evHandlers := map[string][]func(string, int){"HEARTBEAT": {func(string, int) {}}}
+	evFilters := map[string][]string{"Event-Name": {"HEARTBEAT"}}
+	stopError := make(chan error)
+	tlsCfg := &tls.Config{}
+	l := &loggerMock{}
+	fs := newFSock("127.0.0.1:8021", "ClueCon",
+		WithReconnects(-1),
+		WithMaxReconnectInterval(time.Minute),
+		WithReplyTimeout(5*time.Second),
+		WithEventHandlers(evHandlers),
+		WithEventFilters(evFilters),
+		WithLogger(l),
+		WithConnIdx(7),
+		WithBgapi(true),
+		WithStopError(stopError),
+		WithTLSConfig(tlsCfg),
+		WithEventFormat(EventFormatXML),
+		WithLinger(10),
+		WithBgapiJobTTL(time.Minute),
+		WithDispatchWorkers(4, 100),
+		WithSyncDispatch(true),
+		WithEventQueue(50, OverflowDropOldest),
+		WithConnID("fs1"),
+		WithConnMetadata(map[string]string{"site": "eu"}),
+		WithUserAuth("1000", "example.com"),
+	)
+	if fs.reconnects != -1 || fs.maxReconnectInterval != time.Minute ||
+		fs.replyTimeout != 5*time.Second || fs.connIdx != 7 || !fs.bgapi {
+		t.Errorf("options not applied: %+v", fs)
+	}
+	if !reflect.DeepEqual(fs.eventFilters, evFilters) || len(fs.eventHandlers["HEARTBEAT"]) != 1 {
+		t.Errorf("handlers/filters not applied: %+v", fs)
+	}
+	if fs.logger != l || fs.stopError != stopError || fs.tlsConfig != tlsCfg {
+		t.Errorf("options not applied: %+v", fs)
+	}
+	expConnOpts := connOptions{eventFormat: EventFormatXML, linger: 10, bgapiJobTTL: time.Minute,
+		dispatchWorkers: 4, dispatchQueue: 100, syncDispatch: true,
+		eventQueueSize: 50, overflowPolicy: OverflowDropOldest,
+		connID: "fs1", connMetadata: map[string]string{"site": "eu"}, authUser: "1000@example.com"}
+	if !reflect.DeepEqual(fs.connOptions, expConnOpts) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", expConnOpts, fs.connOptions)
+	}
+}
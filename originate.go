@@ -0,0 +1,167 @@
+/*
+originate.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OriginateRequest describes a call to originate, composed into the originate command
+// by Command.
+type OriginateRequest struct {
+	Endpoint       string            // e.g. user/1001 or sofia/gateway/gw1/1002
+	Destination    string            // &app(args) or extension [dialplan [context]], &park() if empty
+	Vars           map[string]string // channel variables, within {}
+	LegVars        map[string]string // variables of the endpoint leg, within []
+	UUID           string            // origination_uuid, generated by Originate if empty
+	CallerIDName   string            // origination_caller_id_name
+	CallerIDNumber string            // origination_caller_id_number
+	Timeout        time.Duration     // originate_timeout, rounded up to seconds, FreeSWITCH default if 0
+}
+
+// OriginateResult is the outcome of an originated call.
+type OriginateResult struct {
+	UUID        string
+	Answered    bool
+	AnswerTime  time.Time // zero if not answered
+	HangupCause string    // e.g. NO_ANSWER or USER_BUSY, empty if answered
+}
+
+// Command composes the originate command out of the request, without the api or bgapi
+// prefix. The variables are checked and escaped, returning ErrUnsafeArg for the ones
+// which cannot be.
+func (req OriginateRequest) Command() (string, error) {
+	if req.Endpoint == "" || strings.ContainsAny(req.Endpoint, " \t\r\n\x00") {
+		return "", fmt.Errorf("%w: endpoint %q", ErrUnsafeArg, req.Endpoint)
+	}
+	vars := maps.Clone(req.Vars)
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	for name, val := range map[string]string{
+		"origination_uuid":             req.UUID,
+		"origination_caller_id_name":   req.CallerIDName,
+		"origination_caller_id_number": req.CallerIDNumber,
+	} {
+		if val != "" {
+			vars[name] = val
+		}
+	}
+	if req.Timeout > 0 {
+		vars["originate_timeout"] = strconv.Itoa(int((req.Timeout + time.Second - 1) / time.Second))
+	}
+	globalVars, err := dialVars(vars, "{", "}")
+	if err != nil {
+		return "", err
+	}
+	legVars, err := dialVars(req.LegVars, "[", "]")
+	if err != nil {
+		return "", err
+	}
+	dest := req.Destination
+	if dest == "" {
+		dest = "&park()"
+	}
+	if err = CheckArg(dest); err != nil {
+		return "", err
+	}
+	return "originate " + globalVars + legVars + req.Endpoint + " " + dest, nil
+}
+
+// dialVars formats the variables of a dialstring enclosed within opener and closer, sorted
+// by name. The commas within the values are escaped, the values with spaces or group
+// closers being quoted.
+func dialVars(vars map[string]string, opener, closer string) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	flds := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, "=,'{}[]<> \t\r\n\x00") {
+			return "", fmt.Errorf("%w: variable name %q", ErrUnsafeArg, name)
+		}
+		val := vars[name]
+		if err := CheckArg(val); err != nil {
+			return "", fmt.Errorf("%w, variable %s", err, name)
+		}
+		if strings.Contains(val, "'") {
+			return "", fmt.Errorf("%w: quote within variable %s", ErrUnsafeArg, name)
+		}
+		val = strings.ReplaceAll(val, ",", `\,`)
+		if strings.ContainsAny(val, " \t}]") {
+			val = "'" + val + "'"
+		}
+		flds = append(flds, name+"="+val)
+	}
+	return opener + strings.Join(flds, ",") + closer, nil
+}
+
+// Originate submits the call through bgapi and follows it until answered or hung up,
+// through its CHANNEL_ANSWER and CHANNEL_HANGUP events, which must be subscribed to,
+// e.g. with event handlers, and not filtered out. A call failing before its channel is
+// created is reported out of the bgapi result.
+func (fs *FSock) Originate(ctx context.Context, req OriginateRequest) (OriginateResult, error) {
+	if req.UUID == "" {
+		req.UUID = genUUID()
+	}
+	cmd, err := req.Command()
+	if err != nil {
+		return OriginateResult{}, err
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := fs.Events(streamCtx, "CHANNEL_ANSWER", "CHANNEL_HANGUP") // before originating, not to miss any
+	out, err := fs.SendBgapiCmdCtx(ctx, cmd)
+	if err != nil {
+		return OriginateResult{}, err
+	}
+	res := OriginateResult{UUID: req.UUID}
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return OriginateResult{}, ctx.Err()
+			}
+			if ev.UUID() != req.UUID {
+				continue
+			}
+			if answered := ev.GetHeader("Caller-Channel-Answered-Time"); answered != "" && answered != "0" {
+				res.Answered, res.AnswerTime = true, parseEventTimestamp(answered)
+				return res, nil // hung up right after the answer if CHANNEL_HANGUP
+			}
+			if ev.Name() == "CHANNEL_HANGUP" {
+				res.HangupCause = ev.GetHeader("Hangup-Cause")
+				return res, nil
+			}
+		case rply, ok := <-out:
+			if !ok {
+				if err = ctx.Err(); err == nil {
+					err = ErrNotConnected
+				}
+				return OriginateResult{}, err
+			}
+			if cause, failed := strings.CutPrefix(strings.TrimSpace(rply), "-ERR"); failed {
+				res.HangupCause = strings.TrimSpace(cause)
+				return res, nil
+			}
+			out = nil // answered, its CHANNEL_ANSWER follows
+		}
+	}
+}
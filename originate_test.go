@@ -0,0 +1,88 @@
+/*
+originate_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestOriginateRequestCommand(t *testing.T) {
+	req := OriginateRequest{
+		Endpoint:       "sofia/gateway/gw1/1002",
+		Vars:           map[string]string{"absolute_codec_string": "PCMA,PCMU", "cgr_reqtype": "*prepaid"},
+		LegVars:        map[string]string{"sip_h_X-Note": "two words"},
+		UUID:           "5e1b3d42",
+		CallerIDName:   "Extension 1001",
+		CallerIDNumber: "1001",
+		Timeout:        1500 * time.Millisecond,
+	}
+	exp := `originate {absolute_codec_string=PCMA\,PCMU,cgr_reqtype=*prepaid,originate_timeout=2,` +
+		`origination_caller_id_name='Extension 1001',origination_caller_id_number=1001,origination_uuid=5e1b3d42}` +
+		`[sip_h_X-Note='two words']sofia/gateway/gw1/1002 &park()`
+	if cmd, err := req.Command(); err != nil {
+		t.Error(err)
+	} else if cmd != exp {
+		t.Errorf("\nExpected: <%s>, \nReceived: <%s>", exp, cmd)
+	}
+
+	for _, req := range []OriginateRequest{
+		{},
+		{Endpoint: "user/1001 &park()"},
+		{Endpoint: "user/1001", Vars: map[string]string{"a}b": "1"}},
+		{Endpoint: "user/1001", Vars: map[string]string{"name": "O'Brien"}},
+		{Endpoint: "user/1001", LegVars: map[string]string{"name": "a\nb"}},
+		{Endpoint: "user/1001", Destination: "&park()\napi status"},
+	} {
+		if _, err := req.Command(); !errors.Is(err, ErrUnsafeArg) {
+			t.Errorf("expected ErrUnsafeArg for %+v, received: %v", req, err)
+		}
+	}
+}
+
+func TestFSockOriginate(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword, WithBgapi(true),
+		WithEventHandlers(map[string][]func(string, int){"CHANNEL_ANSWER": nil, "CHANNEL_HANGUP": nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	srv.Stub("bgapi originate", "+OK 5e1b3d42\n")
+	go func() {
+		if _, err := srv.WaitCommand("bgapi originate", time.Second); err != nil {
+			t.Error(err)
+			return
+		}
+		srv.SendEvent(map[string]string{
+			"Event-Name":                   "CHANNEL_ANSWER",
+			"Unique-ID":                    "5e1b3d42",
+			"Caller-Channel-Answered-Time": "1704880805000000",
+		}, "")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	exp := OriginateResult{UUID: "5e1b3d42", Answered: true, AnswerTime: time.UnixMicro(1704880805000000)}
+	if res, err := fs.Originate(ctx, OriginateRequest{Endpoint: "user/1002", UUID: "5e1b3d42"}); err != nil {
+		t.Error(err)
+	} else if res != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, res)
+	}
+
+	srv.Stub("bgapi originate", "-ERR USER_BUSY\n")
+	exp = OriginateResult{UUID: "7c2d9a10", HangupCause: "USER_BUSY"}
+	if res, err := fs.Originate(ctx, OriginateRequest{Endpoint: "user/1002", UUID: "7c2d9a10"}); err != nil {
+		t.Error(err)
+	} else if res != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, res)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+proxy.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// dialer returns the function establishing the connections: dialFunc, or the default
+// dialer if nil, going through the proxy when one is configured.
+func (opts *connOptions) dialer() DialFunc {
+	if opts.proxy == nil {
+		return opts.dialFunc
+	}
+	proxy, dialFunc := opts.proxy, opts.dialFunc
+	if dialFunc == nil {
+		dialFunc = new(net.Dialer).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network != "tcp" {
+			return nil, fmt.Errorf("cannot reach %s over %s through a proxy", addr, network)
+		}
+		return dialProxy(ctx, proxy, addr, dialFunc)
+	}
+}
+
+// dialProxy connects to addr through the SOCKS5 or HTTP CONNECT proxy, reached with dialFunc.
+func dialProxy(ctx context.Context, proxy *url.URL, addr string, dialFunc DialFunc) (conn net.Conn, err error) {
+	var handshake func(net.Conn, *url.URL, string) (net.Conn, error)
+	switch proxy.Scheme {
+	case "socks5", "socks5h":
+		handshake = socks5Connect
+	case "http":
+		handshake = httpConnect
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: <%s>", proxy.Scheme)
+	}
+	if conn, err = dialFunc(ctx, "tcp", proxy.Host); err != nil {
+		return nil, err
+	}
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+		close(interrupted)
+	})
+	tunnel, err := handshake(conn, proxy, addr)
+	if !stop() {
+		<-interrupted
+		conn.SetDeadline(time.Time{})
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s failed to connect to %s: %w", proxy.Redacted(), addr, err)
+	}
+	return tunnel, nil
+}
+
+// socks5Connect asks the SOCKS5 server on conn to connect to addr, authenticating with
+// the username and password of the proxy URL if any, see RFC 1928 and RFC 1929.
+func socks5Connect(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	portNr, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port of %s", addr)
+	}
+	method := byte(0x00) // no authentication
+	if proxy.User != nil {
+		method = 0x02 // username/password
+	}
+	if _, err = conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return nil, err
+	}
+	rply := make([]byte, 2)
+	if _, err = io.ReadFull(conn, rply); err != nil {
+		return nil, err
+	}
+	if rply[0] != 0x05 || rply[1] != method {
+		return nil, fmt.Errorf("unexpected SOCKS5 method selection received: <%x>", rply)
+	}
+	if method == 0x02 {
+		user := proxy.User.Username()
+		passwd, _ := proxy.User.Password()
+		if len(user) > 255 || len(passwd) > 255 {
+			return nil, errors.New("SOCKS5 username or password too long")
+		}
+		auth := append([]byte{0x01, byte(len(user))}, user...)
+		auth = append(append(auth, byte(len(passwd))), passwd...)
+		if _, err = conn.Write(auth); err != nil {
+			return nil, err
+		}
+		if _, err = io.ReadFull(conn, rply); err != nil {
+			return nil, err
+		}
+		if rply[1] != 0x00 {
+			return nil, errors.New("SOCKS5 authentication failed")
+		}
+	}
+	req := []byte{0x05, 0x01, 0x00} // CONNECT
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if ip.Is4() {
+			req = append(append(req, 0x01), ip.AsSlice()...)
+		} else {
+			req = append(append(req, 0x04), ip.AsSlice()...)
+		}
+	} else if len(host) > 255 {
+		return nil, fmt.Errorf("host name too long: %s", host)
+	} else {
+		req = append(append(req, 0x03, byte(len(host))), host...) // resolved by the proxy
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(portNr))
+	if _, err = conn.Write(req); err != nil {
+		return nil, err
+	}
+	hdr := make([]byte, 4)
+	if _, err = io.ReadFull(conn, hdr); err != nil {
+		return nil, err
+	}
+	if hdr[0] != 0x05 {
+		return nil, fmt.Errorf("unexpected SOCKS5 reply received: <%x>", hdr)
+	}
+	if hdr[1] != 0x00 {
+		return nil, fmt.Errorf("SOCKS5 connect rejected with code %d", hdr[1])
+	}
+	var bndLen int // the bound address, not needed
+	switch hdr[3] {
+	case 0x01:
+		bndLen = net.IPv4len
+	case 0x04:
+		bndLen = net.IPv6len
+	case 0x03:
+		if _, err = io.ReadFull(conn, hdr[:1]); err != nil {
+			return nil, err
+		}
+		bndLen = int(hdr[0])
+	default:
+		return nil, fmt.Errorf("unexpected SOCKS5 address type received: <%x>", hdr[3])
+	}
+	if _, err = io.CopyN(io.Discard, conn, int64(bndLen+2)); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// httpConnect asks the HTTP proxy on conn to tunnel to addr, authenticating with the
+// username and password of the proxy URL if any.
+func httpConnect(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		passwd, _ := proxy.User.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+
+			base64.StdEncoding.EncodeToString([]byte(proxy.User.Username()+":"+passwd)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	rdr := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(rdr, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP CONNECT rejected with status: <%s>", resp.Status)
+	}
+	if rdr.Buffered() != 0 { // FreeSWITCH speaking first, its auth/request can be read already
+		return &bufferedConn{Conn: conn, rdr: rdr}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose reads go through rdr first.
+type bufferedConn struct {
+	net.Conn
+	rdr *bufio.Reader
+}
+
+func (bc *bufferedConn) Read(b []byte) (int, error) {
+	return bc.rdr.Read(b)
+}
@@ -0,0 +1,185 @@
+/*
+proxy_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+// serveProxy accepts the connections on a local listener, handing each to handshake, which
+// returns the address asked for, then relays the traffic to it.
+func serveProxy(t *testing.T, handshake func(net.Conn, *bufio.Reader) (string, error)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				rdr := bufio.NewReader(conn)
+				addr, err := handshake(conn, rdr)
+				if err != nil {
+					return
+				}
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go io.Copy(target, rdr)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// socks5Handshake serves the SOCKS5 handshake, requiring the user:passwd credentials.
+func socks5Handshake(conn net.Conn, rdr *bufio.Reader) (string, error) {
+	greeting := make([]byte, 3)
+	if _, err := io.ReadFull(rdr, greeting); err != nil {
+		return "", err
+	}
+	if greeting[2] != 0x02 {
+		conn.Write([]byte{0x05, 0xff})
+		return "", io.EOF
+	}
+	conn.Write([]byte{0x05, 0x02})
+	var creds []string
+	for i := 0; i < 2; i++ {
+		lenByte := make([]byte, 2-i) // version first
+		if _, err := io.ReadFull(rdr, lenByte); err != nil {
+			return "", err
+		}
+		cred := make([]byte, lenByte[len(lenByte)-1])
+		if _, err := io.ReadFull(rdr, cred); err != nil {
+			return "", err
+		}
+		creds = append(creds, string(cred))
+	}
+	if creds[0] != "user" || creds[1] != "passwd" {
+		conn.Write([]byte{0x01, 0x01})
+		return "", io.EOF
+	}
+	conn.Write([]byte{0x01, 0x00})
+	req := make([]byte, 5)
+	if _, err := io.ReadFull(rdr, req); err != nil {
+		return "", err
+	}
+	if req[3] != 0x03 {
+		return "", io.EOF
+	}
+	dst := make([]byte, int(req[4])+2)
+	if _, err := io.ReadFull(rdr, dst); err != nil {
+		return "", err
+	}
+	host, port := string(dst[:req[4]]), binary.BigEndian.Uint16(dst[req[4]:])
+	if host == "fs.test" {
+		host = "127.0.0.1" // resolved by the proxy
+	}
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+func TestProxySOCKS5(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	_, port, _ := net.SplitHostPort(srv.Addr())
+	proxyAddr := serveProxy(t, socks5Handshake)
+	fs, err := NewFSockWithOptions("fs.test:"+port, fsocktest.DefaultPassword,
+		WithProxy(&url.URL{Scheme: "socks5", Host: proxyAddr, User: url.UserPassword("user", "passwd")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	srv.Stub("api status", "UP 0 years")
+	if rply, err := fs.SendApiCmd("status"); err != nil {
+		t.Error(err)
+	} else if rply != "UP 0 years" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "UP 0 years", rply)
+	}
+
+	if _, err = NewFSockWithOptions("fs.test:"+port, fsocktest.DefaultPassword,
+		WithProxy(&url.URL{Scheme: "socks5", Host: proxyAddr, User: url.UserPassword("user", "wrong")})); err == nil ||
+		!strings.Contains(err.Error(), "SOCKS5 authentication failed") {
+		t.Errorf("expected the authentication failure, received: %v", err)
+	}
+}
+
+func TestProxyHTTPConnect(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	proxyAddr := serveProxy(t, func(conn net.Conn, rdr *bufio.Reader) (string, error) {
+		req, err := http.ReadRequest(rdr)
+		if err != nil {
+			return "", err
+		}
+		if req.Method != http.MethodConnect {
+			io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+			return "", io.EOF
+		}
+		if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNzd2Q=" { // user:passwd
+			io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return "", io.EOF
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		return req.Host, nil
+	})
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithProxy(&url.URL{Scheme: "http", Host: proxyAddr, User: url.UserPassword("user", "passwd")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	srv.Stub("api status", "UP 0 years")
+	if rply, err := fs.SendApiCmd("status"); err != nil {
+		t.Error(err)
+	} else if rply != "UP 0 years" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "UP 0 years", rply)
+	}
+
+	if _, err = NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword,
+		WithProxy(&url.URL{Scheme: "http", Host: proxyAddr})); err == nil ||
+		!strings.Contains(err.Error(), "407") {
+		t.Errorf("expected the rejected CONNECT, received: %v", err)
+	}
+}
+
+func TestProxyUnsupported(t *testing.T) {
+	for name, tc := range map[string]struct {
+		addr  string
+		proxy *url.URL
+		err   string
+	}{
+		"scheme": {addr: "127.0.0.1:8021", proxy: &url.URL{Scheme: "https", Host: "127.0.0.1:1"},
+			err: "unsupported proxy scheme: <https>"},
+		"unix": {addr: "unix:/var/run/freeswitch/esl.sock", proxy: &url.URL{Scheme: "socks5", Host: "127.0.0.1:1"},
+			err: "cannot reach /var/run/freeswitch/esl.sock over unix through a proxy"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewFSockWithOptions(tc.addr, "ClueCon", WithProxy(tc.proxy))
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+/*
+pull.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NewFSConnPull connects to FreeSWITCH, authenticates and subscribes to the events named,
+// without starting to read from the connection: the events are pulled with NextEvent,
+// which also reads the replies to the commands sent meanwhile. A command waits for its
+// reply until NextEvent is called, so the two must not be called from the same goroutine.
+func NewFSConnPull(ctx context.Context, addr, passwd string, connIdx int, lgr Logger,
+	evFilters map[string][]string, eventNames []string, bgapi bool) (*FSConn, error) {
+	if lgr == nil {
+		lgr = nopLogger{}
+	}
+	eventHandlers := make(map[string][]func(string, int), len(eventNames)) // subscribed only
+	for _, evName := range eventNames {
+		eventHandlers[evName] = nil
+	}
+	return newFSConnCtx(ctx, addr, passwd, connIdx, 0, make(chan error, 1), lgr,
+		evFilters, eventHandlers, nil, bgapi, nil, connOptions{pull: true})
+}
+
+// NextEvent reads from the connection until the next event arrives, handing over meanwhile
+// the replies to the commands sent, and returns it. The results of the bgapi jobs are
+// handed to their commands instead of being returned. Only for the connections built
+// with NewFSConnPull. Once ctx is done, ctx.Err() is returned and the connection can be
+// read further, any other error meaning it is lost.
+func (fsConn *FSConn) NextEvent(ctx context.Context) (Event, error) {
+	if !fsConn.pull {
+		return Event{}, errors.New("not a connection built with NewFSConnPull")
+	}
+	for {
+		if err := fsConn.awaitFrame(ctx); err != nil {
+			if ctx.Err() == nil {
+				fsConn.cancelHandlers()
+				fsConn.failReplies()
+			}
+			return Event{}, err
+		}
+		hdr, err := fsConn.readHeaderBytes()
+		var body string
+		var streamed bool
+		if err == nil {
+			if bytes.Contains(hdr, []byte("api/response")) && fsConn.streamAwaited() {
+				streamed = true
+				err = fsConn.streamReply(hdr)
+			} else {
+				body, err = fsConn.readContent(hdr)
+			}
+		}
+		if err != nil {
+			fsConn.cancelHandlers()
+			fsConn.failReplies()
+			return Event{}, err
+		}
+		var event string
+		switch contentType := string(contentTypeOf(hdr)); {
+		case streamed:
+			continue
+		case contentType == "text/event-xml":
+			if event, err = xmlEventToPlain(body); err != nil {
+				fsConn.log().Warning(fmt.Sprintf("<FSock> Cannot decode XML event: <%v>", err))
+				continue
+			}
+		case builtinContentHandlers[contentType] != nil:
+			builtinContentHandlers[contentType](fsConn, hdr, body)
+			continue
+		case body != "":
+			event = body
+		default:
+			continue
+		}
+		switch fullEventName(event) {
+		case "BACKGROUND_JOB":
+			if job, has := fsConn.takeJob(headerVal(event, "Job-UUID")); has {
+				job.deliver(EventToMap(event)[EventBodyTag])
+				continue
+			}
+		case "CHANNEL_EXECUTE_COMPLETE":
+			fsConn.completeExecution(event)
+		}
+		return NewEvent(event), nil
+	}
+}
+
+// awaitFrame waits for the next frame to start arriving, or for ctx to be done. Nothing
+// being consumed from the connection meanwhile, it can be read further after ctx.
+func (fsConn *FSConn) awaitFrame(ctx context.Context) error {
+	if fsConn.rdr.Buffered() != 0 {
+		return nil
+	}
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		fsConn.conn.SetReadDeadline(time.Now())
+		close(interrupted)
+	})
+	_, err := fsConn.rdr.Peek(1)
+	if !stop() {
+		<-interrupted
+		fsConn.conn.SetReadDeadline(time.Time{})
+	}
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+/*
+pull_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestFSConnNextEvent(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.Stub("api status", "UP 0 years, 0 days")
+	srv.Stub("bgapi status", "UP 0 years, 0 days")
+	fsConn, err := NewFSConnPull(context.Background(), srv.Addr(), fsocktest.DefaultPassword, 0, nil,
+		nil, []string{"CHANNEL_ANSWER"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsConn.Disconnect()
+	if cmd, err := srv.WaitCommand("event plain", time.Second); err != nil {
+		t.Fatal(err)
+	} else if exp := "event plain CHANNEL_ANSWER BACKGROUND_JOB"; cmd != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, cmd)
+	}
+
+	// The replies are read while waiting for the events.
+	type result struct {
+		rply string
+		err  error
+	}
+	apiRes, bgapiRes := make(chan result, 1), make(chan result, 1)
+	go func() {
+		rply, err := fsConn.Send("api status\n\n")
+		apiRes <- result{rply, err}
+		out, err := fsConn.SendBgapiCmd("status")
+		if err != nil {
+			bgapiRes <- result{err: err}
+			return
+		}
+		bgapiRes <- result{rply: <-out}
+	}()
+	var results []result
+	for deadline := time.Now().Add(time.Second); len(results) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the replies")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		if _, err := fsConn.NextEvent(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+		}
+		cancel()
+		select {
+		case res := <-apiRes:
+			results = append(results, res)
+		case res := <-bgapiRes:
+			results = append(results, res)
+		default:
+		}
+	}
+	for _, res := range results {
+		if res.err != nil {
+			t.Error(res.err)
+		} else if res.rply != "UP 0 years, 0 days" {
+			t.Errorf("\nExpected: %q, \nReceived: %q", "UP 0 years, 0 days", res.rply)
+		}
+	}
+
+	// Still readable after ctx.
+	if err := srv.SendEvent(map[string]string{"Event-Name": "CHANNEL_ANSWER", "Unique-ID": "uuid1"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := fsConn.NextEvent(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Name() != "CHANNEL_ANSWER" || ev.UUID() != "uuid1" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	srv.DropConnections()
+	if _, err := fsConn.NextEvent(context.Background()); err == nil {
+		t.Error("expected the connection lost")
+	}
+}
+
+func TestFSConnNextEventNotPull(t *testing.T) {
+	if _, err := new(FSConn).NextEvent(context.Background()); err == nil {
+		t.Error("expected NextEvent refused")
+	}
+}
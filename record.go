@@ -0,0 +1,188 @@
+/*
+record.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordedAtHeader carries the time a recorded event was received.
+const recordedAtHeader = "Event-Recorded-At"
+
+// eventRecorder writes the received events, shared by the successive connections of a FSock.
+type eventRecorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// record writes the event received at the given time, framed as FreeSWITCH sends it.
+func (er *eventRecorder) record(event string, received time.Time) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	fmt.Fprintf(er.w, "Content-Length: %d\nContent-Type: text/event-plain\n%s: %s\n\n%s",
+		len(event), recordedAtHeader, received.Format(time.RFC3339Nano), event)
+}
+
+// NewReplayConn returns a connection replaying the events recorded with WithEventRecorder
+// out of r, to be handed to NewFSConnFromConn or returned by the DialFunc of WithDialer.
+// It plays the FreeSWITCH side: accepts any password, answers +OK to the commands and,
+// once the events are subscribed, sends the recorded ones. Their original pace is kept
+// when speed is 1, accelerated by a greater speed, while a speed not positive sends them
+// as fast as they are read. The connection is closed after the last event.
+func NewReplayConn(r io.Reader, speed float64) net.Conn {
+	client, server := net.Pipe()
+	rp := &replayer{
+		conn:       server,
+		rdr:        bufio.NewReader(r),
+		speed:      speed,
+		subscribed: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go rp.answer()
+	go rp.replay()
+	return client
+}
+
+// replayer is the FreeSWITCH side of a replay connection.
+type replayer struct {
+	conn       net.Conn
+	wMu        sync.Mutex // serializes the replies with the events
+	rdr        *bufio.Reader
+	speed      float64
+	subscribed chan struct{} // closed once the events are subscribed
+	done       chan struct{} // closed once the client stops sending commands
+}
+
+// write sends frame to the client.
+func (rp *replayer) write(frame string) error {
+	rp.wMu.Lock()
+	defer rp.wMu.Unlock()
+	_, err := io.WriteString(rp.conn, frame)
+	return err
+}
+
+// answer authenticates the client and replies to its commands until the connection is closed.
+func (rp *replayer) answer() {
+	defer close(rp.done)
+	if rp.write("Content-Type: auth/request\n\n") != nil {
+		return
+	}
+	cmdRdr := bufio.NewReader(rp.conn)
+	var subscribed bool
+	for {
+		cmd, err := readReplayCommand(cmdRdr)
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(cmd, "auth"):
+			err = rp.write("Content-Type: command/reply\nReply-Text: +OK accepted\n\n")
+		case strings.HasPrefix(cmd, "api "):
+			err = rp.write("Content-Type: api/response\nContent-Length: 3\n\n+OK")
+		case strings.HasPrefix(cmd, "bgapi "):
+			_, jobUUID, _ := strings.Cut(cmd, "Job-UUID:")
+			jobUUID, _, _ = strings.Cut(strings.TrimSpace(jobUUID), "\n")
+			err = rp.write("Content-Type: command/reply\nReply-Text: +OK Job-UUID: " + jobUUID + "\n\n")
+		default:
+			err = rp.write("Content-Type: command/reply\nReply-Text: +OK\n\n")
+		}
+		if err != nil {
+			return
+		}
+		if !subscribed && (strings.HasPrefix(cmd, "event ") || strings.HasPrefix(cmd, "myevents")) {
+			subscribed = true
+			close(rp.subscribed)
+		}
+	}
+}
+
+// replay sends the recorded events once subscribed, closing the connection afterwards.
+func (rp *replayer) replay() {
+	defer rp.conn.Close()
+	select {
+	case <-rp.subscribed:
+	case <-rp.done:
+		return
+	}
+	var last time.Time
+	for {
+		hdrs, event, err := readRecordedEvent(rp.rdr)
+		if err != nil {
+			return
+		}
+		if recordedAt, err := time.Parse(time.RFC3339Nano, headerVal(hdrs, recordedAtHeader)); err == nil {
+			if !last.IsZero() && rp.speed > 0 && recordedAt.After(last) {
+				time.Sleep(time.Duration(float64(recordedAt.Sub(last)) / rp.speed))
+			}
+			last = recordedAt
+		}
+		if rp.write(fmt.Sprintf("Content-Length: %d\nContent-Type: text/event-plain\n\n%s", len(event), event)) != nil {
+			return
+		}
+	}
+}
+
+// readReplayCommand reads one command sent by the client, its headers included.
+func readReplayCommand(rdr *bufio.Reader) (string, error) {
+	var cmd strings.Builder
+	for {
+		line, err := rdr.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(line) == "" {
+			if cmd.Len() == 0 {
+				continue
+			}
+			break
+		}
+		cmd.WriteString(line)
+	}
+	if cl, err := strconv.Atoi(headerVal(cmd.String(), "Content-Length")); err == nil && cl > 0 {
+		if _, err = rdr.Discard(cl); err != nil {
+			return "", err
+		}
+	}
+	return cmd.String(), nil
+}
+
+// readRecordedEvent reads the next recorded frame, returning its headers and the event.
+func readRecordedEvent(rdr *bufio.Reader) (hdrs, event string, err error) {
+	var sb strings.Builder
+	for {
+		var line string
+		if line, err = rdr.ReadString('\n'); err != nil {
+			return
+		}
+		if strings.TrimSpace(line) == "" {
+			if sb.Len() == 0 {
+				continue
+			}
+			break
+		}
+		sb.WriteString(line)
+	}
+	hdrs = sb.String()
+	cl, err := strconv.Atoi(headerVal(hdrs, "Content-Length"))
+	if err != nil {
+		return "", "", fmt.Errorf("recorded event without Content-Length: <%s>", hdrs)
+	}
+	body := make([]byte, cl)
+	if _, err = io.ReadFull(rdr, body); err != nil {
+		return
+	}
+	return hdrs, string(body), nil
+}
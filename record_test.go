@@ -0,0 +1,100 @@
+/*
+record_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventRecorder(t *testing.T) {
+	events := []string{
+		"Event-Name: CHANNEL_ANSWER\nUnique-ID: 1\n\n",
+		"Event-Name: CHANNEL_HANGUP\nUnique-ID: 1\n\n",
+	}
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		for _, event := range events {
+			if _, err := fmt.Fprintf(c, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		io.Copy(io.Discard, c)
+	})
+	var rec syncBuffer
+	handled := make(chan string, 2)
+	handler := func(event string, _ int) { handled <- event }
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithEventRecorder(&rec),
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {handler},
+			"CHANNEL_HANGUP": {handler},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	for range events {
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Fatal("event not handled")
+		}
+	}
+	for _, event := range events {
+		if exp := fmt.Sprintf("Content-Length: %d\nContent-Type: text/event-plain\n%s: ", len(event), recordedAtHeader); !strings.Contains(rec.String(), exp) ||
+			!strings.Contains(rec.String(), event) {
+			t.Errorf("event %q not recorded: %q", event, rec.String())
+		}
+	}
+}
+
+func TestReplayConn(t *testing.T) {
+	start := time.Now()
+	var recording bytes.Buffer
+	er := &eventRecorder{w: &recording}
+	er.record("Event-Name: CHANNEL_ANSWER\nUnique-ID: 1\n\n", start)
+	er.record("Event-Name: HEARTBEAT\n\n", start.Add(100*time.Millisecond))
+	er.record("Event-Name: CHANNEL_HANGUP\nUnique-ID: 1\n\n", start.Add(200*time.Millisecond))
+
+	handled := make(chan string, 3)
+	handler := func(event string, _ int) { handled <- headerVal(event, "Event-Name") }
+	fs, err := NewFSockWithOptions("replay", "", WithReconnects(0),
+		WithDialer(func(context.Context, string, string) (net.Conn, error) {
+			return NewReplayConn(bytes.NewReader(recording.Bytes()), 10), nil
+		}),
+		WithEventHandlers(map[string][]func(string, int){
+			"CHANNEL_ANSWER": {handler},
+			"CHANNEL_HANGUP": {handler},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	var rcv []string
+	for range 2 {
+		select {
+		case evName := <-handled:
+			rcv = append(rcv, evName)
+		case <-time.After(time.Second):
+			t.Fatal("event not replayed")
+		}
+	}
+	if exp := []string{"CHANNEL_ANSWER", "CHANNEL_HANGUP"}; !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("replayed faster than 10x: %v", elapsed)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+redact.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import "strings"
+
+// redactedMask replaces the sensitive values.
+const redactedMask = "********"
+
+// Redactor masks the sensitive parts of a text about to be logged, traced or returned
+// within an error, e.g. the passwords given as api arguments.
+type Redactor func(string) string
+
+// redactFrame masks the password of the auth and userauth commands.
+func redactFrame(frame string) string {
+	var prefix, rest string
+	if after, isAuth := strings.CutPrefix(frame, "auth "); isAuth {
+		prefix, rest = "auth ", after
+	} else if after, isUserAuth := strings.CutPrefix(frame, "userauth "); isUserAuth {
+		user, passwd, _ := strings.Cut(after, ":")
+		prefix, rest = "userauth "+user+":", passwd
+	} else {
+		return frame
+	}
+	if eol := strings.IndexByte(rest, '\n'); eol != -1 {
+		return prefix + redactedMask + rest[eol:]
+	}
+	return prefix + redactedMask
+}
+
+// redactSecret masks the occurrences of secret within s.
+func redactSecret(s, secret string) string {
+	if secret == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, secret, redactedMask)
+}
+
+// newRedactingLogger returns lgr with its messages passed through redact, keeping
+// the support for fields if lgr has it.
+func newRedactingLogger(lgr Logger, redact Redactor) Logger {
+	rl := redactingLogger{lgr: lgr, redact: redact}
+	if _, canField := lgr.(FieldLogger); canField {
+		return redactingFieldLogger{rl}
+	}
+	return rl
+}
+
+// redactingLogger masks the sensitive parts of the messages before logging them.
+type redactingLogger struct {
+	lgr    Logger
+	redact Redactor
+}
+
+func (rl redactingLogger) Alert(s string) error   { return rl.lgr.Alert(rl.redact(s)) }
+func (rl redactingLogger) Close() error           { return rl.lgr.Close() }
+func (rl redactingLogger) Crit(s string) error    { return rl.lgr.Crit(rl.redact(s)) }
+func (rl redactingLogger) Debug(s string) error   { return rl.lgr.Debug(rl.redact(s)) }
+func (rl redactingLogger) Emerg(s string) error   { return rl.lgr.Emerg(rl.redact(s)) }
+func (rl redactingLogger) Err(s string) error     { return rl.lgr.Err(rl.redact(s)) }
+func (rl redactingLogger) Info(s string) error    { return rl.lgr.Info(rl.redact(s)) }
+func (rl redactingLogger) Notice(s string) error  { return rl.lgr.Notice(rl.redact(s)) }
+func (rl redactingLogger) Warning(s string) error { return rl.lgr.Warning(rl.redact(s)) }
+
+// redactingFieldLogger is the redactingLogger of a FieldLogger, masking the string fields too.
+type redactingFieldLogger struct {
+	redactingLogger
+}
+
+func (rfl redactingFieldLogger) With(args ...any) Logger {
+	redacted := make([]any, len(args))
+	for i, arg := range args {
+		if str, isStr := arg.(string); isStr && i%2 == 1 {
+			arg = rfl.redact(str)
+		}
+		redacted[i] = arg
+	}
+	return newRedactingLogger(rfl.lgr.(FieldLogger).With(redacted...), rfl.redact)
+}
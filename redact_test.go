@@ -0,0 +1,115 @@
+/*
+redact_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRedactFrame(t *testing.T) {
+	for frame, exp := range map[string]string{
+		"auth ClueCon\n\n":                     "auth ********\n\n",
+		"auth ClueCon":                         "auth ********",
+		"userauth 1000@example.com:secret\n\n": "userauth 1000@example.com:********\n\n",
+		"api status\n\n":                       "api status\n\n",
+	} {
+		if rcv := redactFrame(frame); rcv != exp {
+			t.Errorf("\nExpected: %q, \nReceived: %q", exp, rcv)
+		}
+	}
+}
+
+func TestRedactingLogger(t *testing.T) {
+	redact := func(s string) string { return strings.ReplaceAll(s, "s3cr3t", "***") }
+	lM := new(loggerMock)
+	lgr := newRedactingLogger(lM, redact)
+	if _, canField := lgr.(FieldLogger); canField {
+		t.Error("plain logger turned into a FieldLogger")
+	}
+	ConnInfo{Idx: 1}.scope(lgr).Err("api user_data s3cr3t")
+	if exp := "[conn_idx=1] api user_data ***"; lM.msg != exp {
+		t.Errorf("\nExpected: %q, \nReceived: %q", exp, lM.msg)
+	}
+
+	var buf bytes.Buffer
+	lgr = newRedactingLogger(NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil))), redact)
+	lgr.(FieldLogger).With("cmd", "user_data s3cr3t").Info("sending s3cr3t")
+	if strings.Contains(buf.String(), "s3cr3t") || !strings.Contains(buf.String(), `cmd="user_data ***"`) {
+		t.Errorf("secret not masked: %s", buf.String())
+	}
+}
+
+func TestFSockRedactor(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		rdr := bufio.NewReader(c)
+		if _, err := rdr.ReadString('\n'); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := c.Write([]byte("Content-Type: command/reply\nReply-Text: -ERR no user s3cr3t\n\n")); err != nil {
+			t.Error(err)
+		}
+	})
+	var trace syncBuffer
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithWireTrace(&trace, 0),
+		WithRedactor(func(s string) string { return strings.ReplaceAll(s, "s3cr3t", "***") }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	_, err = fs.SendCmd("api user_exists id s3cr3t example.com\n\n")
+	if err == nil {
+		t.Fatal("expected -ERR reply")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") || !strings.Contains(err.Error(), "no user ***") {
+		t.Errorf("secret not masked in the error: %v", err)
+	}
+	if strings.Contains(trace.String(), "s3cr3t") || strings.Contains(trace.String(), "ClueCon") {
+		t.Errorf("secret not masked in the trace: %s", trace.String())
+	}
+}
+
+func TestFSConnAuthReplyRedacted(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer ln.Close()
+		c, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.Close()
+		if _, err := c.Write([]byte("Content-Type: auth/request\n\n")); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := bufio.NewReader(c).ReadString('\n'); err != nil {
+			t.Error(err)
+			return
+		}
+		c.Write([]byte("Content-Type: command/reply\nReply-Text: -ERR invalid password ClueCon\n\n"))
+	}()
+	lM := new(loggerMock)
+	_, err = NewFSockWithOptions(ln.Addr().String(), "ClueCon", WithLogger(lM))
+	if err == nil {
+		t.Fatal("expected auth failure")
+	}
+	if strings.Contains(err.Error(), "ClueCon") || !strings.Contains(err.Error(), "invalid password ********") {
+		t.Errorf("password not masked in the error: %v", err)
+	}
+	if strings.Contains(lM.msg, "ClueCon") {
+		t.Errorf("password logged: %s", lM.msg)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+registrations.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"time"
+)
+
+// Registration is one SIP registration listed by show registrations.
+type Registration struct {
+	User         string
+	Realm        string
+	Token        string
+	URL          string
+	Expires      time.Time
+	NetworkIP    string
+	NetworkPort  int
+	NetworkProto string
+	Hostname     string
+	Metadata     string
+	Agent        string // user agent, when listed
+}
+
+// ParseRegistrations parses the output of show registrations, either plain or as json.
+// The plain rows which cannot be split are left out.
+func ParseRegistrations(regsStr string) ([]Registration, error) {
+	rows, err := mapListing(regsStr)
+	if err != nil {
+		return nil, err
+	}
+	regs := make([]Registration, 0, len(rows))
+	for _, row := range rows {
+		reg := Registration{
+			User:         row["reg_user"],
+			Realm:        row["realm"],
+			Token:        row["token"],
+			URL:          row["url"],
+			NetworkIP:    row["network_ip"],
+			NetworkProto: row["network_proto"],
+			Hostname:     row["hostname"],
+			Metadata:     row["metadata"],
+			Agent:        row["agent"],
+		}
+		expires, err := atoiEmpty(row["expires"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid expires of registration %s@%s: %v", reg.User, reg.Realm, err)
+		}
+		if expires != 0 {
+			reg.Expires = time.Unix(int64(expires), 0)
+		}
+		if reg.NetworkPort, err = atoiEmpty(row["network_port"]); err != nil {
+			return nil, fmt.Errorf("invalid network_port of registration %s@%s: %v", reg.User, reg.Realm, err)
+		}
+		regs = append(regs, reg)
+	}
+	return regs, nil
+}
@@ -0,0 +1,43 @@
+/*
+registrations_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRegistrations(t *testing.T) {
+	regsStr := `reg_user,realm,token,url,expires,network_ip,network_port,network_proto,hostname,metadata
+1001,192.168.56.120,3c26862f-5bcc-44a1-9e0f-4d2f1d6a3e5c,sofia/internal/sip:1001@192.168.56.1:5081;fs_nat=yes,1703258012,192.168.56.1,5081,udp,debian12,
+1002,192.168.56.120,7a1e0d2c-0a7e-4f8b-9b4a-1f2e3d4c5b6a,sofia/internal/sip:1002@192.168.56.1:5082,1703258020,192.168.56.1,5082,tcp,debian12,
+
+2 total.
+`
+	exp := []Registration{
+		{User: "1001", Realm: "192.168.56.120", Token: "3c26862f-5bcc-44a1-9e0f-4d2f1d6a3e5c",
+			URL: "sofia/internal/sip:1001@192.168.56.1:5081;fs_nat=yes", Expires: time.Unix(1703258012, 0),
+			NetworkIP: "192.168.56.1", NetworkPort: 5081, NetworkProto: "udp", Hostname: "debian12"},
+		{User: "1002", Realm: "192.168.56.120", Token: "7a1e0d2c-0a7e-4f8b-9b4a-1f2e3d4c5b6a",
+			URL: "sofia/internal/sip:1002@192.168.56.1:5082", Expires: time.Unix(1703258020, 0),
+			NetworkIP: "192.168.56.1", NetworkPort: 5082, NetworkProto: "tcp", Hostname: "debian12"},
+	}
+	if rcv, err := ParseRegistrations(regsStr); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+	if rcv, err := ParseRegistrations(`{"row_count":1,"rows":[{"reg_user":"1001","realm":"192.168.56.120","network_port":"5081","expires":"1703258012","agent":"Zoiper"}]}`); err != nil {
+		t.Error(err)
+	} else if len(rcv) != 1 || rcv[0].NetworkPort != 5081 || rcv[0].Agent != "Zoiper" {
+		t.Errorf("unexpected registrations: %+v", rcv)
+	}
+	if _, err := ParseRegistrations(`{"rows":[{"reg_user":"1001","expires":"never"}]}`); err == nil {
+		t.Error("expected error")
+	}
+}
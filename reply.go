@@ -0,0 +1,77 @@
+/*
+reply.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import "strings"
+
+// Reply is the reply of FreeSWITCH to a command, Reply-Text for command/reply
+// or the body for api/response.
+type Reply struct {
+	OK      bool   // false for -ERR and -USAGE replies
+	Text    string // reply without the +OK/-ERR/-USAGE marker
+	JobUUID string // Job-UUID of the bgapi replies
+	Raw     string // reply as received
+}
+
+// NewReply parses the raw reply received from FreeSWITCH.
+func NewReply(raw string) Reply {
+	rply := Reply{
+		OK:   true,
+		Text: strings.TrimSpace(raw),
+		Raw:  raw,
+	}
+	for _, marker := range []string{"-ERR", "-USAGE"} {
+		if txt, has := strings.CutPrefix(rply.Text, marker); has {
+			rply.OK = false
+			rply.Text = strings.TrimSpace(txt)
+			return rply
+		}
+	}
+	if txt, has := strings.CutPrefix(rply.Text, "+OK"); has {
+		rply.Text = strings.TrimSpace(txt)
+	}
+	if jobUUID, has := strings.CutPrefix(rply.Text, "Job-UUID:"); has {
+		rply.JobUUID = strings.TrimSpace(jobUUID)
+	}
+	return rply
+}
+
+// APIError is returned for the -ERR replies, so the callers can branch on the FreeSWITCH
+// error through errors.As, e.g. on Code being NO_ANSWER rather than SUBSCRIBER_ABSENT.
+type APIError struct {
+	Command string // command which failed, without the line breaks ending it
+	Code    string // first word following -ERR, e.g. NO_ANSWER, empty if none
+	Text    string // reply as received, trimmed
+}
+
+// newAPIError builds the APIError for the -ERR reply to cmd.
+func newAPIError(cmd, reply string) *APIError {
+	apiErr := &APIError{
+		Command: strings.TrimRight(cmd, "\r\n"),
+		Text:    strings.TrimSpace(reply),
+	}
+	if _, txt, has := strings.Cut(apiErr.Text, "-ERR"); has {
+		if fields := strings.Fields(txt); len(fields) != 0 {
+			apiErr.Code = fields[0]
+		}
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string { return e.Text }
+
+// Is matches ErrNoSuchChannel for the replies of the uuid_* and myevents commands sent
+// for a channel which does not exist, or no longer.
+func (e *APIError) Is(target error) bool {
+	if target != ErrNoSuchChannel {
+		return false
+	}
+	txt := strings.ToLower(e.Text)
+	return strings.Contains(txt, "no such channel") || strings.Contains(txt, "invalid uuid")
+}
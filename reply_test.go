@@ -0,0 +1,97 @@
+/*
+reply_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestReplyNewReply(t *testing.T) {
+	for _, tc := range []struct {
+		raw string
+		exp Reply
+	}{
+		{
+			raw: "+OK accepted",
+			exp: Reply{OK: true, Text: "accepted", Raw: "+OK accepted"},
+		},
+		{
+			raw: "+OK Job-UUID: 7f4db78a-17d7-11dd-b7a0-db4edd065621",
+			exp: Reply{OK: true, Text: "Job-UUID: 7f4db78a-17d7-11dd-b7a0-db4edd065621",
+				JobUUID: "7f4db78a-17d7-11dd-b7a0-db4edd065621",
+				Raw:     "+OK Job-UUID: 7f4db78a-17d7-11dd-b7a0-db4edd065621"},
+		},
+		{
+			raw: "-ERR invalid command\n",
+			exp: Reply{Text: "invalid command", Raw: "-ERR invalid command\n"},
+		},
+		{
+			raw: "-USAGE: <uuid>\n",
+			exp: Reply{Text: ": <uuid>", Raw: "-USAGE: <uuid>\n"},
+		},
+		{
+			raw: "UP 0 years, 0 days, 1 hour\n",
+			exp: Reply{OK: true, Text: "UP 0 years, 0 days, 1 hour", Raw: "UP 0 years, 0 days, 1 hour\n"},
+		},
+	} {
+		if rcv := NewReply(tc.raw); !reflect.DeepEqual(rcv, tc.exp) {
+			t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", tc.exp, rcv)
+		}
+	}
+}
+
+func TestReplySendCmdReply(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		buf := make([]byte, 512)
+		if _, err := c.Read(buf); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := c.Write([]byte("Content-Type: command/reply\nReply-Text: -ERR invalid command\n\n")); err != nil {
+			t.Error(err)
+		}
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	rply, err := fs.SendCmdReply("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rply.OK || rply.Text != "invalid command" {
+		t.Errorf("unexpected reply: %+v", rply)
+	}
+}
+
+func TestFSConnSendAPIError(t *testing.T) {
+	fs := &FSConn{
+		lgr:  nopLogger{},
+		conn: &connMock3{},
+	}
+	go replyWhenAwaited(fs, "-ERR NO_ANSWER\n")
+	_, err := fs.Send("api originate user/1001 &park\n\n")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, received: %v", err)
+	}
+	exp := &APIError{
+		Command: "api originate user/1001 &park",
+		Code:    "NO_ANSWER",
+		Text:    "-ERR NO_ANSWER",
+	}
+	if !reflect.DeepEqual(apiErr, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, apiErr)
+	}
+	if err.Error() != exp.Text {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp.Text, err.Error())
+	}
+}
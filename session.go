@@ -0,0 +1,213 @@
+/*
+session.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CallerProfile is the caller profile of a channel, out of its Caller-* headers.
+type CallerProfile struct {
+	Username           string
+	Dialplan           string
+	CallerIDName       string
+	CallerIDNumber     string
+	OrigCallerIDName   string
+	OrigCallerIDNumber string
+	CalleeIDName       string
+	CalleeIDNumber     string
+	NetworkAddr        string
+	ANI                string
+	ANIII              string
+	DestinationNumber  string
+	UniqueID           string
+	Source             string // e.g. mod_sofia
+	Context            string
+	RDNIS              string
+	ChannelName        string
+	ProfileIndex       string
+	ScreenBit          bool
+	PrivacyHideName    bool
+	PrivacyHideNumber  bool
+	ProfileCreatedTime time.Time
+	CreatedTime        time.Time
+	AnsweredTime       time.Time // zero for the states not reached, as the ones below
+	ProgressTime       time.Time
+	ProgressMediaTime  time.Time
+	HangupTime         time.Time
+	TransferTime       time.Time
+	ResurrectTime      time.Time
+	BridgedTime        time.Time
+	LastHold           time.Time
+	HoldAccum          time.Duration
+}
+
+// ChannelData is the channel an outbound session is bound to, as described by the reply
+// to connect.
+type ChannelData struct {
+	UUID        string
+	Name        string // e.g. sofia/internal/1001@192.168.56.120
+	State       string // e.g. CS_EXECUTE
+	CallState   string // e.g. RINGING or ACTIVE
+	CallUUID    string
+	Direction   string // inbound or outbound
+	AnswerState string // e.g. ringing or answered
+	ReadCodec   string
+	ReadRate    int
+	WriteCodec  string
+	WriteRate   int
+	Caller      CallerProfile
+	Variables   map[string]string // the channel variables, without the variable_ prefix
+	Headers     map[string]string // all the headers received, as returned by ChannelData
+}
+
+// NewChannelData decodes the channel headers and variables, e.g. the ones received as
+// reply to connect.
+func NewChannelData(hdrs map[string]string) ChannelData {
+	chData := ChannelData{
+		UUID:        hdrs["Unique-ID"],
+		Name:        hdrs["Channel-Name"],
+		State:       hdrs["Channel-State"],
+		CallState:   hdrs["Channel-Call-State"],
+		CallUUID:    hdrs["Channel-Call-UUID"],
+		Direction:   hdrs["Call-Direction"],
+		AnswerState: hdrs["Answer-State"],
+		ReadCodec:   hdrs["Channel-Read-Codec-Name"],
+		WriteCodec:  hdrs["Channel-Write-Codec-Name"],
+		Caller: CallerProfile{
+			Username:           hdrs["Caller-Username"],
+			Dialplan:           hdrs["Caller-Dialplan"],
+			CallerIDName:       hdrs["Caller-Caller-ID-Name"],
+			CallerIDNumber:     hdrs["Caller-Caller-ID-Number"],
+			OrigCallerIDName:   hdrs["Caller-Orig-Caller-ID-Name"],
+			OrigCallerIDNumber: hdrs["Caller-Orig-Caller-ID-Number"],
+			CalleeIDName:       hdrs["Caller-Callee-ID-Name"],
+			CalleeIDNumber:     hdrs["Caller-Callee-ID-Number"],
+			NetworkAddr:        hdrs["Caller-Network-Addr"],
+			ANI:                hdrs["Caller-ANI"],
+			ANIII:              hdrs["Caller-ANI-II"],
+			DestinationNumber:  hdrs["Caller-Destination-Number"],
+			UniqueID:           hdrs["Caller-Unique-ID"],
+			Source:             hdrs["Caller-Source"],
+			Context:            hdrs["Caller-Context"],
+			RDNIS:              hdrs["Caller-RDNIS"],
+			ChannelName:        hdrs["Caller-Channel-Name"],
+			ProfileIndex:       hdrs["Caller-Profile-Index"],
+			ScreenBit:          hdrs["Caller-Screen-Bit"] == "true",
+			PrivacyHideName:    hdrs["Caller-Privacy-Hide-Name"] == "true",
+			PrivacyHideNumber:  hdrs["Caller-Privacy-Hide-Number"] == "true",
+			ProfileCreatedTime: channelTime(hdrs["Caller-Profile-Created-Time"]),
+			CreatedTime:        channelTime(hdrs["Caller-Channel-Created-Time"]),
+			AnsweredTime:       channelTime(hdrs["Caller-Channel-Answered-Time"]),
+			ProgressTime:       channelTime(hdrs["Caller-Channel-Progress-Time"]),
+			ProgressMediaTime:  channelTime(hdrs["Caller-Channel-Progress-Media-Time"]),
+			HangupTime:         channelTime(hdrs["Caller-Channel-Hangup-Time"]),
+			TransferTime:       channelTime(hdrs["Caller-Channel-Transfer-Time"]),
+			ResurrectTime:      channelTime(hdrs["Caller-Channel-Resurrect-Time"]),
+			BridgedTime:        channelTime(hdrs["Caller-Channel-Bridged-Time"]),
+			LastHold:           channelTime(hdrs["Caller-Channel-Last-Hold"]),
+		},
+		Variables: make(map[string]string),
+		Headers:   hdrs,
+	}
+	chData.ReadRate, _ = strconv.Atoi(hdrs["Channel-Read-Codec-Rate"])
+	chData.WriteRate, _ = strconv.Atoi(hdrs["Channel-Write-Codec-Rate"])
+	if holdAccum, err := strconv.ParseInt(hdrs["Caller-Channel-Hold-Accum"], 10, 64); err == nil {
+		chData.Caller.HoldAccum = time.Duration(holdAccum) * time.Microsecond
+	}
+	for hdr, val := range hdrs {
+		if name, isVar := strings.CutPrefix(hdr, "variable_"); isVar {
+			chData.Variables[name] = val
+		}
+	}
+	return chData
+}
+
+// Channel returns the channel the session is bound to, decoded out of ChannelData.
+func (sess *Session) Channel() ChannelData {
+	return sess.channel
+}
+
+// SubscribeEvents subscribes through myevents to the events of the session channel,
+// delivering them to handler. Execute subscribes on its own if not done before.
+func (sess *Session) SubscribeEvents(handler func(string, int)) error {
+	return sess.subscribeMyEvents(sess.UUID(), handler)
+}
+
+// Execute executes the dialplan application app with args on the session channel and
+// waits for it to complete. Sent with event-lock, the applications run in the order sent.
+func (sess *Session) Execute(ctx context.Context, app, args string) (AppResult, error) {
+	if err := sess.ensureEvents(); err != nil {
+		return AppResult{}, err
+	}
+	return sess.executeApp(ctx, sess.UUID(), app, args, true)
+}
+
+// ExecuteAsync queues the dialplan application app with args on the session channel,
+// with event-lock, returning once FreeSWITCH accepted it, without waiting for it to complete.
+func (sess *Session) ExecuteAsync(app, args string) error {
+	msg, err := executeMsg(sess.UUID(), app, args, genUUID(), true)
+	if err != nil {
+		return err
+	}
+	_, err = sess.Send(msg)
+	return err
+}
+
+// Answer answers the session channel.
+func (sess *Session) Answer(ctx context.Context) error {
+	_, err := sess.Execute(ctx, "answer", "")
+	return err
+}
+
+// Set sets the channel variable name to value.
+func (sess *Session) Set(ctx context.Context, name, value string) error {
+	if name == "" || strings.ContainsAny(name, "= \t") {
+		return fmt.Errorf("%w: variable name %q", ErrUnsafeArg, name)
+	}
+	_, err := sess.Execute(ctx, "set", name+"="+value)
+	return err
+}
+
+// Hangup hangs up the session channel with cause, e.g. NORMAL_CLEARING, the default if empty.
+func (sess *Session) Hangup(cause string) error {
+	if cause == "" {
+		cause = "NORMAL_CLEARING"
+	}
+	for _, arg := range []string{sess.UUID(), cause} {
+		if err := CheckArg(arg); err != nil {
+			return err
+		}
+	}
+	_, err := sess.Send("sendmsg " + sess.UUID() + "\ncall-command: hangup\nhangup-cause: " + cause + "\n\n")
+	return err
+}
+
+// Resume sends resume, so the dialplan continues with the applications following the
+// socket application once the session ends, instead of hanging up the channel.
+func (sess *Session) Resume() error {
+	_, err := sess.Send("resume\n\n")
+	return err
+}
+
+// ensureEvents subscribes to the channel events, needed to learn about the applications
+// completed, unless already subscribed.
+func (sess *Session) ensureEvents() error {
+	sess.handlersMux.RLock()
+	subscribed := sess.myEventsHandler != nil
+	sess.handlersMux.RUnlock()
+	if subscribed {
+		return nil
+	}
+	return sess.SubscribeEvents(func(string, int) {})
+}
@@ -0,0 +1,224 @@
+/*
+session_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveOutbound accepts the outbound sessions on a loopback listener, handing them to handler.
+func serveOutbound(t *testing.T, handler func(*Session)) string {
+	t.Helper()
+	srv := NewFSockServer("127.0.0.1:0", handler, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+// readOutboundCmd reads one command sent by the session, its headers included.
+func readOutboundCmd(rdr *bufio.Reader) (string, error) {
+	var cmd strings.Builder
+	for {
+		line, err := rdr.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == "\n" {
+			return strings.TrimSuffix(cmd.String(), "\n"), nil
+		}
+		cmd.WriteString(line)
+	}
+}
+
+func TestSessionCommands(t *testing.T) {
+	const uuid = "4967ceb1"
+	type result struct {
+		answerErr, setErr, asyncErr, hangupErr error
+		events                                 []string
+	}
+	results := make(chan result, 1)
+	addr := serveOutbound(t, func(sess *Session) {
+		var res result
+		events := make(chan string, 1)
+		if err := sess.SubscribeEvents(func(event string, _ int) {
+			if name := headerVal(event, "Event-Name"); name != "CHANNEL_EXECUTE_COMPLETE" {
+				events <- name
+			}
+		}); err != nil {
+			t.Error(err)
+		}
+		res.answerErr = sess.Answer(context.Background())
+		select {
+		case name := <-events:
+			res.events = append(res.events, name)
+		case <-time.After(time.Second):
+		}
+		res.setErr = sess.Set(context.Background(), "foo", "bar baz")
+		res.asyncErr = sess.ExecuteAsync("playback", "tone_stream://%(100,0,440)")
+		res.hangupErr = sess.Hangup("")
+		results <- res
+	})
+	conn, rdr := dialOutbound(t, addr,
+		"Content-Type: command/reply\nReply-Text: +OK\nUnique-ID: "+uuid+"\n\n")
+	defer conn.Close()
+
+	var cmds []string
+	for range 5 {
+		cmd, err := readOutboundCmd(rdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appUUID := headerVal(cmd, "Event-UUID")
+		if appUUID != "" {
+			cmd = strings.Replace(cmd, appUUID, "<app_uuid>", 1)
+		}
+		cmds = append(cmds, cmd)
+		if _, err := conn.Write([]byte("Content-Type: command/reply\nReply-Text: +OK\n\n")); err != nil {
+			t.Fatal(err)
+		}
+		app := headerVal(cmd, "execute-app-name")
+		if app != "answer" && app != "set" {
+			continue
+		}
+		var events []string
+		if app == "answer" {
+			events = append(events, "Event-Name: CHANNEL_ANSWER\nUnique-ID: "+uuid+"\n\n")
+		}
+		events = append(events, fmt.Sprintf(
+			"Event-Name: CHANNEL_EXECUTE_COMPLETE\nUnique-ID: %s\nApplication: %s\nApplication-UUID: %s\n\n",
+			uuid, app, appUUID))
+		for _, event := range events {
+			if _, err := fmt.Fprintf(conn, "Content-Length: %d\nContent-Type: text/event-plain\n\n%s",
+				len(event), event); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var res result
+	select {
+	case res = <-results:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return")
+	}
+	for _, err := range []error{res.answerErr, res.setErr, res.asyncErr, res.hangupErr} {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if exp := []string{"CHANNEL_ANSWER"}; !reflect.DeepEqual(exp, res.events) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, res.events)
+	}
+	exp := []string{
+		"myevents " + uuid,
+		"sendmsg " + uuid + "\ncall-command: execute\nexecute-app-name: answer\nexecute-app-arg: \nEvent-UUID: <app_uuid>\nevent-lock: true",
+		"sendmsg " + uuid + "\ncall-command: execute\nexecute-app-name: set\nexecute-app-arg: foo=bar baz\nEvent-UUID: <app_uuid>\nevent-lock: true",
+		"sendmsg " + uuid + "\ncall-command: execute\nexecute-app-name: playback\nexecute-app-arg: tone_stream://%(100,0,440)\nEvent-UUID: <app_uuid>\nevent-lock: true",
+		"sendmsg " + uuid + "\ncall-command: hangup\nhangup-cause: NORMAL_CLEARING",
+	}
+	if !reflect.DeepEqual(exp, cmds) {
+		t.Errorf("\nExpected: <%q>, \nReceived: <%q>", exp, cmds)
+	}
+}
+
+func TestSessionSetUnsafeName(t *testing.T) {
+	sess := &Session{FSConn: new(FSConn), channelData: map[string]string{"Unique-ID": "4967ceb1"}}
+	if err := sess.Set(context.Background(), "foo=bar", "baz"); err == nil {
+		t.Error("expected the variable name rejected")
+	}
+}
+
+func TestNewChannelData(t *testing.T) {
+	rply := `Content-Type: command/reply
+Reply-Text: +OK
+Unique-ID: 4967ceb1
+Channel-Name: sofia/internal/1001%40192.168.56.120
+Channel-State: CS_EXECUTE
+Channel-Call-State: RINGING
+Channel-Call-UUID: 4967ceb1
+Call-Direction: inbound
+Answer-State: ringing
+Channel-Read-Codec-Name: PCMU
+Channel-Read-Codec-Rate: 8000
+Channel-Write-Codec-Name: PCMU
+Channel-Write-Codec-Rate: 8000
+Caller-Caller-ID-Name: Alice
+Caller-Caller-ID-Number: 1001
+Caller-Network-Addr: 192.168.56.120
+Caller-Destination-Number: 1002
+Caller-Context: default
+Caller-Source: mod_sofia
+Caller-Privacy-Hide-Name: false
+Caller-Screen-Bit: true
+Caller-Channel-Created-Time: 1700000000000000
+Caller-Channel-Answered-Time: 0
+Caller-Channel-Hold-Accum: 1500000
+variable_sip_from_user: 1001
+variable_foo: bar%20baz
+
+`
+	chData := NewChannelData(FSEventStrToMap(rply, nil))
+	if chData.UUID != "4967ceb1" || chData.Name != "sofia/internal/1001@192.168.56.120" ||
+		chData.State != "CS_EXECUTE" || chData.CallState != "RINGING" || chData.Direction != "inbound" ||
+		chData.AnswerState != "ringing" || chData.ReadCodec != "PCMU" || chData.ReadRate != 8000 ||
+		chData.WriteRate != 8000 {
+		t.Errorf("unexpected channel: %+v", chData)
+	}
+	expCaller := CallerProfile{
+		CallerIDName:      "Alice",
+		CallerIDNumber:    "1001",
+		NetworkAddr:       "192.168.56.120",
+		DestinationNumber: "1002",
+		Context:           "default",
+		Source:            "mod_sofia",
+		ScreenBit:         true,
+		CreatedTime:       time.UnixMicro(1700000000000000),
+		HoldAccum:         1500 * time.Millisecond,
+	}
+	if !reflect.DeepEqual(expCaller, chData.Caller) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", expCaller, chData.Caller)
+	}
+	if exp := map[string]string{"sip_from_user": "1001", "foo": "bar baz"}; !reflect.DeepEqual(exp, chData.Variables) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, chData.Variables)
+	}
+}
+
+func TestSessionResume(t *testing.T) {
+	resumed := make(chan error, 1)
+	addr := serveOutbound(t, func(sess *Session) {
+		resumed <- sess.Resume()
+	})
+	conn, rdr := dialOutbound(t, addr, "Content-Type: command/reply\nReply-Text: +OK\nUnique-ID: 4967ceb1\n\n")
+	defer conn.Close()
+	if cmd, err := readOutboundCmd(rdr); err != nil {
+		t.Fatal(err)
+	} else if cmd != "resume" {
+		t.Errorf("\nExpected: %q, \nReceived: %q", "resume", cmd)
+	}
+	if _, err := conn.Write([]byte("Content-Type: command/reply\nReply-Text: +OK\n\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-resumed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return")
+	}
+}
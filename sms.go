@@ -0,0 +1,83 @@
+/*
+sms.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"maps"
+	"strconv"
+)
+
+// Message is a mod_sms message, sent with SendSMS or received within the MESSAGE events.
+type Message struct {
+	Proto       string // e.g. sip, sip if empty when sending
+	From        string // e.g. 1001@example.com
+	To          string // e.g. 1002@example.com
+	Subject     string
+	ContentType string // text/plain if empty when sending
+	Body        string
+	Headers     map[string]string // other headers, e.g. sip_profile or dest_proto
+}
+
+// SendSMS sends the message through the SMS::SEND_MESSAGE event, delivered by mod_sms
+// as its chatplan directs.
+func (fs *FSock) SendSMS(msg Message) error {
+	hdrs := maps.Clone(msg.Headers)
+	if hdrs == nil {
+		hdrs = make(map[string]string)
+	}
+	for name, val := range map[string]string{
+		"proto":   msg.Proto,
+		"from":    msg.From,
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"type":    msg.ContentType,
+	} {
+		if val != "" {
+			hdrs[name] = val
+		}
+	}
+	for name, dflt := range map[string]string{
+		"proto":      "sip",
+		"dest_proto": "sip",
+		"type":       "text/plain",
+	} {
+		if hdrs[name] == "" {
+			hdrs[name] = dflt
+		}
+	}
+	hdrs["Event-Subclass"] = "SMS::SEND_MESSAGE"
+	hdrs["Content-Length"] = strconv.Itoa(len(msg.Body))
+	_, err := fs.SendCmdWithArgs("sendevent CUSTOM\n", hdrs, msg.Body)
+	return err
+}
+
+// NewMessage decodes the MESSAGE event, or the SMS::SEND_MESSAGE one, into a Message.
+func NewMessage(ev Event) Message {
+	msg := Message{
+		Proto:       ev.GetHeader("proto"),
+		From:        ev.GetHeader("from"),
+		To:          ev.GetHeader("to"),
+		Subject:     ev.GetHeader("subject"),
+		ContentType: ev.GetHeader("type"),
+		Body:        ev.Body,
+		Headers:     maps.Clone(ev.Headers),
+	}
+	for _, name := range []string{"proto", "from", "to", "subject", "type", "Content-Length"} {
+		delete(msg.Headers, name)
+	}
+	return msg
+}
+
+// MessageHandler adapts a handler of messages to the plain handlers signature, to be
+// registered for the MESSAGE events.
+func MessageHandler(handler func(Message, int)) func(string, int) {
+	return EventHandler(func(ev Event, connIdx int) {
+		handler(NewMessage(ev), connIdx)
+	})
+}
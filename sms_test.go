@@ -0,0 +1,70 @@
+/*
+sms_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestFSockSendSMS(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if err = fs.SendSMS(Message{
+		From:    "1001@example.com",
+		To:      "1002@example.com",
+		Body:    "hello",
+		Headers: map[string]string{"sip_profile": "internal"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := srv.WaitCommand("sendevent CUSTOM", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hdr, exp := range map[string]string{
+		"Event-Subclass": "SMS::SEND_MESSAGE",
+		"proto":          "sip",
+		"dest_proto":     "sip",
+		"from":           "1001@example.com",
+		"to":             "1002@example.com",
+		"type":           "text/plain",
+		"sip_profile":    "internal",
+		"Content-Length": "5",
+	} {
+		if val := HeaderValue(cmd, hdr); val != exp {
+			t.Errorf("header %s, expected: %q, received: %q", hdr, exp, val)
+		}
+	}
+	if _, has := lookupHeader(cmd, "subject"); has {
+		t.Error("unexpected subject header")
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	ev := NewEvent("Event-Name: MESSAGE\nproto: sip\nfrom: 1001%40example.com\nto: 1002%40example.com\n" +
+		"subject: SIMPLE%20MESSAGE\ntype: text/plain\nsip_profile: internal\nContent-Length: 5\n\nhello")
+	exp := Message{
+		Proto:       "sip",
+		From:        "1001@example.com",
+		To:          "1002@example.com",
+		Subject:     "SIMPLE MESSAGE",
+		ContentType: "text/plain",
+		Body:        "hello",
+		Headers:     map[string]string{"Event-Name": "MESSAGE", "sip_profile": "internal"},
+	}
+	if rcv := NewMessage(ev); !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+sofia.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SofiaEntry is one profile, gateway or alias listed by sofia status.
+type SofiaEntry struct {
+	Name  string // e.g. internal, or external::gw1 for the gateways
+	Type  string // profile, gateway or alias
+	Data  string // SIP URI, the profile for the aliases
+	State string // e.g. RUNNING for the profiles, REGED, NOREG or FAIL_WAIT for the gateways
+	Calls int    // active calls of the profiles
+}
+
+// SofiaGateway is the state of one gateway as reported by sofia status gateway.
+type SofiaGateway struct {
+	Name           string
+	Profile        string
+	Scheme         string
+	Realm          string
+	Username       string
+	From           string
+	Contact        string
+	Exten          string
+	To             string
+	Proxy          string
+	Context        string
+	Expires        int
+	Freq           int
+	PingFreq       int
+	PingTime       time.Duration // last OPTIONS ping round trip
+	PingState      string
+	State          string // registration state, e.g. REGED, FAILED or NOREG
+	Status         string // UP or DOWN, as detected by the pings
+	Uptime         time.Duration
+	CallsIn        int
+	CallsOut       int
+	FailedCallsIn  int
+	FailedCallsOut int
+	Fields         map[string]string // all the fields as listed, the unknown ones included
+}
+
+// Up tells if the gateway is usable: reachable, and registered if registering.
+func (gw SofiaGateway) Up() bool {
+	return gw.Status == "UP" && (gw.State == "REGED" || gw.State == "NOREG")
+}
+
+// ParseSofiaStatus parses the output of sofia status.
+func ParseSofiaStatus(sofiaStatus string) (entries []SofiaEntry, err error) {
+	for _, line := range strings.Split(sofiaStatus, "\n") {
+		flds := strings.Split(line, "\t")
+		if len(flds) != 4 || strings.TrimSpace(flds[0]) == "Name" {
+			continue // separators, header and totals
+		}
+		entry := SofiaEntry{
+			Name:  strings.TrimSpace(flds[0]),
+			Type:  strings.TrimSpace(flds[1]),
+			Data:  strings.TrimSpace(flds[2]),
+			State: strings.TrimSpace(flds[3]),
+		}
+		if state, calls, has := strings.Cut(entry.State, " ("); has {
+			entry.State = state
+			if entry.Calls, err = strconv.Atoi(strings.TrimSuffix(calls, ")")); err != nil {
+				return nil, fmt.Errorf("unexpected sofia status line received: <%s>", line)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// ParseSofiaGateway parses the output of sofia status gateway.
+func ParseSofiaGateway(gwStatus string) (gw SofiaGateway, err error) {
+	gw.Fields = make(map[string]string)
+	for _, line := range strings.Split(gwStatus, "\n") {
+		name, val, has := strings.Cut(line, "\t")
+		if !has {
+			continue // separators
+		}
+		gw.Fields[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	}
+	if gw.Name = gw.Fields["Name"]; gw.Name == "" {
+		return SofiaGateway{}, fmt.Errorf("unexpected gateway status received: <%s>", strings.TrimSpace(gwStatus))
+	}
+	gw.Profile = gw.Fields["Profile"]
+	gw.Scheme = gw.Fields["Scheme"]
+	gw.Realm = gw.Fields["Realm"]
+	gw.Username = gw.Fields["Username"]
+	gw.From = gw.Fields["From"]
+	gw.Contact = gw.Fields["Contact"]
+	gw.Exten = gw.Fields["Exten"]
+	gw.To = gw.Fields["To"]
+	gw.Proxy = gw.Fields["Proxy"]
+	gw.Context = gw.Fields["Context"]
+	gw.PingState = gw.Fields["PingState"]
+	gw.State = gw.Fields["State"]
+	gw.Status = gw.Fields["Status"]
+	for fld, val := range map[string]*int{
+		"Expires":        &gw.Expires,
+		"Freq":           &gw.Freq,
+		"PingFreq":       &gw.PingFreq,
+		"CallsIN":        &gw.CallsIn,
+		"CallsOUT":       &gw.CallsOut,
+		"FailedCallsIN":  &gw.FailedCallsIn,
+		"FailedCallsOUT": &gw.FailedCallsOut,
+	} {
+		if *val, err = atoiEmpty(gw.Fields[fld]); err != nil {
+			return SofiaGateway{}, fmt.Errorf("invalid %s of gateway %s: %v", fld, gw.Name, err)
+		}
+	}
+	if pingTime := gw.Fields["PingTime"]; pingTime != "" {
+		ms, err := strconv.ParseFloat(pingTime, 64)
+		if err != nil {
+			return SofiaGateway{}, fmt.Errorf("invalid PingTime of gateway %s: %v", gw.Name, err)
+		}
+		gw.PingTime = time.Duration(ms * float64(time.Millisecond))
+	}
+	if uptime := gw.Fields["Uptime"]; uptime != "" {
+		secs, err := strconv.Atoi(strings.TrimSuffix(uptime, "s"))
+		if err != nil {
+			return SofiaGateway{}, fmt.Errorf("invalid Uptime of gateway %s: %v", gw.Name, err)
+		}
+		gw.Uptime = time.Duration(secs) * time.Second
+	}
+	return
+}
+
+// SofiaStatus runs sofia status, returning the profiles, gateways and aliases listed.
+func (fs *FSock) SofiaStatus() ([]SofiaEntry, error) {
+	rply, err := fs.SendApiCmd("sofia status")
+	if err != nil {
+		return nil, err
+	}
+	return ParseSofiaStatus(rply)
+}
+
+// SofiaGatewayStatus runs sofia status gateway for the gateway named gwName.
+func (fs *FSock) SofiaGatewayStatus(gwName string) (SofiaGateway, error) {
+	cmd, err := ApiCmd("sofia status gateway", gwName)
+	if err != nil {
+		return SofiaGateway{}, err
+	}
+	rply, err := fs.SendApiCmd(cmd)
+	if err != nil {
+		return SofiaGateway{}, err
+	}
+	return ParseSofiaGateway(rply)
+}
@@ -0,0 +1,83 @@
+/*
+sofia_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+const (
+	testSofiaStatus = "                     Name\t   Type\t                                      Data\tState\n" +
+		"=================================================================================================\n" +
+		"                 internal\tprofile\t           sip:mod_sofia@192.168.56.120:5060\tRUNNING (2)\n" +
+		"            external::gw1\tgateway\t                   sip:1001@sip.example.com\tREGED\n" +
+		"           192.168.56.120\t  alias\t                                  internal\tALIASED\n" +
+		"=================================================================================================\n" +
+		"1 profile 1 alias\n"
+	testSofiaGateway = "=================================================================================================\n" +
+		"Name    \tgw1\nProfile \texternal\nScheme  \tDigest\nRealm   \tsip.example.com\nUsername\t1001\n" +
+		"Password\tyes\nFrom    \t<sip:1001@sip.example.com>\nContact \t<sip:gw+gw1@192.168.56.120:5080;transport=udp;gw=gw1>\n" +
+		"Exten   \t1001\nTo      \tsip:1001@sip.example.com\nProxy   \tsip:sip.example.com\nContext \tpublic\n" +
+		"Expires \t3600\nFreq    \t3600\nPing    \t1703257952\nPingFreq\t30\nPingMin \t3\nPingCount\t3\nPingMax \t3\n" +
+		"PingTime\t12.50\nPingState\t3/3/3\nState   \tREGED\nStatus  \tUP\nUptime  \t7200s\n" +
+		"CallsIN \t3\nCallsOUT\t5\nFailedCallsIN\t0\nFailedCallsOUT\t1\n" +
+		"=================================================================================================\n"
+)
+
+func TestParseSofiaStatus(t *testing.T) {
+	exp := []SofiaEntry{
+		{Name: "internal", Type: "profile", Data: "sip:mod_sofia@192.168.56.120:5060", State: "RUNNING", Calls: 2},
+		{Name: "external::gw1", Type: "gateway", Data: "sip:1001@sip.example.com", State: "REGED"},
+		{Name: "192.168.56.120", Type: "alias", Data: "internal", State: "ALIASED"},
+	}
+	if rcv, err := ParseSofiaStatus(testSofiaStatus); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+}
+
+func TestParseSofiaGateway(t *testing.T) {
+	gw, err := ParseSofiaGateway(testSofiaGateway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.Name != "gw1" || gw.Profile != "external" || gw.State != "REGED" || gw.Status != "UP" ||
+		gw.Expires != 3600 || gw.PingFreq != 30 || gw.PingTime != 12500*time.Microsecond ||
+		gw.Uptime != 2*time.Hour || gw.CallsIn != 3 || gw.CallsOut != 5 || gw.FailedCallsOut != 1 ||
+		gw.PingState != "3/3/3" || gw.Fields["PingCount"] != "3" || !gw.Up() {
+		t.Errorf("unexpected gateway: %+v", gw)
+	}
+	if _, err := ParseSofiaGateway("Invalid Gateway!\n"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestFSockSofiaStatus(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	srv.Stub("api sofia status", testSofiaStatus)
+	srv.Stub("api sofia status gateway gw1", testSofiaGateway)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	if entries, err := fs.SofiaStatus(); err != nil {
+		t.Error(err)
+	} else if len(entries) != 3 {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+	if gw, err := fs.SofiaGatewayStatus("gw1"); err != nil {
+		t.Error(err)
+	} else if gw.Name != "gw1" {
+		t.Errorf("unexpected gateway: %+v", gw)
+	}
+}
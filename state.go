@@ -0,0 +1,69 @@
+/*
+state.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// ConnState is the state of the connection of a FSock, see State.
+type ConnState int32
+
+// The states the connection goes through.
+const (
+	StateDisconnected ConnState = iota // not connected, e.g. out of reconnects, nor trying to
+	StateConnecting                    // connecting on Connect
+	StateConnected                     // connected and authenticated
+	StateReconnecting                  // the connection was lost, reconnecting
+	StateClosed                        // closed through Disconnect or Shutdown
+)
+
+var connStateNames = map[ConnState]string{
+	StateDisconnected: "disconnected",
+	StateConnecting:   "connecting",
+	StateConnected:    "connected",
+	StateReconnecting: "reconnecting",
+	StateClosed:       "closed",
+}
+
+func (s ConnState) String() string {
+	if name, has := connStateNames[s]; has {
+		return name
+	}
+	return "ConnState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// State returns the state of the connection. Lock free, it can be called from the handlers.
+func (fs *FSock) State() ConnState {
+	return ConnState(fs.state.Load())
+}
+
+// setState moves the connection to the state to, only if in one of the states from when
+// given, telling if it did. All the state changes go through it.
+func (fs *FSock) setState(to ConnState, from ...ConnState) bool {
+	for {
+		cur := ConnState(fs.state.Load())
+		if len(from) != 0 && !slices.Contains(from, cur) {
+			return false
+		}
+		if cur == to {
+			return true
+		}
+		if !fs.state.CompareAndSwap(int32(cur), int32(to)) {
+			continue
+		}
+		if fs.getLogger() != nil { // not set on the FSocks built directly
+			fs.log().Debug(fmt.Sprintf("<FSock> Connection %s, was %s (connection index: %d)",
+				to, cur, fs.connIdx))
+		}
+		return true
+	}
+}
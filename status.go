@@ -0,0 +1,113 @@
+/*
+status.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the state of FreeSWITCH as reported by api status.
+type Status struct {
+	Up                     bool
+	Uptime                 time.Duration
+	Version                string // e.g. 1.10.9-release 64bit
+	Ready                  bool
+	SessionsSinceStartup   int
+	Sessions               int
+	SessionsPeak           int
+	SessionsPeak5Min       int
+	SessionsPerSec         int
+	MaxSessionsPerSec      int
+	SessionsPerSecPeak     int
+	SessionsPerSecPeak5Min int
+	MaxSessions            int
+	MinIdleCPU             float64 // idle CPU percentage below which calls are refused
+	IdleCPU                float64 // current idle CPU percentage
+	StackSize              string
+	MaxStackSize           string
+}
+
+// uptimeUnits converts the units of the uptime line, years counted as 365 days.
+var uptimeUnits = map[string]time.Duration{
+	"year":        365 * 24 * time.Hour,
+	"day":         24 * time.Hour,
+	"hour":        time.Hour,
+	"minute":      time.Minute,
+	"second":      time.Second,
+	"millisecond": time.Millisecond,
+	"microsecond": time.Microsecond,
+}
+
+// ParseStatus parses the body of the api status reply. The lines not recognized are
+// ignored, so older or newer FreeSWITCH versions still get the known ones parsed.
+func ParseStatus(body string) (st Status, err error) {
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	upLine := strings.TrimSpace(lines[0])
+	switch {
+	case strings.HasPrefix(upLine, "UP "):
+		st.Up = true
+	case !strings.HasPrefix(upLine, "DOWN "):
+		return Status{}, fmt.Errorf("unexpected status reply received: <%s>", upLine)
+	}
+	if st.Uptime, err = parseUptime(upLine[strings.IndexByte(upLine, ' ')+1:]); err != nil {
+		return Status{}, err
+	}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "FreeSWITCH "):
+			if _, ver, has := strings.Cut(line, "(Version "); has {
+				st.Version, _, _ = strings.Cut(ver, ")")
+			}
+			st.Ready = strings.HasSuffix(line, "is ready")
+		case strings.HasSuffix(line, "session(s) since startup"):
+			_, err = fmt.Sscanf(line, "%d session(s) since startup", &st.SessionsSinceStartup)
+		case strings.Contains(line, "session(s) - peak"):
+			_, err = fmt.Sscanf(line, "%d session(s) - peak %d, last 5min %d",
+				&st.Sessions, &st.SessionsPeak, &st.SessionsPeak5Min)
+		case strings.Contains(line, "session(s) per Sec"):
+			_, err = fmt.Sscanf(line, "%d session(s) per Sec out of max %d, peak %d, last 5min %d",
+				&st.SessionsPerSec, &st.MaxSessionsPerSec, &st.SessionsPerSecPeak, &st.SessionsPerSecPeak5Min)
+		case strings.HasSuffix(line, "session(s) max"):
+			_, err = fmt.Sscanf(line, "%d session(s) max", &st.MaxSessions)
+		case strings.HasPrefix(line, "min idle cpu"):
+			_, err = fmt.Sscanf(line, "min idle cpu %f/%f", &st.MinIdleCPU, &st.IdleCPU)
+		case strings.HasPrefix(line, "Current Stack Size/Max"):
+			st.StackSize, st.MaxStackSize, _ = strings.Cut(strings.TrimSpace(
+				strings.TrimPrefix(line, "Current Stack Size/Max")), "/")
+		}
+		if err != nil {
+			return Status{}, fmt.Errorf("unexpected status line received: <%s>", line)
+		}
+	}
+	return
+}
+
+// parseUptime converts the uptime, e.g. 0 years, 0 days, 3 hours, 34 minutes.
+func parseUptime(uptime string) (d time.Duration, err error) {
+	for _, part := range strings.Split(uptime, ",") {
+		val, unit, has := strings.Cut(strings.TrimSpace(part), " ")
+		if !has {
+			return 0, fmt.Errorf("unexpected uptime received: <%s>", uptime)
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected uptime received: <%s>", uptime)
+		}
+		unitDur, known := uptimeUnits[strings.TrimSuffix(unit, "s")]
+		if !known {
+			return 0, fmt.Errorf("unexpected uptime received: <%s>", uptime)
+		}
+		d += time.Duration(n) * unitDur
+	}
+	return
+}
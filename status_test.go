@@ -0,0 +1,61 @@
+/*
+status_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStatus(t *testing.T) {
+	body := `UP 0 years, 0 days, 3 hours, 34 minutes, 57 seconds, 300 milliseconds, 531 microseconds
+FreeSWITCH (Version 1.8.2 -3-a98a958ac3 64bit) is ready
+12 session(s) since startup
+2 session(s) - peak 5, last 5min 3 
+1 session(s) per Sec out of max 30, peak 4, last 5min 2 
+1000 session(s) max
+min idle cpu 0.00/99.50
+Current Stack Size/Max 240K/8192K
+`
+	exp := Status{
+		Up: true,
+		Uptime: 3*time.Hour + 34*time.Minute + 57*time.Second +
+			300*time.Millisecond + 531*time.Microsecond,
+		Version:                "1.8.2 -3-a98a958ac3 64bit",
+		Ready:                  true,
+		SessionsSinceStartup:   12,
+		Sessions:               2,
+		SessionsPeak:           5,
+		SessionsPeak5Min:       3,
+		SessionsPerSec:         1,
+		MaxSessionsPerSec:      30,
+		SessionsPerSecPeak:     4,
+		SessionsPerSecPeak5Min: 2,
+		MaxSessions:            1000,
+		MinIdleCPU:             0,
+		IdleCPU:                99.5,
+		StackSize:              "240K",
+		MaxStackSize:           "8192K",
+	}
+	if rcv, err := ParseStatus(body); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+	if rcv, err := ParseStatus("UP 1 year, 1 day, 1 hour, 1 minute\n"); err != nil {
+		t.Error(err)
+	} else if exp := 366*24*time.Hour + time.Hour + time.Minute; rcv.Uptime != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv.Uptime)
+	}
+	if _, err := ParseStatus("-ERR command not found"); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := ParseStatus("UP 0 years\nx session(s) max"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,123 @@
+/*
+stream.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replyStream streams an api/response body straight out of the connection. The read loop
+// stays paused until it is closed, then skips what was left unread.
+type replyStream struct {
+	fsConn *FSConn
+	rdr    io.Reader     // the connection reader, limited to the body
+	mu     sync.Mutex    // keeps Close from returning during a Read
+	closed bool          // set by Close, no more reads allowed
+	err    error         // first error reading the body, EOF excluded
+	done   chan struct{} // closed by Close, resuming the read loop
+}
+
+// Read reads from the body, returning io.EOF at its end.
+func (rs *replyStream) Read(p []byte) (n int, err error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed {
+		return 0, ErrStreamClosed
+	}
+	n, err = rs.rdr.Read(p)
+	if n > 0 {
+		rs.fsConn.lastRead.Store(time.Now().UnixNano()) // keeps the watchdog quiet
+	}
+	if err != nil && err != io.EOF && rs.err == nil {
+		rs.err = err
+	}
+	return
+}
+
+// Close hands the connection back to the read loop, discarding the unread part of the body.
+func (rs *replyStream) Close() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !rs.closed {
+		rs.closed = true
+		close(rs.done)
+	}
+	return nil
+}
+
+// streamAwaited tells if the oldest command awaiting a reply wants it streamed.
+func (fsConn *FSConn) streamAwaited() bool {
+	fsConn.repliesMux.Lock()
+	defer fsConn.repliesMux.Unlock()
+	return len(fsConn.waiters) != 0 && fsConn.waiters[0].stream != nil
+}
+
+// streamReply hands the body announced by header to the oldest command awaiting a reply,
+// waiting for it to be consumed before the read loop goes on.
+func (fsConn *FSConn) streamReply(header []byte) error {
+	cl, _, err := contentLength(header)
+	if err != nil {
+		return fmt.Errorf("invalid Content-Length header: %v", err)
+	}
+	rs := &replyStream{
+		fsConn: fsConn,
+		rdr:    io.LimitReader(fsConn.rdr, int64(cl)),
+		done:   make(chan struct{}),
+	}
+	fsConn.repliesMux.Lock()
+	waiter := fsConn.popWaiter()
+	fsConn.repliesMux.Unlock()
+	waiter.stream <- rs
+	select {
+	case <-rs.done:
+	case <-waiter.gone:
+		rs.Close()
+	}
+	if _, err = io.Copy(io.Discard, rs.rdr); err == nil {
+		err = rs.err
+	}
+	if err != nil {
+		fsConn.log().Err(fmt.Sprintf("<FSock> Error reading message body: <%v>", err))
+		fsConn.conn.Close()
+		return io.EOF // Return io.EOF to trigger ReconnectIfNeeded.
+	}
+	if fsConn.tracer != nil {
+		fsConn.tracer.trace(traceReceived, fsConn.connIdx, cl, "<streamed body>")
+	}
+	return nil
+}
+
+// SendStream is the same as Send, streaming the body of an api/response out of the
+// connection instead of buffering it, e.g. for api show channels on a busy box.
+func (fsConn *FSConn) SendStream(payload string) (io.ReadCloser, error) {
+	return fsConn.SendStreamCtx(context.Background(), payload)
+}
+
+// SendStreamCtx is the same as SendStream, giving up on waiting for the reply once ctx
+// is done. The body must be closed, the events and the replies of the other commands
+// being read only afterwards. It is not checked for -ERR, unlike the command/reply
+// received instead of it, returned as *APIError.
+func (fsConn *FSConn) SendStreamCtx(ctx context.Context, payload string) (io.ReadCloser, error) {
+	reply, rs, err := fsConn.roundTrip(ctx, payload, true)
+	if err != nil {
+		return nil, err
+	}
+	if rs != nil {
+		return rs, nil
+	}
+	if strings.Contains(reply, "-ERR") {
+		return nil, newAPIError(fsConn.redact(payload), fsConn.redact(reply))
+	}
+	return io.NopCloser(strings.NewReader(reply)), nil
+}
@@ -0,0 +1,92 @@
+/*
+stream_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFSockSendApiCmdStream(t *testing.T) {
+	channels := strings.Repeat("uuid,direction,created,name,state\n", 1<<15)
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		rdr := bufio.NewReader(c)
+		for {
+			line, err := rdr.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			body := "+OK " + strings.TrimPrefix(line, "api ")
+			switch {
+			case line == "api show channels":
+				body = channels
+			case line == "api slow":
+				time.Sleep(100 * time.Millisecond)
+			}
+			if _, err := fmt.Fprintf(c, "Content-Type: api/response\nContent-Length: %d\n\n%s", len(body), body); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	fs, err := NewFSockWithOptions(addr, "ClueCon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+
+	// the whole body is streamed
+	body, err := fs.SendApiCmdStream("show channels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcv, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Error(err)
+	} else if string(rcv) != channels {
+		t.Errorf("streamed %d bytes out of %d", len(rcv), len(channels))
+	}
+	if _, err := body.Read(make([]byte, 1)); err != ErrStreamClosed {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", ErrStreamClosed, err)
+	}
+
+	// the unread part is skipped once closed
+	if body, err = fs.SendApiCmdStream("show channels"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = body.Read(make([]byte, 10)); err != nil {
+		t.Error(err)
+	}
+	body.Close()
+	if rply, err := fs.SendApiCmd("status"); err != nil {
+		t.Error(err)
+	} else if rply != "+OK status" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "+OK status", rply)
+	}
+
+	// the body of a stream given up on is skipped as well
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = fs.SendApiCmdStreamCtx(ctx, "slow"); err != context.DeadlineExceeded {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", context.DeadlineExceeded, err)
+	}
+	if rply, err := fs.SendApiCmd("status"); err != nil {
+		t.Error(err)
+	} else if rply != "+OK status" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "+OK status", rply)
+	}
+}
@@ -0,0 +1,43 @@
+/*
+trace.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Directions of the traced frames.
+const (
+	traceSent     = ">>>"
+	traceReceived = "<<<"
+)
+
+// wireTracer writes the raw frames exchanged with FreeSWITCH, shared by the successive
+// connections of a FSock.
+type wireTracer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	maxFrame int // bytes written out of each frame, all if not positive
+}
+
+// trace writes the frame of size bytes going in the direction dir, already redacted,
+// truncating it to maxFrame bytes.
+func (wt *wireTracer) trace(dir string, connIdx, size int, frame string) {
+	if wt.maxFrame > 0 && len(frame) > wt.maxFrame {
+		frame = fmt.Sprintf("%s...[%d bytes truncated]", frame[:wt.maxFrame], len(frame)-wt.maxFrame)
+	}
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	fmt.Fprintf(wt.w, "%s %s conn_idx=%d len=%d\n%s\n",
+		time.Now().Format(time.RFC3339Nano), dir, connIdx, size, strings.TrimRight(frame, "\n"))
+}
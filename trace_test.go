@@ -0,0 +1,49 @@
+/*
+trace_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestWireTracerTruncate(t *testing.T) {
+	var buf bytes.Buffer
+	wt := &wireTracer{w: &buf, maxFrame: 4}
+	wt.trace(traceSent, 2, 12, "api status\n\n")
+	if exp := " >>> conn_idx=2 len=12\napi ...[8 bytes truncated]\n"; !strings.HasSuffix(buf.String(), exp) {
+		t.Errorf("expected suffix %q, received: %q", exp, buf.String())
+	}
+}
+
+func TestFSockWireTrace(t *testing.T) {
+	addr := mockFreeSWITCH(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	var buf syncBuffer
+	fs, err := NewFSockWithOptions(addr, "ClueCon", WithWireTrace(&buf, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	trace := buf.String()
+	for _, exp := range []string{
+		"<<< conn_idx=0 len=13\nauth/request\n",
+		">>> conn_idx=0 len=14\nauth ********\n",
+		"<<< conn_idx=0 len=25\nReply-Text: +OK accepted\n",
+	} {
+		if !strings.Contains(trace, exp) {
+			t.Errorf("expected %q within: %s", exp, trace)
+		}
+	}
+	if strings.Contains(trace, "ClueCon") {
+		t.Errorf("password leaked into the trace: %s", trace)
+	}
+}
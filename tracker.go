@@ -0,0 +1,161 @@
+/*
+tracker.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+
+*/
+
+package fsock
+
+import (
+	"sync"
+	"time"
+)
+
+// trackerTombstoneTTL is how long the hung up channels are remembered, so their events
+// dispatched late do not bring them back.
+const trackerTombstoneTTL = time.Minute
+
+// TrackedChannel is the state of a live channel as known by ChannelTracker.
+type TrackedChannel struct {
+	UUID              string
+	CallUUID          string // Channel-Call-UUID, shared by the legs of the call
+	Direction         string
+	CallerIDNumber    string
+	DestinationNumber string
+	State             string // Channel-State, e.g. CS_EXECUTE
+	CallState         string // Channel-Call-State, e.g. RINGING, ACTIVE or HELD
+	Created           time.Time
+	Answered          time.Time // zero until answered
+	Updated           time.Time // Event-Date-Timestamp of the last event applied
+}
+
+// ChannelTracker keeps the live channels out of their CHANNEL_CREATE, CHANNEL_CALLSTATE
+// and CHANNEL_HANGUP events. The events older than the state already known are ignored,
+// so their order of dispatching does not matter.
+type ChannelTracker struct {
+	mu    sync.RWMutex
+	chans map[string]TrackedChannel
+	gone  map[string]time.Time // hung up channels, by the time they were forgotten
+}
+
+// NewChannelTracker returns an empty ChannelTracker.
+func NewChannelTracker() *ChannelTracker {
+	return &ChannelTracker{
+		chans: make(map[string]TrackedChannel),
+		gone:  make(map[string]time.Time),
+	}
+}
+
+// Attach subscribes the tracker to the channel events of fs, over reconnects.
+func (ct *ChannelTracker) Attach(fs *FSock) error {
+	for _, evName := range []string{"CHANNEL_CREATE", "CHANNEL_CALLSTATE", "CHANNEL_HANGUP"} {
+		if _, err := fs.AddEventHandler(evName, ct.Handle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handle applies the channel event, to be registered as handler of the CHANNEL_CREATE,
+// CHANNEL_CALLSTATE and CHANNEL_HANGUP events. The other events are ignored.
+func (ct *ChannelTracker) Handle(event string, _ int) {
+	ev := NewEvent(event)
+	uuid := ev.UUID()
+	if uuid == "" {
+		return
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if _, gone := ct.gone[uuid]; gone {
+		return
+	}
+	ch, known := ct.chans[uuid]
+	updated := ev.Timestamp()
+	if known && updated.Before(ch.Updated) {
+		return
+	}
+	switch ev.Name() {
+	case "CHANNEL_HANGUP":
+		delete(ct.chans, uuid)
+		now := time.Now()
+		for goneUUID, goneAt := range ct.gone {
+			if now.Sub(goneAt) > trackerTombstoneTTL {
+				delete(ct.gone, goneUUID)
+			}
+		}
+		ct.gone[uuid] = now
+		return
+	case "CHANNEL_CREATE", "CHANNEL_CALLSTATE":
+	default:
+		return
+	}
+	ch.UUID = uuid
+	ch.Updated = updated
+	for val, hdr := range map[*string]string{
+		&ch.CallUUID:          "Channel-Call-UUID",
+		&ch.Direction:         "Call-Direction",
+		&ch.CallerIDNumber:    "Caller-Caller-ID-Number",
+		&ch.DestinationNumber: "Caller-Destination-Number",
+		&ch.State:             "Channel-State",
+		&ch.CallState:         "Channel-Call-State",
+	} {
+		if hdrVal := ev.GetHeader(hdr); hdrVal != "" {
+			*val = hdrVal
+		}
+	}
+	if created := channelTime(ev.GetHeader("Caller-Channel-Created-Time")); !created.IsZero() {
+		ch.Created = created
+	}
+	if answered := channelTime(ev.GetHeader("Caller-Channel-Answered-Time")); !answered.IsZero() {
+		ch.Answered = answered
+	}
+	ct.chans[uuid] = ch
+}
+
+// Get returns the channel with the given uuid, if live.
+func (ct *ChannelTracker) Get(uuid string) (TrackedChannel, bool) {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	ch, has := ct.chans[uuid]
+	return ch, has
+}
+
+// Channels returns the live channels, in no particular order.
+func (ct *ChannelTracker) Channels() []TrackedChannel {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	chans := make([]TrackedChannel, 0, len(ct.chans))
+	for _, ch := range ct.chans {
+		chans = append(chans, ch)
+	}
+	return chans
+}
+
+// Call returns the live legs of the call with the given Channel-Call-UUID.
+func (ct *ChannelTracker) Call(callUUID string) (legs []TrackedChannel) {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	for _, ch := range ct.chans {
+		if ch.CallUUID == callUUID {
+			legs = append(legs, ch)
+		}
+	}
+	return
+}
+
+// Count returns the number of live channels.
+func (ct *ChannelTracker) Count() int {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	return len(ct.chans)
+}
+
+// Reset forgets all the channels, e.g. after a reconnect missing their events.
+func (ct *ChannelTracker) Reset() {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	clear(ct.chans)
+	clear(ct.gone)
+}
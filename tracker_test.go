@@ -0,0 +1,83 @@
+/*
+tracker_test.go is released under the MIT License <http://www.opensource.org/licenses/mit-license.php
+Copyright (C) ITsysCOM. All Rights Reserved.
+
+Provides FreeSWITCH socket communication.
+*/
+package fsock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cgrates/fsock/fsocktest"
+)
+
+func TestChannelTracker(t *testing.T) {
+	ct := NewChannelTracker()
+	ct.Handle("Event-Name: CHANNEL_CREATE\nUnique-ID: a1\nChannel-Call-UUID: a1\nCall-Direction: inbound\n"+
+		"Caller-Caller-ID-Number: 1001\nCaller-Destination-Number: 1002\nChannel-State: CS_INIT\n"+
+		"Channel-Call-State: DOWN\nCaller-Channel-Created-Time: 1704880800000000\n"+
+		"Caller-Channel-Answered-Time: 0\nEvent-Date-Timestamp: 1704880800000100\n", 0)
+	ct.Handle("Event-Name: CHANNEL_CREATE\nUnique-ID: b1\nChannel-Call-UUID: a1\nCall-Direction: outbound\n"+
+		"Event-Date-Timestamp: 1704880801000000\n", 0)
+	ct.Handle("Event-Name: CHANNEL_CALLSTATE\nUnique-ID: a1\nChannel-State: CS_EXECUTE\nChannel-Call-State: ACTIVE\n"+
+		"Caller-Channel-Answered-Time: 1704880805000000\nEvent-Date-Timestamp: 1704880805000100\n", 0)
+	// dispatched late, older than the state known
+	ct.Handle("Event-Name: CHANNEL_CALLSTATE\nUnique-ID: a1\nChannel-Call-State: RINGING\n"+
+		"Event-Date-Timestamp: 1704880802000000\n", 0)
+
+	exp := TrackedChannel{
+		UUID:              "a1",
+		CallUUID:          "a1",
+		Direction:         "inbound",
+		CallerIDNumber:    "1001",
+		DestinationNumber: "1002",
+		State:             "CS_EXECUTE",
+		CallState:         "ACTIVE",
+		Created:           time.UnixMicro(1704880800000000),
+		Answered:          time.UnixMicro(1704880805000000),
+		Updated:           time.UnixMicro(1704880805000100),
+	}
+	if ch, has := ct.Get("a1"); !has {
+		t.Error("channel not tracked")
+	} else if ch != exp {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, ch)
+	}
+	if ct.Count() != 2 || len(ct.Channels()) != 2 || len(ct.Call("a1")) != 2 {
+		t.Errorf("unexpected channels: %+v", ct.Channels())
+	}
+
+	ct.Handle("Event-Name: CHANNEL_HANGUP\nUnique-ID: b1\nEvent-Date-Timestamp: 1704880810000000\n", 0)
+	// dispatched after the hangup
+	ct.Handle("Event-Name: CHANNEL_CALLSTATE\nUnique-ID: b1\nChannel-Call-State: ACTIVE\n"+
+		"Event-Date-Timestamp: 1704880805000000\n", 0)
+	if _, has := ct.Get("b1"); has || ct.Count() != 1 {
+		t.Errorf("unexpected channels: %+v", ct.Channels())
+	}
+	ct.Reset()
+	if ct.Count() != 0 {
+		t.Errorf("unexpected channels: %+v", ct.Channels())
+	}
+}
+
+func TestChannelTrackerAttach(t *testing.T) {
+	srv := fsocktest.NewServer(t)
+	fs, err := NewFSockWithOptions(srv.Addr(), fsocktest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Disconnect()
+	ct := NewChannelTracker()
+	if err = ct.Attach(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.SendEvent(map[string]string{"Event-Name": "CHANNEL_CREATE", "Unique-ID": "a1"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); ct.Count() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("channel not tracked")
+		}
+	}
+}
@@ -8,6 +8,7 @@ package fsock
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
@@ -17,7 +18,8 @@ import (
 
 const EventBodyTag = "EvBody"
 
-type logger interface {
+// Logger is the logging interface used across the package, modelled after *syslog.Writer.
+type Logger interface {
 	Alert(string) error
 	Close() error
 	Crit(string) error
@@ -40,8 +42,52 @@ func (nopLogger) Info(string) error    { return nil }
 func (nopLogger) Notice(string) error  { return nil }
 func (nopLogger) Warning(string) error { return nil }
 
+// FieldLogger is a Logger able to attach structured fields to its messages, see SlogLogger.
+// The fields are given as alternating keys and values, e.g. "conn_idx", 1.
+type FieldLogger interface {
+	Logger
+	With(args ...any) Logger
+}
+
+// connLogger scopes a plain Logger to one connection, keeping its fields as keys and
+// values and prefixing every message with them, e.g. "[conn_idx=1] ".
+type connLogger struct {
+	lgr    Logger
+	fields []any
+	prefix string
+}
+
+func newConnLogger(lgr Logger, fields ...any) connLogger {
+	var prefix strings.Builder
+	prefix.WriteByte('[')
+	for i := 0; i+1 < len(fields); i += 2 {
+		if i != 0 {
+			prefix.WriteByte(' ')
+		}
+		fmt.Fprintf(&prefix, "%v=%v", fields[i], fields[i+1])
+	}
+	prefix.WriteString("] ")
+	return connLogger{lgr: lgr, fields: fields, prefix: prefix.String()}
+}
+
+// With returns the logger with the args fields, alternating keys and values, added to the prefix.
+func (cl connLogger) With(args ...any) Logger {
+	return newConnLogger(cl.lgr, append(slices.Clip(cl.fields), args...)...)
+}
+
+func (cl connLogger) Alert(s string) error   { return cl.lgr.Alert(cl.prefix + s) }
+func (cl connLogger) Close() error           { return cl.lgr.Close() }
+func (cl connLogger) Crit(s string) error    { return cl.lgr.Crit(cl.prefix + s) }
+func (cl connLogger) Debug(s string) error   { return cl.lgr.Debug(cl.prefix + s) }
+func (cl connLogger) Emerg(s string) error   { return cl.lgr.Emerg(cl.prefix + s) }
+func (cl connLogger) Err(s string) error     { return cl.lgr.Err(cl.prefix + s) }
+func (cl connLogger) Info(s string) error    { return cl.lgr.Info(cl.prefix + s) }
+func (cl connLogger) Notice(s string) error  { return cl.lgr.Notice(cl.prefix + s) }
+func (cl connLogger) Warning(s string) error { return cl.lgr.Warning(cl.prefix + s) }
+
 // FSEventStrToMap transforms an FreeSWITCH event string into a map, optionally filtering headers.
-func FSEventStrToMap(fsevstr string, headers []string) map[string]string {
+func FSEventStrToMap(fsevstr string, headers []string, opts ...ParseOption) map[string]string {
+	po := newParseOptions(opts)
 	fsevent := make(map[string]string)
 	filtered := (len(headers) != 0)
 	for _, strLn := range strings.Split(fsevstr, "\n") {
@@ -49,7 +95,7 @@ func FSEventStrToMap(fsevstr string, headers []string) map[string]string {
 			if filtered && slices.Contains(headers, hdrVal[0]) {
 				continue // Loop again since we only work on filtered fields
 			}
-			fsevent[hdrVal[0]] = urlDecode(strings.TrimSpace(strings.TrimRight(hdrVal[1], "\n")))
+			fsevent[hdrVal[0]] = po.decode(strings.TrimSpace(strings.TrimRight(hdrVal[1], "\n")))
 		}
 	}
 	return fsevent
@@ -78,7 +124,10 @@ func MapChanData(chanInfoStr string, chanDelim string) (chansInfoMap []map[strin
 	return
 }
 
-func EventToMap(event string) (result map[string]string) {
+// EventToMap parses the event into a map of its URL decoded headers, the body, if any,
+// under EventBodyTag.
+func EventToMap(event string, opts ...ParseOption) (result map[string]string) {
+	po := newParseOptions(opts)
 	result = make(map[string]string)
 	body := false
 	spltevent := strings.Split(event, "\n")
@@ -92,12 +141,74 @@ func EventToMap(event string) (result map[string]string) {
 			return
 		}
 		if val := strings.SplitN(spltevent[i], ": ", 2); len(val) == 2 {
-			result[val[0]] = urlDecode(strings.TrimSpace(val[1]))
+			result[val[0]] = po.decode(strings.TrimSpace(val[1]))
+		}
+	}
+	return
+}
+
+// Header is one header of an event, as listed by ParseHeaders.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// MapChanDataJSON is the same as MapChanData for the output of show channels as json,
+// sparing the field splitting heuristics, which the channel data may still confuse.
+// The values which are not strings are kept in their JSON form, null ones as empty.
+func MapChanDataJSON(chanInfoJSON string) ([]map[string]string, error) {
+	var chanInfo struct {
+		Rows []map[string]json.RawMessage `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(chanInfoJSON), &chanInfo); err != nil {
+		return nil, fmt.Errorf("invalid channels JSON: %v", err)
+	}
+	chansInfoMap := make([]map[string]string, 0, len(chanInfo.Rows))
+	for _, row := range chanInfo.Rows {
+		chnMp := make(map[string]string, len(row))
+		for hdr, val := range row {
+			var strVal *string
+			if err := json.Unmarshal(val, &strVal); err != nil { // not a string
+				chnMp[hdr] = string(val)
+			} else if strVal != nil {
+				chnMp[hdr] = *strVal
+			} else {
+				chnMp[hdr] = ""
+			}
+		}
+		chansInfoMap = append(chansInfoMap, chnMp)
+	}
+	return chansInfoMap, nil
+}
+
+// ParseHeaders parses the headers of the event in the order received, the repeated ones
+// included, unlike EventToMap which keeps only the last of them. The body is left out.
+func ParseHeaders(event string, opts ...ParseOption) (hdrs []Header) {
+	po := newParseOptions(opts)
+	for _, line := range strings.Split(event, "\n") {
+		if len(line) == 0 {
+			return // body follows
+		}
+		if name, val, has := strings.Cut(line, ": "); has {
+			hdrs = append(hdrs, Header{Name: name, Value: po.decode(strings.TrimSpace(val))})
 		}
 	}
 	return
 }
 
+// EventToMultiMap is the same as EventToMap, keeping all the values of the repeated headers
+// in the order received. The body, if any, is the single value under EventBodyTag.
+func EventToMultiMap(event string, opts ...ParseOption) map[string][]string {
+	result := make(map[string][]string)
+	for _, hdr := range ParseHeaders(event, opts...) {
+		result[hdr.Name] = append(result[hdr.Name], hdr.Value)
+	}
+	if _, body, has := strings.Cut(event, "\n\n"); has && body != "" {
+		result[EventBodyTag] = []string{body}
+	}
+	return result
+}
+
 // helper function for uuid generation
 func genUUID() string {
 	b := make([]byte, 16)
@@ -109,7 +220,11 @@ func genUUID() string {
 }
 
 // splitIgnoreGroups splits a string by a separator, excluding elements
-// enclosed in {}, [], or ().
+// enclosed in {}, [], () or quotes. Only the quotes starting an element group
+// it, up to the quote ending it, followed by the separator or the end of s.
+// Any other quote is kept as it is, e.g. within caller names like O'Brien.
+// A backslash escapes the character following it, e.g. \, or \[ within
+// dialstrings, the escapes being kept in the elements returned.
 func splitIgnoreGroups(s, sep string, expectedLength int) []string {
 	if s == "" {
 		return []string{}
@@ -147,6 +262,15 @@ func splitIgnoreGroups(s, sep string, expectedLength int) []string {
 			if parantheses > 0 {
 				parantheses--
 			}
+		case '\\':
+			i++ // the escaped character is taken as it is
+		case '\'', '"':
+			if i != idx {
+				break // within the element, e.g. O'Brien
+			}
+			if end := closingQuote(s[i+1:], s[i], sep); end != -1 {
+				i += end + 1 // skip to the closing quote
+			}
 		}
 		i++
 	}
@@ -154,19 +278,72 @@ func splitIgnoreGroups(s, sep string, expectedLength int) []string {
 	return sl
 }
 
+// closingQuote returns the index of the first quote q within s not escaped
+// by a backslash and ending an element, followed by sep or the end of s, -1
+// if there is none.
+func closingQuote(s string, q byte, sep string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case q:
+			if i+1 == len(s) || strings.HasPrefix(s[i+1:], sep) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 // headerVal extracts a header's value from a content string.
 func headerVal(hdrs, hdr string) string {
-	var hdrSIdx, hdrEIdx int
-	if hdrSIdx = strings.Index(hdrs, hdr); hdrSIdx == -1 {
-		return ""
-	} else if hdrEIdx = strings.Index(hdrs[hdrSIdx:], "\n"); hdrEIdx == -1 {
-		hdrEIdx = len(hdrs[hdrSIdx:])
+	val, _ := lookupHeader(hdrs, hdr)
+	return val
+}
+
+// HeaderValue returns the value of the header named hdr within hdrs, e.g. an event or the
+// headers of a reply, empty if missing. Only whole header names at the start of a line
+// match, so looking up Event-Name ignores Original-Event-Name as well as any value
+// containing the text. The value is returned as received, URL encoded for the events.
+func HeaderValue(hdrs, hdr string) string {
+	return headerVal(hdrs, hdr)
+}
+
+// lookupHeader returns the raw value of the header named name within hdrs, matching the
+// name only at the start of a line so neither longer names ending in it nor the values
+// containing it are mistaken for it.
+func lookupHeader(hdrs, name string) (string, bool) {
+	for from := 0; ; {
+		idx := strings.Index(hdrs[from:], name)
+		if idx == -1 {
+			return "", false
+		}
+		idx += from
+		if val, has := strings.CutPrefix(hdrs[idx+len(name):], ": "); has &&
+			(idx == 0 || hdrs[idx-1] == '\n') {
+			if end := strings.IndexByte(val, '\n'); end != -1 {
+				val = val[:end]
+			}
+			return strings.TrimSpace(val), true
+		}
+		from = idx + 1
 	}
-	splt := strings.SplitN(hdrs[hdrSIdx:hdrSIdx+hdrEIdx], ": ", 2)
-	if len(splt) != 2 {
-		return ""
+}
+
+// networkAddr returns the network and address to use for addr. Unix sockets are
+// given either as "unix:/path/to/socket" or directly by their absolute path, the
+// SRV records as "srv:_esl._tcp.example.com", anything else is considered a TCP address.
+func networkAddr(addr string) (network, address string) {
+	if name, isSRV := strings.CutPrefix(addr, "srv:"); isSRV {
+		return "srv", name
 	}
-	return strings.TrimSpace(strings.TrimRight(splt[1], "\n"))
+	if path, isUnix := strings.CutPrefix(addr, "unix:"); isUnix {
+		return "unix", path
+	}
+	if strings.HasPrefix(addr, "/") {
+		return "unix", addr
+	}
+	return "tcp", addr
 }
 
 // urlDecode decodes URL-encoded FS event header values, reverting to the original on error.
@@ -177,7 +354,7 @@ func urlDecode(hdrVal string) string {
 	return hdrVal
 }
 
-func getMapKeys(m map[string][]func(string, int)) (keys []string) {
+func getMapKeys[V any](m map[string]V) (keys []string) {
 	keys = make([]string, len(m))
 	indx := 0
 	for key := range m {
@@ -186,3 +363,16 @@ func getMapKeys(m map[string][]func(string, int)) (keys []string) {
 	}
 	return
 }
+
+// cloneMap copies the map of slices, e.g. of handlers, so it can be altered independently
+// of the original.
+func cloneMap[V any](m map[string][]V) map[string][]V {
+	if m == nil {
+		return nil
+	}
+	cloned := make(map[string][]V, len(m))
+	for key, vals := range m {
+		cloned[key] = slices.Clone(vals)
+	}
+	return cloned
+}
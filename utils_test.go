@@ -134,6 +134,29 @@ eacd0ae4-e1d5-447d-a7aa-e422a3a7abad,outbound,2014-10-26 18:08:32,1414343312,sof
 	}
 }
 
+func TestMapChanDataJSON(t *testing.T) {
+	chanInfoJSON := `{"row_count":2,"rows":[` +
+		`{"uuid":"fed464b3-a328-453f-9437-92b9b6a400fd","direction":"inbound","created_epoch":"1414343312","application":"playback","application_data":"file,with,commas.wav","presence_data":null},` +
+		`{"uuid":"c56125cc-024a-48a2-adbc-9612f6c02334","direction":"outbound","created_epoch":1414343312,"application":"bridge","application_data":"{a=b,c=d}[e=f]sofia/gateway/gw1/1002","presence_data":""}]}`
+	eChanData := []map[string]string{
+		{"uuid": "fed464b3-a328-453f-9437-92b9b6a400fd", "direction": "inbound", "created_epoch": "1414343312",
+			"application": "playback", "application_data": "file,with,commas.wav", "presence_data": ""},
+		{"uuid": "c56125cc-024a-48a2-adbc-9612f6c02334", "direction": "outbound", "created_epoch": "1414343312",
+			"application": "bridge", "application_data": "{a=b,c=d}[e=f]sofia/gateway/gw1/1002", "presence_data": ""},
+	}
+	if rcvChanData, err := MapChanDataJSON(chanInfoJSON); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eChanData, rcvChanData) {
+		t.Errorf("Expected: %+v, received: %+v", eChanData, rcvChanData)
+	}
+	if rcvChanData, err := MapChanDataJSON(`{"row_count":0}`); err != nil || len(rcvChanData) != 0 {
+		t.Errorf("unexpected channels: %+v, err: %v", rcvChanData, err)
+	}
+	if _, err := MapChanDataJSON("0 total."); err == nil {
+		t.Error("expected error for the plain output")
+	}
+}
+
 func TestMapChanData2(t *testing.T) {
 	chanInfoStr := `uuid,direction,created,created_epoch,name,state,cid_name,cid_num,ip_addr,dest,application,application_data,dialplan,context,read_codec,read_rate,read_bit_rate,write_codec,write_rate,write_bit_rate,secure,hostname,presence_id,presence_data,callstate,callee_name,callee_num,callee_direction,call_uuid,sent_callee_name,sent_callee_num
 ba23506f-e36b-4c12-9c17-9146077bb240,inbound,2014-10-27 10:30:11,1414402211,sofia/ipbxas/dan@172.16.254.66,CS_EXECUTE,dan,dan,172.16.254.66,+4986517174963,bridge,{sip_contact_user=iPBXSuite}[origination_caller_id_number=+4986517174963,to_domain_tag=172.16.254.66,sip_h_X-CalledEPType=SIP,sip_h_X-CalledEPTag=dan,sip_h_X-ForwardedCall=false,presence_id=dan@172.16.254.66,leg_progress_timeout=50,leg_timeout=100,to_ep_type=SIP,to_ep_tag=dan,sip_h_X-CalledDomainTag=172.16.254.66,sip_h_X-Billable=false,sip_h_X-LoopApp=LOOP_ROUTED]sofia/ipbxas/dan@172.16.254.66;fs_path=sip:127.0.0.1,XML,ipbxas,PCMA,8000,64000,PCMA,8000,64000,,iPBXDev,dan@172.16.254.66,,ACTIVE,,,,ba23506f-e36b-4c12-9c17-9146077bb240,,
@@ -244,6 +267,30 @@ f66a1563-3d86-4a93-914d-3f9436f830d2,inbound,2018-06-29 04:37:18,1530261438,sofi
 	}
 }
 
+func TestMapChanDataApostrophes(t *testing.T) {
+	chanInfoStr := `uuid,direction,created,created_epoch,name,state,cid_name,cid_num,ip_addr,dest,application,application_data,dialplan,context,read_codec,read_rate,read_bit_rate,write_codec,write_rate,write_bit_rate,secure,hostname,presence_id,presence_data,accountcode,callstate,callee_name,callee_num,callee_direction,call_uuid,sent_callee_name,sent_callee_num,initial_cid_name,initial_cid_num,initial_ip_addr,initial_dest,initial_dialplan,initial_context
+f66a1563-3d86-4a93-914d-3f9436f830d2,inbound,2018-06-29 04:37:18,1530261438,sofia/internal/1001@192.168.56.203,CS_EXECUTE,O'Brien,1001,192.168.56.2,1002,park,,XML,default,G722,16000,64000,G722,16000,64000,,teo,1001@192.168.56.203,,1001,ACTIVE,D'Angelo,1002,SEND,,,,O'Brien,1001,192.168.56.2,1002,XML,default
+
+1 total.
+`
+	rcvChanData := MapChanData(chanInfoStr, ",")
+	if len(rcvChanData) != 1 {
+		t.Fatalf("expected the channel, received: %+v", rcvChanData)
+	}
+	for hdr, val := range map[string]string{
+		"cid_name":         "O'Brien",
+		"cid_num":          "1001",
+		"callee_name":      "D'Angelo",
+		"callee_num":       "1002",
+		"initial_cid_name": "O'Brien",
+		"initial_context":  "default",
+	} {
+		if rcv := rcvChanData[0][hdr]; rcv != val {
+			t.Errorf("%s\nExpected: <%+v>, \nReceived: <%+v>", hdr, val, rcv)
+		}
+	}
+}
+
 func TestMapChanData5(t *testing.T) {
 	chanInfoStr := `uuid,direction,created,created_epoch,name,state,cid_name,cid_num,ip_addr,dest,application,application_data,dialplan,context,read_codec,read_rate,read_bit_rate,write_codec,write_rate,write_bit_rate,secure,hostname,presence_id,presence_data,accountcode,callstate,callee_name,callee_num,callee_direction,call_uuid,sent_callee_name,sent_callee_num,initial_cid_name,initial_cid_num,initial_ip_addr,initial_dest,initial_dialplan,initial_context
 f66a1563-3d86-4a93-914d-3f9436f830d2,inbound,2023-01-17 05:14:50,1673925290,sofia/internal/1001@192.168.56.203,CS_EXECUTE,1001,1001,192.168.56.2,1002,playback,1 1 1 5000 # media/mypbx_mainmenu.mp3 tone_stream://%(210,0,622.37,440)%(120,0,197,109.33) var_menu_dtmf [1,3],XML,default,G722,16000,64000,G722,16000,64000,,test,,,1001,ACTIVE,,,,,,,1001,1001,192.168.56.2,1002,XML,default
@@ -440,6 +487,30 @@ func TestUtilsHeaderValNotFound(t *testing.T) {
 	}
 }
 
+func TestLookupHeader(t *testing.T) {
+	hdrs := "Original-Event-Name: CHANNEL_PARK\nvariable_note: Event-Name: fake\nEvent-Name: CHANNEL_ANSWER\nEvent-Names: x"
+	if val, has := lookupHeader(hdrs, "Event-Name"); !has || val != "CHANNEL_ANSWER" {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", "CHANNEL_ANSWER", val)
+	}
+	if val, has := lookupHeader(hdrs, "Event"); has {
+		t.Errorf("unexpected header value: %q", val)
+	}
+}
+
+func TestUtilsHeaderValueAnchored(t *testing.T) {
+	event := "Original-Event-Name: CHANNEL_PARK\nvariable_note: Unique-ID: fake\nUnique-ID: uuid1\nEvent-Name: CHANNEL_ANSWER\n"
+	for hdr, exp := range map[string]string{
+		"Event-Name": "CHANNEL_ANSWER",
+		"Unique-ID":  "uuid1",
+		"ID":         "",
+		"Name":       "",
+	} {
+		if rcv := HeaderValue(event, hdr); rcv != exp {
+			t.Errorf("%s\nExpected: <%+v>, \nReceived: <%+v>", hdr, exp, rcv)
+		}
+	}
+}
+
 func TestUtilsToJSON(t *testing.T) {
 	m := map[string]int{
 		"testKey1": 1,
@@ -774,6 +845,61 @@ func TestUtilsSplitIgnoreGroups(t *testing.T) {
 			params:   []string{"el1 sep [el2 sep (el3 sep el4)] sep el5 sep ({el6 sep el7} sep el8) sep el9", " sep "},
 			expected: []string{"el1", "[el2 sep (el3 sep el4)]", "el5", "({el6 sep el7} sep el8)", "el9"},
 		},
+		{
+			desc:     "SingleQuotedGroup",
+			params:   []string{"el1,'file,with,commas.wav',el3", ","},
+			expected: []string{"el1", "'file,with,commas.wav'", "el3"},
+		},
+		{
+			desc:     "QuoteWithinElement",
+			params:   []string{"el1,playback 'file,el3'", ","},
+			expected: []string{"el1", "playback 'file", "el3'"},
+		},
+		{
+			desc:     "QuotesInSeveralElements",
+			params:   []string{"uuid1,O'Brien,1000,D'Angelo,park,x", ","},
+			expected: []string{"uuid1", "O'Brien", "1000", "D'Angelo", "park", "x"},
+		},
+		{
+			desc:     "QuoteNotEndingElement",
+			params:   []string{"el1,'it's,el3", ","},
+			expected: []string{"el1", "'it's", "el3"},
+		},
+		{
+			desc:     "DoubleQuotedGroup",
+			params:   []string{`el1,"el2,'el3",el4`, ","},
+			expected: []string{"el1", `"el2,'el3"`, "el4"},
+		},
+		{
+			desc:     "QuotedBrackets",
+			params:   []string{"el1,'[el2',el3]", ","},
+			expected: []string{"el1", "'[el2'", "el3]"},
+		},
+		{
+			desc:     "UnclosedQuote",
+			params:   []string{"el1,O'Brien,el3", ","},
+			expected: []string{"el1", "O'Brien", "el3"},
+		},
+		{
+			desc:     "EscapedSeparator",
+			params:   []string{`el1,el2\,el3,el4`, ","},
+			expected: []string{"el1", `el2\,el3`, "el4"},
+		},
+		{
+			desc:     "EscapedGroupOpener",
+			params:   []string{`{absolute_codec_string=PCMA\,PCMU}sofia/gw/\[1001,el2,el3`, ","},
+			expected: []string{`{absolute_codec_string=PCMA\,PCMU}sofia/gw/\[1001`, "el2", "el3"},
+		},
+		{
+			desc:     "EscapedQuote",
+			params:   []string{`el1,'it\'s,quoted',el3`, ","},
+			expected: []string{"el1", `'it\'s,quoted'`, "el3"},
+		},
+		{
+			desc:     "TrailingBackslash",
+			params:   []string{`el1,el2\`, ","},
+			expected: []string{"el1", `el2\`},
+		},
 	}
 
 	for testNr, testData := range testCases {
@@ -826,3 +952,44 @@ func BenchmarkSplitIgnoreGroupsPreallocatedSlice(b *testing.B) {
 		_ = splitIgnoreGroups(input, ",", 30)
 	}
 }
+
+func TestUtilsNetworkAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr, network, address string
+	}{
+		{addr: "127.0.0.1:8021", network: "tcp", address: "127.0.0.1:8021"},
+		{addr: "fs.cgrates.org:8021", network: "tcp", address: "fs.cgrates.org:8021"},
+		{addr: "unix:/var/run/freeswitch/esl.sock", network: "unix", address: "/var/run/freeswitch/esl.sock"},
+		{addr: "/var/run/freeswitch/esl.sock", network: "unix", address: "/var/run/freeswitch/esl.sock"},
+		{addr: "srv:_esl._tcp.cgrates.org", network: "srv", address: "_esl._tcp.cgrates.org"},
+	} {
+		if network, address := networkAddr(tc.addr); network != tc.network || address != tc.address {
+			t.Errorf("networkAddr(%q) = %q, %q, want %q, %q", tc.addr, network, address, tc.network, tc.address)
+		}
+	}
+}
+
+func TestUtilsEventToMultiMap(t *testing.T) {
+	event := "Event-Name: CUSTOM\nvariable_sip_h_X-Info: first%20value\nUnique-ID: uuid1\nvariable_sip_h_X-Info: second\n\nbody"
+	expHdrs := []Header{
+		{Name: "Event-Name", Value: "CUSTOM"},
+		{Name: "variable_sip_h_X-Info", Value: "first value"},
+		{Name: "Unique-ID", Value: "uuid1"},
+		{Name: "variable_sip_h_X-Info", Value: "second"},
+	}
+	if rcv := ParseHeaders(event); !reflect.DeepEqual(rcv, expHdrs) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", expHdrs, rcv)
+	}
+	exp := map[string][]string{
+		"Event-Name":            {"CUSTOM"},
+		"variable_sip_h_X-Info": {"first value", "second"},
+		"Unique-ID":             {"uuid1"},
+		EventBodyTag:            {"body"},
+	}
+	if rcv := EventToMultiMap(event); !reflect.DeepEqual(rcv, exp) {
+		t.Errorf("\nExpected: <%+v>, \nReceived: <%+v>", exp, rcv)
+	}
+	if rcv := EventToMultiMap(event, RawValues())["variable_sip_h_X-Info"]; !reflect.DeepEqual(rcv, []string{"first%20value", "second"}) {
+		t.Errorf("unexpected raw values: %q", rcv)
+	}
+}